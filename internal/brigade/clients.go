@@ -0,0 +1,265 @@
+package brigade
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	"github.com/brigadecore/brigade/sdk/v3/restmachinery"
+)
+
+// Clients is a set of Brigade API clients that can be replaced, as a whole,
+// while in use. The clients it returns always delegate to the most recent
+// replacements. This permits the Brigade API token to be rotated without
+// restarting.
+type Clients struct {
+	current atomic.Value
+}
+
+// clientSet holds one generation of Brigade API clients.
+type clientSet struct {
+	projects sdk.ProjectsClient
+	events   sdk.EventsClient
+	system   sdk.SystemClient
+}
+
+// NewClients returns a set of Brigade API clients for the Brigade API server
+// at the specified address that authenticate using the specified token.
+func NewClients(
+	address string,
+	token string,
+	opts restmachinery.APIClientOptions,
+) *Clients {
+	c := &Clients{}
+	c.Replace(address, token, opts)
+	return c
+}
+
+// Replace atomically replaces all of the clients with new ones for the Brigade
+// API server at the specified address that authenticate using the specified
+// token. Requests already in progress are unaffected.
+func (c *Clients) Replace(
+	address string,
+	token string,
+	opts restmachinery.APIClientOptions,
+) {
+	c.current.Store(
+		clientSet{
+			projects: sdk.NewProjectsClient(address, token, &opts),
+			events:   sdk.NewEventsClient(address, token, &opts),
+			system:   sdk.NewSystemClient(address, token, &opts),
+		},
+	)
+}
+
+func (c *Clients) get() clientSet {
+	return c.current.Load().(clientSet)
+}
+
+// Projects returns a client for managing Projects.
+func (c *Clients) Projects() sdk.ProjectsClient {
+	return &projectsClient{clients: c}
+}
+
+// Events returns a client for managing Events.
+func (c *Clients) Events() sdk.EventsClient {
+	return &eventsClient{clients: c}
+}
+
+// System returns a client for system-related concerns.
+func (c *Clients) System() sdk.SystemClient {
+	return &systemClient{clients: c}
+}
+
+// projectsClient is an implementation of the sdk.ProjectsClient interface that
+// delegates to the current generation of clients.
+type projectsClient struct {
+	clients *Clients
+}
+
+func (p *projectsClient) Create(
+	ctx context.Context,
+	project sdk.Project,
+	opts *sdk.ProjectCreateOptions,
+) (sdk.Project, error) {
+	return p.clients.get().projects.Create(ctx, project, opts)
+}
+
+func (p *projectsClient) CreateFromBytes(
+	ctx context.Context,
+	projectBytes []byte,
+	opts *sdk.ProjectCreateOptions,
+) (sdk.Project, error) {
+	return p.clients.get().projects.CreateFromBytes(ctx, projectBytes, opts)
+}
+
+func (p *projectsClient) List(
+	ctx context.Context,
+	selector *sdk.ProjectsSelector,
+	opts *meta.ListOptions,
+) (sdk.ProjectList, error) {
+	return p.clients.get().projects.List(ctx, selector, opts)
+}
+
+func (p *projectsClient) Get(
+	ctx context.Context,
+	id string,
+	opts *sdk.ProjectGetOptions,
+) (sdk.Project, error) {
+	return p.clients.get().projects.Get(ctx, id, opts)
+}
+
+func (p *projectsClient) Update(
+	ctx context.Context,
+	project sdk.Project,
+	opts *sdk.ProjectUpdateOptions,
+) (sdk.Project, error) {
+	return p.clients.get().projects.Update(ctx, project, opts)
+}
+
+func (p *projectsClient) UpdateFromBytes(
+	ctx context.Context,
+	id string,
+	projectBytes []byte,
+	opts *sdk.ProjectUpdateOptions,
+) (sdk.Project, error) {
+	return p.clients.get().projects.UpdateFromBytes(ctx, id, projectBytes, opts)
+}
+
+func (p *projectsClient) Delete(
+	ctx context.Context,
+	id string,
+	opts *sdk.ProjectDeleteOptions,
+) error {
+	return p.clients.get().projects.Delete(ctx, id, opts)
+}
+
+func (p *projectsClient) Authz() sdk.ProjectAuthzClient {
+	return p.clients.get().projects.Authz()
+}
+
+func (p *projectsClient) Secrets() sdk.SecretsClient {
+	return p.clients.get().projects.Secrets()
+}
+
+// eventsClient is an implementation of the sdk.EventsClient interface that
+// delegates to the current generation of clients.
+type eventsClient struct {
+	clients *Clients
+}
+
+func (e *eventsClient) Create(
+	ctx context.Context,
+	event sdk.Event,
+	opts *sdk.EventCreateOptions,
+) (sdk.EventList, error) {
+	return e.clients.get().events.Create(ctx, event, opts)
+}
+
+func (e *eventsClient) List(
+	ctx context.Context,
+	selector *sdk.EventsSelector,
+	opts *meta.ListOptions,
+) (sdk.EventList, error) {
+	return e.clients.get().events.List(ctx, selector, opts)
+}
+
+func (e *eventsClient) Get(
+	ctx context.Context,
+	id string,
+	opts *sdk.EventGetOptions,
+) (sdk.Event, error) {
+	return e.clients.get().events.Get(ctx, id, opts)
+}
+
+func (e *eventsClient) Clone(
+	ctx context.Context,
+	id string,
+	opts *sdk.EventCloneOptions,
+) (sdk.Event, error) {
+	return e.clients.get().events.Clone(ctx, id, opts)
+}
+
+func (e *eventsClient) UpdateSourceState(
+	ctx context.Context,
+	id string,
+	sourceState sdk.SourceState,
+	opts *sdk.EventSourceStateUpdateOptions,
+) error {
+	return e.clients.get().events.UpdateSourceState(ctx, id, sourceState, opts)
+}
+
+func (e *eventsClient) UpdateSummary(
+	ctx context.Context,
+	id string,
+	summary sdk.EventSummary,
+	opts *sdk.EventSummaryUpdateOptions,
+) error {
+	return e.clients.get().events.UpdateSummary(ctx, id, summary, opts)
+}
+
+func (e *eventsClient) Cancel(
+	ctx context.Context,
+	id string,
+	opts *sdk.EventCancelOptions,
+) error {
+	return e.clients.get().events.Cancel(ctx, id, opts)
+}
+
+func (e *eventsClient) CancelMany(
+	ctx context.Context,
+	selector sdk.EventsSelector,
+	opts *sdk.EventCancelManyOptions,
+) (sdk.CancelManyEventsResult, error) {
+	return e.clients.get().events.CancelMany(ctx, selector, opts)
+}
+
+func (e *eventsClient) Delete(
+	ctx context.Context,
+	id string,
+	opts *sdk.EventDeleteOptions,
+) error {
+	return e.clients.get().events.Delete(ctx, id, opts)
+}
+
+func (e *eventsClient) DeleteMany(
+	ctx context.Context,
+	selector sdk.EventsSelector,
+	opts *sdk.EventDeleteManyOptions,
+) (sdk.DeleteManyEventsResult, error) {
+	return e.clients.get().events.DeleteMany(ctx, selector, opts)
+}
+
+func (e *eventsClient) Retry(
+	ctx context.Context,
+	id string,
+	opts *sdk.EventRetryOptions,
+) (sdk.Event, error) {
+	return e.clients.get().events.Retry(ctx, id, opts)
+}
+
+func (e *eventsClient) Workers() sdk.WorkersClient {
+	return e.clients.get().events.Workers()
+}
+
+func (e *eventsClient) Logs() sdk.LogsClient {
+	return e.clients.get().events.Logs()
+}
+
+// systemClient is an implementation of the sdk.SystemClient interface that
+// delegates to the current generation of clients.
+type systemClient struct {
+	clients *Clients
+}
+
+func (s *systemClient) Ping(
+	ctx context.Context,
+	opts *sdk.PingOptions,
+) (sdk.PingResponse, error) {
+	return s.clients.get().system.Ping(ctx, opts)
+}
+
+func (s *systemClient) UnversionedPing(ctx context.Context) ([]byte, error) {
+	return s.clients.get().system.UnversionedPing(ctx)
+}
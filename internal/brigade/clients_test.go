@@ -0,0 +1,55 @@
+package brigade
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brigadecore/brigade/sdk/v3/restmachinery"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClients(t *testing.T) {
+	var authHeaders []string
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("{}")) // nolint: errcheck
+		}),
+	)
+	defer server.Close()
+	ctx := context.Background()
+	clients := NewClients(server.URL, "foo", restmachinery.APIClientOptions{})
+	// Clients obtained before the replacement...
+	projectsClient := clients.Projects()
+	eventsClient := clients.Events()
+	systemClient := clients.System()
+	_, err := projectsClient.Get(ctx, "italian", nil)
+	require.NoError(t, err)
+	_, err = eventsClient.Get(ctx, "tunguska", nil)
+	require.NoError(t, err)
+	_, err = systemClient.UnversionedPing(ctx)
+	require.NoError(t, err)
+	clients.Replace(server.URL, "bar", restmachinery.APIClientOptions{})
+	// ...should use the new token after it
+	_, err = projectsClient.Get(ctx, "italian", nil)
+	require.NoError(t, err)
+	_, err = eventsClient.Get(ctx, "tunguska", nil)
+	require.NoError(t, err)
+	_, err = systemClient.UnversionedPing(ctx)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]string{
+			"Bearer foo",
+			"Bearer foo",
+			"Bearer foo",
+			"Bearer bar",
+			"Bearer bar",
+			"Bearer bar",
+		},
+		authHeaders,
+	)
+}
@@ -0,0 +1,34 @@
+package reload
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const metricsNamespace = "brigade_slack_gateway"
+
+var (
+	// reloadsCounter counts attempts to reload configuration, by result.
+	reloadsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "config_reloads_total",
+			Help: "Total number of attempts to reload configuration after it " +
+				"changed, by result",
+		},
+		[]string{"result"},
+	)
+	// lastReloadSuccessfulGauge indicates whether the most recent attempt to
+	// reload configuration succeeded.
+	lastReloadSuccessfulGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "config_last_reload_successful",
+			Help: "Whether the most recent attempt to reload configuration " +
+				"succeeded (1) or was rejected (0)",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(reloadsCounter, lastReloadSuccessfulGauge)
+	// Configuration that was loaded at startup is, by definition, good.
+	lastReloadSuccessfulGauge.Set(1)
+}
@@ -0,0 +1,101 @@
+package reload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+)
+
+// Watcher periodically checks a set of configuration files for changes and,
+// whenever their contents have changed, invokes a function to reload them.
+// Files are checked by content rather than by modification time because the
+// files in Kubernetes Secret and ConfigMap volumes are updated by atomically
+// swapping symbolic links.
+type Watcher struct {
+	paths    []string
+	interval time.Duration
+	reloadFn func() error
+	checksum string
+}
+
+// NewWatcher returns a Watcher that checks the files at the specified paths
+// for changes at the specified interval and, whenever their contents have
+// changed, invokes the provided function. The function should only put new
+// configuration into effect if all of it is valid, and should otherwise
+// return an error, leaving the previous configuration in effect. The files'
+// current contents are assumed to have been loaded already.
+func NewWatcher(
+	paths []string,
+	interval time.Duration,
+	reloadFn func() error,
+) *Watcher {
+	w := &Watcher{
+		paths:    paths,
+		interval: interval,
+		reloadFn: reloadFn,
+	}
+	w.checksum, _ = checksum(paths)
+	return w
+}
+
+// Run checks for changes until the provided context is canceled.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check invokes the reload function if the contents of the files have changed
+// since they were last checked.
+func (w *Watcher) check() {
+	checksum, err := checksum(w.paths)
+	if err != nil {
+		log.Printf("error checking configuration for changes: %s", err)
+		return
+	}
+	if checksum == w.checksum {
+		return
+	}
+	// The new checksum is recorded even if the new configuration is rejected so
+	// that it is only rejected (and logged) once.
+	w.checksum = checksum
+	if err = w.reloadFn(); err != nil {
+		reloadsCounter.WithLabelValues("failure").Inc()
+		lastReloadSuccessfulGauge.Set(0)
+		log.Printf(
+			"rejected changes to %s; previous configuration remains in use: %s",
+			strings.Join(w.paths, ", "),
+			err,
+		)
+		return
+	}
+	reloadsCounter.WithLabelValues("success").Inc()
+	lastReloadSuccessfulGauge.Set(1)
+	log.Printf("reloaded configuration from %s", strings.Join(w.paths, ", "))
+}
+
+// checksum returns a hex-encoded checksum of the contents of the files at the
+// specified paths.
+func checksum(paths []string) (string, error) {
+	checksums := make([]string, len(paths))
+	for i, path := range paths {
+		fileBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(fileBytes)
+		checksums[i] = hex.EncodeToString(sum[:])
+	}
+	return strings.Join(checksums, ":"), nil
+}
@@ -0,0 +1,96 @@
+package reload
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	appsPath := filepath.Join(dir, "slack-apps.json")
+	tokenPath := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(appsPath, []byte("[]"), 0600))
+	require.NoError(t, ioutil.WriteFile(tokenPath, []byte("foo"), 0600))
+	var reloads int
+	var reloadErr error
+	w := NewWatcher(
+		[]string{appsPath, tokenPath},
+		time.Minute,
+		func() error {
+			reloads++
+			return reloadErr
+		},
+	)
+	successes := reloadsCounter.WithLabelValues("success")
+	failures := reloadsCounter.WithLabelValues("failure")
+	startingSuccesses := testutil.ToFloat64(successes)
+	startingFailures := testutil.ToFloat64(failures)
+
+	// Nothing has changed
+	w.check()
+	require.Equal(t, 0, reloads)
+
+	// A file has changed
+	require.NoError(t, ioutil.WriteFile(tokenPath, []byte("bar"), 0600))
+	w.check()
+	require.Equal(t, 1, reloads)
+	require.Equal(t, startingSuccesses+1, testutil.ToFloat64(successes))
+	require.Equal(t, float64(1), testutil.ToFloat64(lastReloadSuccessfulGauge))
+	w.check()
+	require.Equal(t, 1, reloads)
+
+	// A change is rejected
+	reloadErr = errors.New("something went wrong")
+	require.NoError(t, ioutil.WriteFile(appsPath, []byte("nonsense"), 0600))
+	w.check()
+	require.Equal(t, 2, reloads)
+	require.Equal(t, startingFailures+1, testutil.ToFloat64(failures))
+	require.Equal(t, float64(0), testutil.ToFloat64(lastReloadSuccessfulGauge))
+	// And isn't retried until the files change again
+	w.check()
+	require.Equal(t, 2, reloads)
+
+	// A file can't be read
+	require.NoError(t, ioutil.WriteFile(appsPath, []byte("[]"), 0600))
+	w.paths = append(w.paths, filepath.Join(dir, "bogus"))
+	w.check()
+	require.Equal(t, 2, reloads)
+}
+
+func TestWatcherRun(t *testing.T) {
+	appsPath := filepath.Join(t.TempDir(), "slack-apps.json")
+	require.NoError(t, ioutil.WriteFile(appsPath, []byte("[]"), 0600))
+	reloadCh := make(chan struct{}, 1)
+	w := NewWatcher(
+		[]string{appsPath},
+		10*time.Millisecond,
+		func() error {
+			reloadCh <- struct{}{}
+			return nil
+		},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		w.Run(ctx)
+	}()
+	require.NoError(
+		t,
+		ioutil.WriteFile(appsPath, []byte(`[{"appID":"42"}]`), 0600),
+	)
+	select {
+	case <-reloadCh:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for reload")
+	}
+	cancel()
+	<-doneCh
+}
@@ -0,0 +1,216 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const apiBaseURL = "https://slack.com/api/"
+
+// APIError represents an error returned by a Slack Web API method. These are
+// returned with a 200 status code, but with "ok" set to false and an error code
+// in the response body.
+type APIError struct {
+	// Method is the Slack Web API method that returned the error.
+	Method string
+	// Code is the error code returned by Slack. e.g. invalid_auth
+	Code string
+}
+
+func (a *APIError) Error() string {
+	return fmt.Sprintf("slack API method %q returned error %q", a.Method, a.Code)
+}
+
+// IsTokenError returns a bool indicating whether the provided error indicates
+// that Slack rejected the API token that was used because it is invalid or has
+// been revoked.
+func IsTokenError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == "invalid_auth" || apiErr.Code == "token_revoked"
+}
+
+// IsPermanentError returns a bool indicating whether the provided error
+// indicates that Slack rejected a request for a reason that retrying the same
+// request won't remedy, e.g. because the channel it targets does not exist or
+// has been archived. Errors pertaining to the API token, rate limiting, and
+// Slack's own internal errors are not permanent. Neither are errors that did
+// not originate from the Slack Web API, e.g. network errors.
+func IsPermanentError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case "invalid_auth", "token_revoked", "token_expired", "not_authed",
+		"account_inactive", "ratelimited", "rate_limited", "internal_error",
+		"fatal_error", "service_unavailable", "request_timeout":
+		return false
+	}
+	return true
+}
+
+// CallWithFallback invokes the specified Slack Web API method using the first
+// of the provided tokens. If Slack rejects that token as invalid or revoked,
+// the next token is tried, and so on. This permits API tokens to be rotated
+// without any interruption.
+func CallWithFallback(
+	ctx context.Context,
+	client APIClient,
+	tokens []string,
+	method string,
+	args interface{},
+	result interface{},
+) error {
+	if len(tokens) == 0 {
+		return errors.Errorf("no API token available for calling %q", method)
+	}
+	var err error
+	for _, token := range tokens {
+		if err = client.Call(ctx, token, method, args, result); !IsTokenError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// APIClient is an interface for components that can invoke methods of the
+// Slack Web API.
+type APIClient interface {
+	// Call invokes the specified Slack Web API method (e.g. chat.postMessage)
+	// using the provided bearer token. If args is of type url.Values, they are
+	// sent form-encoded. Otherwise, they are sent JSON-encoded. If the response
+	// indicates success, it is unmarshaled into the (optional) result. If it
+	// does not, an *APIError is returned.
+	Call(
+		ctx context.Context,
+		token string,
+		method string,
+		args interface{},
+		result interface{},
+	) error
+	// Respond sends the provided, JSON-encoded message to the provided response
+	// URL. Slack includes response URLs in slash commands and interactions so
+	// that apps can respond to them asynchronously.
+	Respond(ctx context.Context, responseURL string, message []byte) error
+}
+
+type apiClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewAPIClient returns an implementation of the APIClient interface.
+func NewAPIClient() APIClient {
+	return &apiClient{
+		baseURL: apiBaseURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (a *apiClient) Call(
+	ctx context.Context,
+	token string,
+	method string,
+	args interface{},
+	result interface{},
+) error {
+	var body io.Reader
+	var contentType string
+	if form, ok := args.(url.Values); ok {
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	} else {
+		argBytes, err := json.Marshal(args)
+		if err != nil {
+			return errors.Wrapf(err, "error marshaling args for %q", method)
+		}
+		body = bytes.NewBuffer(argBytes)
+		contentType = "application/json; charset=utf-8"
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("%s%s", a.baseURL, method),
+		body,
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error preparing http request for %q", method)
+	}
+	req.Header.Add("Content-Type", contentType)
+	if token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	respBytes, err := a.send(req)
+	if err != nil {
+		return errors.Wrapf(err, "error invoking %q", method)
+	}
+	apiResp := struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}{}
+	if err = json.Unmarshal(respBytes, &apiResp); err != nil {
+		return errors.Wrapf(err, "error unmarshaling response from %q", method)
+	}
+	if !apiResp.OK {
+		return &APIError{
+			Method: method,
+			Code:   apiResp.Error,
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	return errors.Wrapf(
+		json.Unmarshal(respBytes, result),
+		"error unmarshaling response from %q",
+		method,
+	)
+}
+
+func (a *apiClient) Respond(
+	ctx context.Context,
+	responseURL string,
+	message []byte,
+) error {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		responseURL,
+		bytes.NewBuffer(message),
+	)
+	if err != nil {
+		return errors.Wrap(err, "error preparing http request for response URL")
+	}
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+	_, err = a.send(req)
+	return errors.Wrap(err, "error sending message to response URL")
+}
+
+// send sends the provided request and returns the response body if the
+// response has a 200 status code.
+func (a *apiClient) send(req *http.Request) ([]byte, error) {
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("received status code %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,282 @@
+package slack
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIClient(t *testing.T) {
+	client, ok := NewAPIClient().(*apiClient)
+	require.True(t, ok)
+	require.Equal(t, apiBaseURL, client.baseURL)
+	require.NotNil(t, client.httpClient)
+}
+
+func TestAPIClientCall(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       interface{}
+		handler    http.HandlerFunc
+		assertions func(result map[string]interface{}, err error)
+	}{
+		{
+			name: "non-200 response",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			assertions: func(_ map[string]interface{}, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "received status code 500")
+			},
+		},
+		{
+			name: "error returned by slack",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`)) // nolint: errcheck
+			},
+			assertions: func(_ map[string]interface{}, err error) {
+				require.Error(t, err)
+				apiErr, ok := err.(*APIError)
+				require.True(t, ok)
+				require.Equal(t, "chat.postMessage", apiErr.Method)
+				require.Equal(t, "invalid_auth", apiErr.Code)
+			},
+		},
+		{
+			name: "success with json args",
+			args: map[string]string{"channel": "C2147483705"},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/chat.postMessage", r.URL.Path)
+				require.Equal(t, "Bearer foo", r.Header.Get("Authorization"))
+				require.Contains(t, r.Header.Get("Content-Type"), "application/json")
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.JSONEq(t, `{"channel":"C2147483705"}`, string(body))
+				w.Write([]byte(`{"ok":true,"ts":"1234"}`)) // nolint: errcheck
+			},
+			assertions: func(result map[string]interface{}, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "1234", result["ts"])
+			},
+		},
+		{
+			name: "success with form args",
+			args: url.Values{"channel": []string{"C2147483705"}},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(
+					t,
+					"application/x-www-form-urlencoded",
+					r.Header.Get("Content-Type"),
+				)
+				require.Equal(t, "C2147483705", r.FormValue("channel"))
+				w.Write([]byte(`{"ok":true,"ts":"1234"}`)) // nolint: errcheck
+			},
+			assertions: func(result map[string]interface{}, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "1234", result["ts"])
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(testCase.handler)
+			defer server.Close()
+			client := &apiClient{
+				baseURL:    server.URL + "/",
+				httpClient: server.Client(),
+			}
+			result := map[string]interface{}{}
+			err := client.Call(
+				context.Background(),
+				"foo",
+				"chat.postMessage",
+				testCase.args,
+				&result,
+			)
+			testCase.assertions(result, err)
+		})
+	}
+}
+
+func TestAPIClientRespond(t *testing.T) {
+	testCases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		assertions func(error)
+	}{
+		{
+			name: "non-200 response",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "received status code 404")
+			},
+		},
+		{
+			name: "success",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, `{"text":"hello"}`, string(body))
+				w.WriteHeader(http.StatusOK)
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(testCase.handler)
+			defer server.Close()
+			client := &apiClient{httpClient: server.Client()}
+			testCase.assertions(
+				client.Respond(
+					context.Background(),
+					server.URL,
+					[]byte(`{"text":"hello"}`),
+				),
+			)
+		})
+	}
+}
+
+func TestIsTokenError(t *testing.T) {
+	require.False(t, IsTokenError(nil))
+	require.False(t, IsTokenError(errors.New("something went wrong")))
+	require.False(
+		t,
+		IsTokenError(&APIError{Method: "chat.postMessage", Code: "not_in_channel"}),
+	)
+	require.True(
+		t,
+		IsTokenError(&APIError{Method: "chat.postMessage", Code: "invalid_auth"}),
+	)
+	require.True(
+		t,
+		IsTokenError(
+			errors.Wrap(
+				&APIError{Method: "chat.postMessage", Code: "token_revoked"},
+				"error posting message",
+			),
+		),
+	)
+}
+
+func TestIsPermanentError(t *testing.T) {
+	require.False(t, IsPermanentError(nil))
+	require.False(t, IsPermanentError(errors.New("something went wrong")))
+	for _, code := range []string{"invalid_auth", "ratelimited", "fatal_error"} {
+		require.False(
+			t,
+			IsPermanentError(&APIError{Method: "chat.postMessage", Code: code}),
+			code,
+		)
+	}
+	for _, code := range []string{"channel_not_found", "is_archived"} {
+		require.True(
+			t,
+			IsPermanentError(
+				errors.Wrap(
+					&APIError{Method: "chat.postMessage", Code: code},
+					"error posting message",
+				),
+			),
+			code,
+		)
+	}
+}
+
+func TestCallWithFallback(t *testing.T) {
+	testCases := []struct {
+		name       string
+		tokens     []string
+		assertions func(usedTokens []string, err error)
+	}{
+		{
+			name: "no tokens",
+			assertions: func(usedTokens []string, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "no API token available")
+				require.Empty(t, usedTokens)
+			},
+		},
+		{
+			name:   "primary token accepted",
+			tokens: []string{"new", "old"},
+			assertions: func(usedTokens []string, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []string{"new"}, usedTokens)
+			},
+		},
+		{
+			name:   "primary token rejected",
+			tokens: []string{"revoked", "new"},
+			assertions: func(usedTokens []string, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []string{"revoked", "new"}, usedTokens)
+			},
+		},
+		{
+			name:   "all tokens rejected",
+			tokens: []string{"revoked", "bogus"},
+			assertions: func(usedTokens []string, err error) {
+				require.Error(t, err)
+				require.True(t, IsTokenError(err))
+				require.Equal(t, []string{"revoked", "bogus"}, usedTokens)
+			},
+		},
+		{
+			name:   "other errors are not retried",
+			tokens: []string{"lost", "new"},
+			assertions: func(usedTokens []string, err error) {
+				require.Error(t, err)
+				require.False(t, IsTokenError(err))
+				require.Equal(t, []string{"lost"}, usedTokens)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			usedTokens := []string{}
+			responses := map[string]string{
+				"revoked": `{"ok":false,"error":"token_revoked"}`,
+				"bogus":   `{"ok":false,"error":"invalid_auth"}`,
+				"lost":    `{"ok":false,"error":"channel_not_found"}`,
+				"new":     `{"ok":true}`,
+			}
+			server := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					token :=
+						strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+					usedTokens = append(usedTokens, token)
+					_, err := w.Write([]byte(responses[token]))
+					require.NoError(t, err)
+				}),
+			)
+			defer server.Close()
+			err := CallWithFallback(
+				context.Background(),
+				&apiClient{
+					baseURL:    server.URL + "/",
+					httpClient: server.Client(),
+				},
+				testCase.tokens,
+				"chat.postMessage",
+				nil,
+				nil,
+			)
+			testCase.assertions(usedTokens, err)
+		})
+	}
+}
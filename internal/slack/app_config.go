@@ -0,0 +1,129 @@
+package slack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync/atomic"
+
+	"github.com/brigadecore/brigade-foundations/file"
+	"github.com/pkg/errors"
+)
+
+// Apps is a set of Slack App configurations, indexed by App ID, that is safe
+// for concurrent use and can be replaced, as a whole, while in use. This
+// permits configuration to be reloaded without restarting. A nil *Apps is
+// empty.
+type Apps struct {
+	apps atomic.Value
+}
+
+// NewApps returns a set of Slack App configurations initially containing the
+// provided configurations, which must be indexed by App ID.
+func NewApps(apps map[string]App) *Apps {
+	a := &Apps{}
+	a.Replace(apps)
+	return a
+}
+
+// Get returns configuration for the specified App and a bool indicating
+// whether any was found.
+func (a *Apps) Get(appID string) (App, bool) {
+	app, ok := a.All()[appID]
+	return app, ok
+}
+
+// All returns configuration for all Apps, indexed by App ID. The map that is
+// returned is shared and must not be modified.
+func (a *Apps) All() map[string]App {
+	if a == nil {
+		return nil
+	}
+	apps, _ := a.apps.Load().(map[string]App)
+	return apps
+}
+
+// Replace atomically replaces configuration for all Apps with the provided
+// configurations, which must be indexed by App ID.
+func (a *Apps) Replace(apps map[string]App) {
+	if apps == nil {
+		apps = map[string]App{}
+	}
+	a.apps.Store(apps)
+}
+
+// LoadApps loads Slack App configurations from the JSON file at the specified
+// path and returns them indexed by App ID.
+func LoadApps(path string) (map[string]App, error) {
+	exists, err := file.Exists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.Errorf("file %s does not exist", path)
+	}
+	appsBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	appList := []App{}
+	if err = json.Unmarshal(appsBytes, &appList); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling %s", path)
+	}
+	apps := make(map[string]App, len(appList))
+	for _, app := range appList {
+		apps[app.AppID] = app
+	}
+	if err = ValidateApps(apps); err != nil {
+		return nil, errors.Wrapf(err, "invalid configuration in %s", path)
+	}
+	return apps, nil
+}
+
+// ValidateApps returns an error if any of the provided Slack App configurations
+// is invalid in a way that would otherwise only be detected when it is used,
+// e.g. because it includes a payload template that cannot be parsed or an
+// unrecognized visibility. LoadApps applies this validation, so invalid
+// configuration is rejected by every component both at startup and when it is
+// reloaded.
+func ValidateApps(apps map[string]App) error {
+	for _, app := range apps {
+		if err := app.Visibility.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid configuration for app %q", app.AppID)
+		}
+		if err := app.Payload.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid configuration for app %q", app.AppID)
+		}
+		for _, cmd := range app.Commands {
+			if err := validateCommand(cmd); err != nil {
+				return errors.Wrapf(
+					err,
+					"invalid configuration for command %q of app %q",
+					cmd.Command,
+					app.AppID,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// validateCommand returns an error if the provided slash command configuration
+// is invalid.
+func validateCommand(cmd Command) error {
+	if err := cmd.Visibility.Validate(); err != nil {
+		return err
+	}
+	if err := cmd.Payload.Validate(); err != nil {
+		return err
+	}
+	for _, arg := range cmd.Arguments {
+		if IsReservedLabel(arg.Label) {
+			return errors.Errorf(
+				"argument %q uses reserved label %q",
+				arg.Name,
+				arg.Label,
+			)
+		}
+	}
+	return nil
+}
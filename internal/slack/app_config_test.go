@@ -0,0 +1,149 @@
+package slack
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApps(t *testing.T) {
+	var apps *Apps
+	_, ok := apps.Get("control-app")
+	require.False(t, ok)
+	require.Empty(t, apps.All())
+	apps = NewApps(map[string]App{
+		"control-app": {AppID: "control-app"},
+	})
+	app, ok := apps.Get("control-app")
+	require.True(t, ok)
+	require.Equal(t, "control-app", app.AppID)
+	apps.Replace(map[string]App{
+		"kaos-app": {AppID: "kaos-app"},
+	})
+	_, ok = apps.Get("control-app")
+	require.False(t, ok)
+	require.Len(t, apps.All(), 1)
+	apps.Replace(nil)
+	require.NotNil(t, apps.All())
+	require.Empty(t, apps.All())
+}
+
+func TestLoadApps(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadApps(filepath.Join(dir, "bogus.json"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not exist")
+	path := filepath.Join(dir, "slack-apps.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte("nonsense"), 0600))
+	_, err = LoadApps(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid character")
+	require.NoError(
+		t,
+		ioutil.WriteFile(
+			path,
+			[]byte(`[{"appID":"42","appSigningSecret":"foobar"}]`),
+			0600,
+		),
+	)
+	apps, err := LoadApps(path)
+	require.NoError(t, err)
+	require.Len(t, apps, 1)
+	require.Equal(t, "foobar", apps["42"].AppSigningSecret)
+	require.NoError(
+		t,
+		ioutil.WriteFile(
+			path,
+			[]byte(`[{"appID":"42","visibility":{"ack":"private"}}]`),
+			0600,
+		),
+	)
+	_, err = LoadApps(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid configuration")
+	require.Contains(t, err.Error(), `invalid ack visibility "private"`)
+}
+
+func TestValidateApps(t *testing.T) {
+	require.NoError(
+		t,
+		ValidateApps(
+			map[string]App{
+				"control-app": {
+					Payload: Payload{Template: "{{ .Text }}"},
+				},
+			},
+		),
+	)
+	err := ValidateApps(
+		map[string]App{
+			"control-app": {
+				Payload: Payload{Template: "{{ .Text"},
+			},
+		},
+	)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error parsing payload template")
+	// Unrecognized visibility must not silently fall back to inChannel
+	err = ValidateApps(
+		map[string]App{
+			"control-app": {
+				AppID:      "control-app",
+				Visibility: Visibility{Ack: "Ephemeral"},
+			},
+		},
+	)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid ack visibility "Ephemeral"`)
+	require.Contains(t, err.Error(), `app "control-app"`)
+	err = ValidateApps(
+		map[string]App{
+			"control-app": {
+				AppID: "control-app",
+				Commands: []Command{
+					{
+						Command:    "/deploy",
+						Visibility: Visibility{Status: "private"},
+					},
+				},
+			},
+		},
+	)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid status visibility "private"`)
+	require.Contains(t, err.Error(), `command "/deploy"`)
+	// Labels that the gateway itself adds to events may not be configured
+	for _, key := range ReservedLabels {
+		t.Run(key, func(t *testing.T) {
+			err := ValidateApps(
+				map[string]App{
+					"control-app": {
+						Payload: Payload{
+							Labels: map[string]string{key: "foo"},
+						},
+					},
+				},
+			)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "reserved label")
+			err = ValidateApps(
+				map[string]App{
+					"control-app": {
+						Commands: []Command{
+							{
+								Command: "/deploy",
+								Arguments: []Argument{
+									{Name: "env", Label: key},
+								},
+							},
+						},
+					},
+				},
+			)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "reserved label")
+		})
+	}
+}
@@ -7,7 +7,186 @@ type App struct {
 	AppID string `json:"appID"`
 	// AppSigningSecret is the secret and verify requests.
 	AppSigningSecret string `json:"appSigningSecret"`
+	// AppSigningSecrets optionally specifies additional signing secrets that are
+	// also accepted when verifying requests. This permits signing secrets to be
+	// rotated without rejecting any requests.
+	AppSigningSecrets []string `json:"appSigningSecrets,omitempty"`
 	// APIToken is the bearer token that may be used by this gateway to send
 	// messages to Slack.
 	APIToken string `json:"apiToken"`
+	// SecondaryAPIToken optionally specifies a bearer token to fall back to if
+	// Slack rejects APIToken as invalid or revoked. This permits API tokens to be
+	// rotated without any interruption.
+	SecondaryAPIToken string `json:"secondaryAPIToken,omitempty"`
+	// AppToken optionally specifies an app-level token with the
+	// connections:write scope. If specified, this gateway receives the App's
+	// slash commands, interactions, and Events API requests over a Socket Mode
+	// connection that it opens to Slack instead of over HTTP.
+	AppToken string `json:"appToken,omitempty"`
+	// Commands optionally specifies additional configuration for individual
+	// slash commands handled by this App. Slash commands do not need to be
+	// listed here to be handled by this gateway.
+	Commands []Command `json:"commands,omitempty"`
+	// Shortcuts optionally maps the callback IDs of this App's global and
+	// message shortcuts to the types of the events they should emit into
+	// Brigade. By default, a shortcut's callback ID is used as the event type.
+	Shortcuts []Shortcut `json:"shortcuts,omitempty"`
+	// Visibility optionally specifies who can see the messages this gateway
+	// sends in response to this App's slash commands. This can be overridden
+	// for individual slash commands.
+	Visibility Visibility `json:"visibility,omitempty"`
+	// Policy optionally restricts who may invoke this App's slash commands and
+	// where. Individual slash commands may be further restricted.
+	Policy Policy `json:"policy,omitempty"`
+	// Identities optionally specifies how the Slack users who invoke this App's
+	// slash commands map to Brigade users. If specified, slash commands invoked
+	// by users who cannot be mapped to a Brigade user, or whose Brigade user
+	// lacks the required role for any project that would receive the resulting
+	// event, are denied.
+	Identities *IdentityMapping `json:"identities,omitempty"`
+	// RateLimits optionally limits the rate at which this App's slash commands
+	// are accepted.
+	RateLimits RateLimits `json:"rateLimits,omitempty"`
+	// Payload optionally specifies how the payloads and additional labels of
+	// events emitted in response to this App's slash commands are formed. This
+	// can be overridden for individual commands.
+	Payload Payload `json:"payload,omitempty"`
+	// EnrichEvents indicates whether events emitted in response to this App's
+	// slash commands should be enriched with details of the user who invoked
+	// them and of the channel they were invoked in, as looked up using the Slack
+	// Web API. This requires the App to have been granted the users:read,
+	// users:read.email, channels:read, and groups:read scopes.
+	EnrichEvents bool `json:"enrichEvents,omitempty"`
+	// OAuth optionally specifies how this App may be installed into additional
+	// workspaces using Slack's OAuth v2 flow. The bot token granted by each
+	// installation is used instead of APIToken for requests from, and messages
+	// to, that workspace.
+	OAuth *OAuthConfig `json:"oauth,omitempty"`
+	// Enterprises optionally specifies additional configuration that applies
+	// only within individual Slack Enterprise Grid organizations.
+	Enterprises []Enterprise `json:"enterprises,omitempty"`
+}
+
+// Enterprise encapsulates configuration for a Slack App that applies only
+// within a single Slack Enterprise Grid organization.
+type Enterprise struct {
+	// EnterpriseID is the ID of the organization. e.g. E0001
+	EnterpriseID string `json:"enterpriseID"`
+	// APIToken optionally specifies an org-wide bot token, granted when the App
+	// was installed into the whole organization, that is used instead of the
+	// App's APIToken for every workspace in the organization.
+	APIToken string `json:"apiToken,omitempty"`
+	// Policy optionally restricts who may invoke the App's slash commands within
+	// the organization, and where, in addition to the App-level policy.
+	Policy Policy `json:"policy,omitempty"`
+}
+
+// OAuthConfig encapsulates the details needed to install a Slack App into a
+// workspace using Slack's OAuth v2 flow.
+type OAuthConfig struct {
+	// ClientID is the App's client ID.
+	ClientID string `json:"clientID"`
+	// ClientSecret is the App's client secret.
+	ClientSecret string `json:"clientSecret"`
+	// Scopes are the bot scopes requested when the App is installed.
+	Scopes []string `json:"scopes,omitempty"`
+	// RedirectURL is the URL of this gateway's /oauth/callback endpoint. It must
+	// match a redirect URL configured for the App.
+	RedirectURL string `json:"redirectURL"`
+}
+
+// Shortcut encapsulates configuration for a single global or message shortcut
+// handled by a Slack App.
+type Shortcut struct {
+	// CallbackID is the callback ID that was specified when the shortcut was
+	// created.
+	CallbackID string `json:"callbackID"`
+	// EventType is the type of the events the shortcut should emit into Brigade.
+	EventType string `json:"eventType"`
+}
+
+// SigningSecrets returns all of the App's active signing secrets.
+func (a App) SigningSecrets() []string {
+	secrets := make([]string, 0, len(a.AppSigningSecrets)+1)
+	for _, secret := range append(
+		[]string{a.AppSigningSecret},
+		a.AppSigningSecrets...,
+	) {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// APITokens returns the App's primary and secondary API tokens, in that order,
+// omitting either if not specified.
+func (a App) APITokens() []string {
+	tokens := []string{}
+	for _, token := range []string{a.APIToken, a.SecondaryAPIToken} {
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// Enterprise returns configuration for the specified Enterprise Grid
+// organization and a bool indicating whether any was found.
+func (a App) Enterprise(enterpriseID string) (Enterprise, bool) {
+	if enterpriseID == "" {
+		return Enterprise{}, false
+	}
+	for _, enterprise := range a.Enterprises {
+		if enterprise.EnterpriseID == enterpriseID {
+			return enterprise, true
+		}
+	}
+	return Enterprise{}, false
+}
+
+// Command returns configuration for the specified slash command (including its
+// leading slash) and a bool indicating whether any was found. Aliases are taken
+// into account.
+func (a App) Command(command string) (Command, bool) {
+	for _, cmd := range a.Commands {
+		if cmd.Matches(command) {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+// CommandVisibility returns the effective visibility of messages sent in
+// response to the specified slash command (including its leading slash),
+// taking into account both App-level and command-level configuration.
+func (a App) CommandVisibility(command string) Visibility {
+	visibility := a.Visibility
+	if cmd, ok := a.Command(command); ok {
+		visibility = visibility.merge(cmd.Visibility)
+	}
+	return visibility
+}
+
+// CommandPayload returns the effective configuration for the payloads and
+// additional labels of events emitted in response to the specified slash
+// command (including its leading slash), taking into account both App-level
+// and command-level configuration.
+func (a App) CommandPayload(command string) Payload {
+	payload := a.Payload
+	if cmd, ok := a.Command(command); ok {
+		payload = payload.merge(cmd.Payload)
+	}
+	return payload
+}
+
+// ShortcutEventType returns the type of the events that the shortcut with the
+// specified callback ID should emit into Brigade.
+func (a App) ShortcutEventType(callbackID string) string {
+	for _, shortcut := range a.Shortcuts {
+		if shortcut.CallbackID == callbackID && shortcut.EventType != "" {
+			return shortcut.EventType
+		}
+	}
+	return callbackID
 }
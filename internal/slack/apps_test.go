@@ -0,0 +1,157 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppCommand(t *testing.T) {
+	app := App{
+		Commands: []Command{
+			{
+				Command: "/deploy",
+				Aliases: []string{"/ship"},
+			},
+		},
+	}
+	cmd, ok := app.Command("/deploy")
+	require.True(t, ok)
+	require.Equal(t, "/deploy", cmd.Command)
+	cmd, ok = app.Command("/ship")
+	require.True(t, ok)
+	require.Equal(t, "/deploy", cmd.Command)
+	_, ok = app.Command("/bogus")
+	require.False(t, ok)
+}
+
+func TestAppEnterprise(t *testing.T) {
+	app := App{
+		Enterprises: []Enterprise{
+			{
+				EnterpriseID: "net",
+				APIToken:     "foo",
+			},
+		},
+	}
+	enterprise, ok := app.Enterprise("net")
+	require.True(t, ok)
+	require.Equal(t, "foo", enterprise.APIToken)
+	_, ok = app.Enterprise("kaos")
+	require.False(t, ok)
+	_, ok = app.Enterprise("")
+	require.False(t, ok)
+}
+
+func TestAppShortcutEventType(t *testing.T) {
+	app := App{
+		Shortcuts: []Shortcut{
+			{
+				CallbackID: "run_with_brigade",
+				EventType:  "run",
+			},
+		},
+	}
+	require.Equal(t, "run", app.ShortcutEventType("run_with_brigade"))
+	require.Equal(t, "new_release", app.ShortcutEventType("new_release"))
+}
+
+func TestAppSigningSecrets(t *testing.T) {
+	require.Empty(t, App{}.SigningSecrets())
+	require.Equal(
+		t,
+		[]string{"foo", "bar"},
+		App{
+			AppSigningSecret:  "foo",
+			AppSigningSecrets: []string{"bar"},
+		}.SigningSecrets(),
+	)
+	require.Equal(
+		t,
+		[]string{"bar"},
+		App{AppSigningSecrets: []string{"bar"}}.SigningSecrets(),
+	)
+}
+
+func TestAppAPITokens(t *testing.T) {
+	require.Empty(t, App{}.APITokens())
+	require.Equal(t, []string{"foo"}, App{APIToken: "foo"}.APITokens())
+	require.Equal(
+		t,
+		[]string{"foo", "bar"},
+		App{
+			APIToken:          "foo",
+			SecondaryAPIToken: "bar",
+		}.APITokens(),
+	)
+}
+
+func TestAppCommandVisibility(t *testing.T) {
+	app := App{
+		Visibility: Visibility{
+			Ack:    VisibilityEphemeral,
+			Status: VisibilityEphemeral,
+		},
+		Commands: []Command{
+			{
+				Command: "/rotate-secrets",
+				Visibility: Visibility{
+					Status: VisibilityDM,
+				},
+			},
+		},
+	}
+	require.Equal(
+		t,
+		Visibility{
+			Ack:    VisibilityEphemeral,
+			Status: VisibilityDM,
+		},
+		app.CommandVisibility("/rotate-secrets"),
+	)
+	require.Equal(t, app.Visibility, app.CommandVisibility("/deploy"))
+	require.Equal(t, Visibility{}, App{}.CommandVisibility("/deploy"))
+}
+
+func TestAppCommandPayload(t *testing.T) {
+	app := App{
+		Payload: Payload{
+			Format: PayloadFormatJSON,
+			Labels: map[string]string{
+				"team":    "{{ .Command.TeamDomain }}",
+				"channel": "{{ .Command.ChannelName }}",
+			},
+		},
+		Commands: []Command{
+			{
+				Command: "/deploy",
+				Payload: Payload{
+					Template: "{{ .Text }}",
+					Labels: map[string]string{
+						"channel": "{{ .Command.ChannelID }}",
+					},
+				},
+			},
+		},
+	}
+	require.Equal(
+		t,
+		Payload{
+			Format:   PayloadFormatJSON,
+			Template: "{{ .Text }}",
+			Labels: map[string]string{
+				"team":    "{{ .Command.TeamDomain }}",
+				"channel": "{{ .Command.ChannelID }}",
+			},
+		},
+		app.CommandPayload("/deploy"),
+	)
+	// The App-level labels should not have been modified
+	require.Equal(
+		t,
+		"{{ .Command.ChannelName }}",
+		app.Payload.Labels["channel"],
+	)
+	require.Equal(t, app.Payload, app.CommandPayload("/status"))
+	require.Equal(t, Payload{}, App{}.CommandPayload("/deploy"))
+}
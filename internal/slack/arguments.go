@@ -0,0 +1,361 @@
+package slack
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ArgumentTypeString represents a free-form argument. This is the default.
+	ArgumentTypeString = "string"
+	// ArgumentTypeEnum represents an argument whose value must be one of a fixed
+	// set of options.
+	ArgumentTypeEnum = "enum"
+	// ArgumentTypeSemver represents an argument whose value must be a semantic
+	// version. e.g. 1.2.3 or v1.2.3-rc.1
+	ArgumentTypeSemver = "semver"
+	// ArgumentTypeLabel represents a repeatable argument whose values must be
+	// key=value pairs. Each pair is added to the resulting event's labels.
+	ArgumentTypeLabel = "label"
+	// ArgumentTypeUser represents an argument whose value must be a mention of a
+	// Slack user. e.g. @alice
+	ArgumentTypeUser = "user"
+	// ArgumentTypeChannel represents an argument whose value must be a mention of
+	// a Slack channel. e.g. #ops
+	ArgumentTypeChannel = "channel"
+	// ArgumentTypeBool represents a flag that takes no value.
+	ArgumentTypeBool = "bool"
+)
+
+// semverRegex matches semantic versions, optionally prefixed with a v.
+var semverRegex = regexp.MustCompile(
+	`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+		`(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`,
+)
+
+// Argument encapsulates configuration for a single typed argument of a slash
+// command. Arguments are supplied as flags, e.g. --env prod or --env=prod, or,
+// if they are positional, in the order in which they are configured.
+type Argument struct {
+	// Name is the argument's flag, minus its leading dashes, as well as the key
+	// under which the argument's value will be found in the resulting event's
+	// payload.
+	Name string `json:"name"`
+	// Type is the argument's type. Valid values are "string" (the default),
+	// "enum", "semver", "label", "user", "channel", and "bool".
+	Type string `json:"type,omitempty"`
+	// Options enumerates the permitted values of an argument of type "enum".
+	Options []string `json:"options,omitempty"`
+	// Default optionally specifies the argument's value when it isn't supplied.
+	Default string `json:"default,omitempty"`
+	// Required indicates whether the argument must be supplied.
+	Required bool `json:"required,omitempty"`
+	// Positional indicates whether the argument may also be supplied without its
+	// flag.
+	Positional bool `json:"positional,omitempty"`
+	// Label optionally specifies the key of a label to add to the resulting
+	// event, with the argument's value as its value. This has no effect on
+	// arguments of type "label", whose key=value pairs are always added to the
+	// resulting event's labels.
+	Label string `json:"label,omitempty"`
+}
+
+// ParseArguments parses the specified text, which should have had any
+// subcommand removed from it already, according to the slash command's
+// arguments. It returns the value of each argument, indexed by name, as well as
+// any labels that should be added to the resulting event. The error returned
+// for invalid text is suitable for showing to the user.
+func (c Command) ParseArguments(
+	text string,
+) (map[string]interface{}, map[string]string, error) {
+	words, err := splitWords(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := map[string]interface{}{}
+	positionals := []Argument{}
+	for _, arg := range c.Arguments {
+		if arg.Positional {
+			positionals = append(positionals, arg)
+		}
+	}
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if !strings.HasPrefix(word, "--") {
+			if len(positionals) == 0 {
+				return nil, nil, errors.Errorf("unexpected argument %q", word)
+			}
+			if err = setArgument(values, positionals[0], word); err != nil {
+				return nil, nil, err
+			}
+			positionals = positionals[1:]
+			continue
+		}
+		name, value, hasValue :=
+			strings.Cut(strings.TrimPrefix(word, "--"), "=")
+		arg, ok := c.argument(name)
+		if !ok {
+			return nil, nil, errors.Errorf("unknown flag --%s", name)
+		}
+		if !hasValue {
+			if arg.Type == ArgumentTypeBool {
+				value = "true"
+			} else if i++; i < len(words) {
+				value = words[i]
+			} else {
+				return nil, nil, errors.Errorf("flag --%s requires a value", name)
+			}
+		}
+		if err = setArgument(values, arg, value); err != nil {
+			return nil, nil, err
+		}
+		// A positional argument that was supplied using its flag can't also be
+		// supplied positionally.
+		for j, positional := range positionals {
+			if positional.Name == arg.Name {
+				positionals = append(positionals[:j:j], positionals[j+1:]...)
+				break
+			}
+		}
+	}
+	labels := map[string]string{}
+	for _, arg := range c.Arguments {
+		if _, ok := values[arg.Name]; !ok {
+			switch {
+			case arg.Default != "":
+				if err = setArgument(values, arg, arg.Default); err != nil {
+					return nil, nil, err
+				}
+			case arg.Required:
+				return nil, nil,
+					errors.Errorf("missing required argument %s", arg.display())
+			default:
+				continue
+			}
+		}
+		switch value := values[arg.Name].(type) {
+		case map[string]string:
+			for key, val := range value {
+				labels[key] = val
+			}
+		default:
+			if arg.Label != "" {
+				labels[arg.Label] = fmt.Sprintf("%v", value)
+			}
+		}
+	}
+	return values, labels, nil
+}
+
+// Usage returns a one-line summary of how to supply the slash command's
+// arguments when invoking it as the specified command, which may be an alias.
+func (c Command) Usage(command string) string {
+	parts := []string{command}
+	for _, arg := range c.Arguments {
+		var part string
+		switch {
+		case arg.Type == ArgumentTypeBool:
+			part = "--" + arg.Name
+		case arg.Positional:
+			part = arg.placeholder()
+		default:
+			part = fmt.Sprintf("--%s %s", arg.Name, arg.placeholder())
+		}
+		if !arg.Required || arg.Default != "" {
+			part = "[" + part + "]"
+		}
+		if arg.Type == ArgumentTypeLabel {
+			part += "..."
+		}
+		parts = append(parts, part)
+	}
+	return "Usage: " + strings.Join(parts, " ")
+}
+
+// argument returns the slash command's argument having the specified name,
+// along with a bool indicating whether any such argument was found.
+func (c Command) argument(name string) (Argument, bool) {
+	for _, arg := range c.Arguments {
+		if arg.Name == name {
+			return arg, true
+		}
+	}
+	return Argument{}, false
+}
+
+// setArgument validates the specified value for the provided argument and, if
+// it is valid, records it in the provided map of values. Label pairs accumulate
+// across repeated flags, but other arguments may only be supplied once.
+func setArgument(
+	values map[string]interface{},
+	arg Argument,
+	value string,
+) error {
+	parsed, err := arg.parse(value)
+	if err != nil {
+		return err
+	}
+	existing, ok := values[arg.Name]
+	if !ok {
+		values[arg.Name] = parsed
+		return nil
+	}
+	if arg.Type != ArgumentTypeLabel {
+		return errors.Errorf("%s was supplied more than once", arg.display())
+	}
+	for key, val := range parsed.(map[string]string) {
+		existing.(map[string]string)[key] = val
+	}
+	return nil
+}
+
+// parse validates the specified value according to the argument's type and
+// returns it in the form in which it should appear in an event's payload.
+// Mentions of users and channels are reduced to the corresponding IDs.
+func (a Argument) parse(value string) (interface{}, error) {
+	switch a.Type {
+	case ArgumentTypeEnum:
+		for _, option := range a.Options {
+			if strings.EqualFold(option, value) {
+				return option, nil
+			}
+		}
+		return nil, errors.Errorf(
+			"invalid value %q for %s; must be one of: %s",
+			value,
+			a.display(),
+			strings.Join(a.Options, ", "),
+		)
+	case ArgumentTypeSemver:
+		if !semverRegex.MatchString(value) {
+			return nil, errors.Errorf(
+				"invalid value %q for %s; must be a semantic version, e.g. 1.2.3",
+				value,
+				a.display(),
+			)
+		}
+	case ArgumentTypeLabel:
+		key, val, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, errors.Errorf(
+				"invalid value %q for %s; must be of the form key=value",
+				value,
+				a.display(),
+			)
+		}
+		if IsReservedLabel(key) {
+			return nil, errors.Errorf(
+				"invalid value %q for %s; label %q is reserved",
+				value,
+				a.display(),
+				key,
+			)
+		}
+		return map[string]string{key: val}, nil
+	case ArgumentTypeUser:
+		if id, ok := unescapeMention(value, "@"); ok {
+			return id, nil
+		}
+		return nil, errors.Errorf(
+			"invalid value %q for %s; must mention a user, e.g. @alice",
+			value,
+			a.display(),
+		)
+	case ArgumentTypeChannel:
+		if id, ok := unescapeMention(value, "#"); ok {
+			return id, nil
+		}
+		return nil, errors.Errorf(
+			"invalid value %q for %s; must mention a channel, e.g. #ops",
+			value,
+			a.display(),
+		)
+	case ArgumentTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.Errorf(
+				"invalid value %q for %s; must be true or false",
+				value,
+				a.display(),
+			)
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+// display returns how the argument is referred to in messages to the user.
+func (a Argument) display() string {
+	if a.Positional {
+		return a.placeholder()
+	}
+	return "--" + a.Name
+}
+
+// placeholder returns a placeholder for the argument's value, for use in usage
+// messages.
+func (a Argument) placeholder() string {
+	switch a.Type {
+	case ArgumentTypeEnum:
+		return "<" + strings.Join(a.Options, "|") + ">"
+	case ArgumentTypeSemver:
+		return "<version>"
+	case ArgumentTypeLabel:
+		return "<key=value>"
+	case ArgumentTypeUser:
+		return "<@user>"
+	case ArgumentTypeChannel:
+		return "<#channel>"
+	}
+	return "<" + a.Name + ">"
+}
+
+// unescapeMention extracts the ID from a user or channel mention, as escaped by
+// Slack, having the specified sigil. e.g. <@U123|alice> or <#C123|ops> It
+// returns the ID along with a bool indicating whether the specified value was
+// such a mention.
+func unescapeMention(value string, sigil string) (string, bool) {
+	if !strings.HasPrefix(value, "<"+sigil) || !strings.HasSuffix(value, ">") {
+		return "", false
+	}
+	id, _, _ := strings.Cut(value[1+len(sigil):len(value)-1], "|")
+	return id, id != ""
+}
+
+// splitWords splits the specified text into words delimited by whitespace.
+// Double quotes, including the "smart" quotes that Slack clients may
+// substitute for them, group words containing whitespace together.
+func splitWords(text string) ([]string, error) {
+	words := []string{}
+	word := strings.Builder{}
+	inWord := false
+	quoted := false
+	for _, r := range text {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			quoted = !quoted
+			inWord = true
+		case unicode.IsSpace(r) && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,238 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandParseArguments(t *testing.T) {
+	testCommand := Command{
+		Command: "/deploy",
+		Arguments: []Argument{
+			{
+				Name:       "service",
+				Positional: true,
+				Required:   true,
+			},
+			{
+				Name:     "env",
+				Type:     ArgumentTypeEnum,
+				Options:  []string{"dev", "staging", "prod"},
+				Required: true,
+				Label:    "environment",
+			},
+			{
+				Name:    "version",
+				Type:    ArgumentTypeSemver,
+				Default: "latest",
+			},
+			{
+				Name: "label",
+				Type: ArgumentTypeLabel,
+			},
+			{
+				Name: "notify",
+				Type: ArgumentTypeUser,
+			},
+			{
+				Name: "in",
+				Type: ArgumentTypeChannel,
+			},
+			{
+				Name: "force",
+				Type: ArgumentTypeBool,
+			},
+		},
+	}
+	// The default for version is deliberately invalid, so tests that need it to
+	// be valid supply a version.
+	testCases := []struct {
+		name           string
+		text           string
+		expectedValues map[string]interface{}
+		expectedLabels map[string]string
+		expectedErr    string
+	}{
+		{
+			name: "all arguments",
+			text: "api --env=Prod --version v1.2.3-rc.1 --label team=ops " +
+				"--label tier=web --notify <@U123|alice> --in <#C456|ops> --force",
+			expectedValues: map[string]interface{}{
+				"service": "api",
+				"env":     "prod",
+				"version": "v1.2.3-rc.1",
+				"label": map[string]string{
+					"team": "ops",
+					"tier": "web",
+				},
+				"notify": "U123",
+				"in":     "C456",
+				"force":  true,
+			},
+			expectedLabels: map[string]string{
+				"environment": "prod",
+				"team":        "ops",
+				"tier":        "web",
+			},
+		},
+		{
+			name: "positional argument supplied using its flag",
+			text: `--service "api gateway" --env dev --version 1.0.0`,
+			expectedValues: map[string]interface{}{
+				"service": "api gateway",
+				"env":     "dev",
+				"version": "1.0.0",
+			},
+			expectedLabels: map[string]string{"environment": "dev"},
+		},
+		{
+			name: "smart quotes",
+			text: "“api gateway” --env dev --version 1.0.0",
+			expectedValues: map[string]interface{}{
+				"service": "api gateway",
+				"env":     "dev",
+				"version": "1.0.0",
+			},
+			expectedLabels: map[string]string{"environment": "dev"},
+		},
+		{
+			name:        "unterminated quote",
+			text:        `"api --env dev`,
+			expectedErr: "unterminated quote",
+		},
+		{
+			name:        "unknown flag",
+			text:        "api --env dev --region us-east",
+			expectedErr: "unknown flag --region",
+		},
+		{
+			name:        "unexpected argument",
+			text:        "api web --env dev",
+			expectedErr: `unexpected argument "web"`,
+		},
+		{
+			name:        "missing value",
+			text:        "api --env",
+			expectedErr: "flag --env requires a value",
+		},
+		{
+			name:        "missing required argument",
+			text:        "--env dev --version 1.0.0",
+			expectedErr: "missing required argument <service>",
+		},
+		{
+			name:        "supplied more than once",
+			text:        "api --env dev --env prod",
+			expectedErr: "--env was supplied more than once",
+		},
+		{
+			name: "invalid enum",
+			text: "api --env qa",
+			expectedErr: `invalid value "qa" for --env; ` +
+				"must be one of: dev, staging, prod",
+		},
+		{
+			name:        "invalid semver",
+			text:        "api --env dev --version 1.2",
+			expectedErr: "must be a semantic version",
+		},
+		{
+			name:        "invalid default",
+			text:        "api --env dev",
+			expectedErr: `invalid value "latest"`,
+		},
+		{
+			name:        "invalid label",
+			text:        "api --env dev --version 1.0.0 --label =ops",
+			expectedErr: "must be of the form key=value",
+		},
+		{
+			name:        "invalid user",
+			text:        "api --env dev --version 1.0.0 --notify alice",
+			expectedErr: "must mention a user",
+		},
+		{
+			name:        "channel where user expected",
+			text:        "api --env dev --version 1.0.0 --notify <#C456|ops>",
+			expectedErr: "must mention a user",
+		},
+		{
+			name:        "invalid channel",
+			text:        "api --env dev --version 1.0.0 --in ops",
+			expectedErr: "must mention a channel",
+		},
+		{
+			name:        "invalid bool",
+			text:        "api --env dev --version 1.0.0 --force=maybe",
+			expectedErr: "must be true or false",
+		},
+	}
+	// Labels that the gateway itself adds to events may not be supplied by users
+	for _, key := range ReservedLabels {
+		testCases = append(
+			testCases,
+			struct {
+				name           string
+				text           string
+				expectedValues map[string]interface{}
+				expectedLabels map[string]string
+				expectedErr    string
+			}{
+				name:        "reserved label " + key,
+				text:        "api --env dev --version 1.0.0 --label " + key + "=foo",
+				expectedErr: "is reserved",
+			},
+		)
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			values, labels, err := testCommand.ParseArguments(testCase.text)
+			if testCase.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), testCase.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testCase.expectedValues, values)
+			require.Equal(t, testCase.expectedLabels, labels)
+		})
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	command := Command{
+		Command: "/deploy",
+		Arguments: []Argument{
+			{
+				Name:       "service",
+				Positional: true,
+				Required:   true,
+			},
+			{
+				Name:     "env",
+				Type:     ArgumentTypeEnum,
+				Options:  []string{"dev", "prod"},
+				Required: true,
+			},
+			{
+				Name: "version",
+				Type: ArgumentTypeSemver,
+			},
+			{
+				Name: "label",
+				Type: ArgumentTypeLabel,
+			},
+			{
+				Name: "force",
+				Type: ArgumentTypeBool,
+			},
+		},
+	}
+	require.Equal(
+		t,
+		"Usage: /ship <service> --env <dev|prod> [--version <version>] "+
+			"[--label <key=value>]... [--force]",
+		command.Usage("/ship"),
+	)
+}
@@ -0,0 +1,185 @@
+package slack
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// ParameterTypeText represents a free-form, single-line text parameter.
+	ParameterTypeText = "text"
+	// ParameterTypeSelect represents a parameter whose value must be selected
+	// from a list of options.
+	ParameterTypeSelect = "select"
+	// ParameterTypeCheckbox represents a boolean parameter.
+	ParameterTypeCheckbox = "checkbox"
+)
+
+// Command encapsulates configuration for a single slash command handled by a
+// Slack App.
+type Command struct {
+	// Command is the slash command, including its leading slash. e.g. /deploy
+	Command string `json:"command"`
+	// Aliases optionally specifies other slash commands, including their leading
+	// slashes, that should be handled exactly like this one. e.g. /ship
+	Aliases []string `json:"aliases,omitempty"`
+	// EventType optionally specifies the type of the events the slash command
+	// (and any of its aliases) should emit into Brigade. By default, the command
+	// minus its leading slash is used as the event type.
+	EventType string `json:"eventType,omitempty"`
+	// Subcommands optionally enumerates subcommands of the slash command. When
+	// the first word of a slash command's text matches the name (or an alias)
+	// of a subcommand, that word is removed from the resulting event's payload
+	// and the subcommand's name is appended to the event type. e.g. The text
+	// "deploy prod" could result in an event of type brigade.deploy having the
+	// payload "prod".
+	Subcommands []Subcommand `json:"subcommands,omitempty"`
+	// BuiltinSubcommands indicates whether the reserved subcommands help,
+	// status, list, and logs should be handled by the gateway itself, reporting
+	// on subscribed projects and on events previously emitted into Brigade
+	// instead of emitting new ones.
+	BuiltinSubcommands bool `json:"builtinSubcommands,omitempty"`
+	// RequireConfirmation indicates whether the user who invoked the slash
+	// command must confirm exactly what will be emitted into Brigade before
+	// any event is emitted.
+	RequireConfirmation bool `json:"requireConfirmation,omitempty"`
+	// Visibility optionally overrides the App-level configuration for who can
+	// see the messages this gateway sends in response to the slash command.
+	Visibility Visibility `json:"visibility,omitempty"`
+	// Policy optionally restricts who may invoke the slash command and where.
+	// This applies in addition to any App-level policy.
+	Policy Policy `json:"policy,omitempty"`
+	// RateLimits optionally limits the rate at which the slash command is
+	// accepted, in addition to any App-level rate limits.
+	RateLimits RateLimits `json:"rateLimits,omitempty"`
+	// Payload optionally overrides the App-level configuration for how the
+	// payloads and additional labels of events emitted in response to the slash
+	// command are formed.
+	Payload Payload `json:"payload,omitempty"`
+	// Parameters optionally specifies parameters for the slash command. If any
+	// are specified and the command is invoked with no text, a modal form will
+	// be opened to collect values for each parameter. The values collected will
+	// be used to form a JSON payload for the resulting event(s).
+	Parameters []Parameter `json:"parameters,omitempty"`
+	// Arguments optionally specifies typed arguments for the slash command. If
+	// any are specified, the slash command's text, with any subcommand removed,
+	// is parsed and validated accordingly before any event is emitted. Invalid
+	// text is answered with a usage message. The values parsed will be used to
+	// form a JSON payload for the resulting event(s).
+	Arguments []Argument `json:"arguments,omitempty"`
+}
+
+// Subcommand encapsulates configuration for a single subcommand of a slash
+// command.
+type Subcommand struct {
+	// Name is the subcommand's name. e.g. deploy
+	Name string `json:"name"`
+	// Aliases optionally specifies other names for the subcommand. e.g. ship
+	Aliases []string `json:"aliases,omitempty"`
+	// RequireConfirmation indicates whether the user who invoked the
+	// subcommand must confirm exactly what will be emitted into Brigade before
+	// any event is emitted. This is implied if the slash command itself
+	// requires confirmation.
+	RequireConfirmation bool `json:"requireConfirmation,omitempty"`
+}
+
+// Matches returns a bool indicating whether the slash command is the specified
+// command (including its leading slash) or an alias thereof.
+func (c Command) Matches(command string) bool {
+	if c.Command == command {
+		return true
+	}
+	for _, alias := range c.Aliases {
+		if alias == command {
+			return true
+		}
+	}
+	return false
+}
+
+// Route determines the type of the event that should be emitted into Brigade
+// when the slash command is invoked with the specified text. It returns the
+// event type along with whatever text remains after any subcommand has been
+// removed from it.
+func (c Command) Route(text string) (string, string) {
+	eventType := c.EventType
+	if eventType == "" {
+		eventType = strings.TrimPrefix(c.Command, "/")
+	}
+	if len(c.Subcommands) == 0 {
+		return eventType, text
+	}
+	word, rest := firstWord(text)
+	for _, sub := range c.Subcommands {
+		if sub.Matches(word) {
+			return eventType + "." + sub.Name, rest
+		}
+	}
+	return eventType, text
+}
+
+// RequiresConfirmation returns a bool indicating whether invoking the slash
+// command with the specified text requires confirmation, either because the
+// slash command itself does or because the subcommand that the text routes to
+// does.
+func (c Command) RequiresConfirmation(text string) bool {
+	if c.RequireConfirmation {
+		return true
+	}
+	word, _ := firstWord(text)
+	for _, sub := range c.Subcommands {
+		if sub.Matches(word) {
+			return sub.RequireConfirmation
+		}
+	}
+	return false
+}
+
+// Matches returns a bool indicating whether the specified word is the
+// subcommand's name or an alias thereof. Comparisons are case-insensitive.
+func (s Subcommand) Matches(word string) bool {
+	if word == "" {
+		return false
+	}
+	if strings.EqualFold(s.Name, word) {
+		return true
+	}
+	for _, alias := range s.Aliases {
+		if strings.EqualFold(alias, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstWord splits the specified text into its first word and whatever text
+// follows it, with leading and trailing whitespace removed from both.
+func firstWord(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	i := strings.IndexFunc(text, unicode.IsSpace)
+	if i < 0 {
+		return text, ""
+	}
+	return text[:i], strings.TrimSpace(text[i:])
+}
+
+// Parameter encapsulates configuration for a single slash command parameter.
+type Parameter struct {
+	// Name is the key under which the parameter's value will be found in the
+	// JSON payload of the resulting event(s).
+	Name string `json:"name"`
+	// Label is the text used to label the parameter's form field. If not
+	// specified, Name is used instead.
+	Label string `json:"label,omitempty"`
+	// Type is the parameter's type. Valid values are "text" (the default),
+	// "select", and "checkbox".
+	Type string `json:"type,omitempty"`
+	// Options enumerates the values that can be selected for a parameter of
+	// type "select".
+	Options []string `json:"options,omitempty"`
+	// Default optionally specifies the parameter's initial value. For
+	// parameters of type "checkbox", this should be "true" or "false".
+	Default string `json:"default,omitempty"`
+	// Optional indicates whether the parameter may be left blank.
+	Optional bool `json:"optional,omitempty"`
+}
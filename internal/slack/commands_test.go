@@ -0,0 +1,147 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandRoute(t *testing.T) {
+	testCases := []struct {
+		name              string
+		command           Command
+		text              string
+		expectedEventType string
+		expectedText      string
+	}{
+		{
+			name:              "no event type or subcommands",
+			command:           Command{Command: "/deploy"},
+			text:              "prod",
+			expectedEventType: "deploy",
+			expectedText:      "prod",
+		},
+		{
+			name: "event type specified",
+			command: Command{
+				Command:   "/ship",
+				EventType: "deploy",
+			},
+			text:              "prod",
+			expectedEventType: "deploy",
+			expectedText:      "prod",
+		},
+		{
+			name: "subcommand matched",
+			command: Command{
+				Command: "/brigade",
+				Subcommands: []Subcommand{
+					{Name: "deploy"},
+				},
+			},
+			text:              "  deploy   prod 1.2.3 ",
+			expectedEventType: "brigade.deploy",
+			expectedText:      "prod 1.2.3",
+		},
+		{
+			name: "subcommand alias matched",
+			command: Command{
+				Command:   "/brigade",
+				EventType: "bdg",
+				Subcommands: []Subcommand{
+					{
+						Name:    "deploy",
+						Aliases: []string{"ship"},
+					},
+				},
+			},
+			text:              "SHIP",
+			expectedEventType: "bdg.deploy",
+			expectedText:      "",
+		},
+		{
+			name: "subcommand not matched",
+			command: Command{
+				Command: "/brigade",
+				Subcommands: []Subcommand{
+					{Name: "deploy"},
+				},
+			},
+			text:              "rollback prod",
+			expectedEventType: "brigade",
+			expectedText:      "rollback prod",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			eventType, text := testCase.command.Route(testCase.text)
+			require.Equal(t, testCase.expectedEventType, eventType)
+			require.Equal(t, testCase.expectedText, text)
+		})
+	}
+}
+
+func TestCommandRequiresConfirmation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		command  Command
+		text     string
+		expected bool
+	}{
+		{
+			name:     "confirmation not required",
+			command:  Command{Command: "/deploy"},
+			text:     "prod",
+			expected: false,
+		},
+		{
+			name: "command requires confirmation",
+			command: Command{
+				Command:             "/db-restore",
+				RequireConfirmation: true,
+			},
+			text:     "",
+			expected: true,
+		},
+		{
+			name: "subcommand requires confirmation",
+			command: Command{
+				Command: "/deploy",
+				Subcommands: []Subcommand{
+					{Name: "staging"},
+					{
+						Name:                "prod",
+						Aliases:             []string{"production"},
+						RequireConfirmation: true,
+					},
+				},
+			},
+			text:     "Production v1.2.3",
+			expected: true,
+		},
+		{
+			name: "other subcommand does not require confirmation",
+			command: Command{
+				Command: "/deploy",
+				Subcommands: []Subcommand{
+					{Name: "staging"},
+					{
+						Name:                "prod",
+						RequireConfirmation: true,
+					},
+				},
+			},
+			text:     "staging v1.2.3",
+			expected: false,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(
+				t,
+				testCase.expected,
+				testCase.command.RequiresConfirmation(testCase.text),
+			)
+		})
+	}
+}
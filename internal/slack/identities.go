@@ -0,0 +1,74 @@
+package slack
+
+import "strings"
+
+// IdentityMapping encapsulates configuration for mapping the Slack users who
+// invoke a Slack App's slash commands to Brigade users.
+type IdentityMapping struct {
+	// Users explicitly maps individual Slack users to Brigade users.
+	Users []UserMapping `json:"users,omitempty"`
+	// MatchEmails indicates whether Slack users who aren't explicitly mapped to a
+	// Brigade user should be mapped to the Brigade user whose ID is the same as
+	// their email address.
+	MatchEmails bool `json:"matchEmails,omitempty"`
+	// RequiredRole is the project-level Brigade role that a mapped user must hold
+	// for every project that would receive the events they trigger. If not
+	// specified, PROJECT_USER is assumed.
+	RequiredRole string `json:"requiredRole,omitempty"`
+}
+
+// UserMapping maps a single Slack user, identified either by their Slack user
+// ID or by their email address, to a Brigade user.
+type UserMapping struct {
+	// SlackUserID identifies the Slack user by ID. e.g. U2147483697
+	SlackUserID string `json:"slackUserID,omitempty"`
+	// Email identifies the Slack user by email address. e.g. max@example.com
+	Email string `json:"email,omitempty"`
+	// BrigadeUserID is the ID of the Brigade user. e.g. max@example.com
+	BrigadeUserID string `json:"brigadeUserID"`
+}
+
+// NeedsEmail returns a bool indicating whether the email address of the
+// specified Slack user is required in order to map them to a Brigade user.
+func (i IdentityMapping) NeedsEmail(slackUserID string) bool {
+	if _, ok := i.BrigadeUserID(slackUserID, ""); ok {
+		return false
+	}
+	if i.MatchEmails {
+		return true
+	}
+	for _, user := range i.Users {
+		if user.Email != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// BrigadeUserID returns the ID of the Brigade user that the specified Slack
+// user, whose email address may optionally be provided, maps to and a bool
+// indicating whether any was found. Explicit mappings by Slack user ID take
+// precedence over explicit mappings by email address, which, in turn, take
+// precedence over matching email addresses to Brigade user IDs.
+func (i IdentityMapping) BrigadeUserID(
+	slackUserID string,
+	email string,
+) (string, bool) {
+	for _, user := range i.Users {
+		if user.SlackUserID != "" && user.SlackUserID == slackUserID {
+			return user.BrigadeUserID, true
+		}
+	}
+	if email == "" {
+		return "", false
+	}
+	for _, user := range i.Users {
+		if user.Email != "" && strings.EqualFold(user.Email, email) {
+			return user.BrigadeUserID, true
+		}
+	}
+	if i.MatchEmails {
+		return email, true
+	}
+	return "", false
+}
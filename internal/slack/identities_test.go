@@ -0,0 +1,98 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityMappingNeedsEmail(t *testing.T) {
+	require.False(t, IdentityMapping{}.NeedsEmail("U86"))
+	mapping := IdentityMapping{
+		Users: []UserMapping{
+			{
+				SlackUserID:   "U86",
+				BrigadeUserID: "max@example.com",
+			},
+		},
+	}
+	require.False(t, mapping.NeedsEmail("U86"))
+	require.False(t, mapping.NeedsEmail("U99"))
+	mapping.MatchEmails = true
+	require.True(t, mapping.NeedsEmail("U99"))
+	require.True(
+		t,
+		IdentityMapping{
+			Users: []UserMapping{
+				{
+					Email:         "agent99@example.com",
+					BrigadeUserID: "99",
+				},
+			},
+		}.NeedsEmail("U99"),
+	)
+}
+
+func TestIdentityMappingBrigadeUserID(t *testing.T) {
+	mapping := IdentityMapping{
+		Users: []UserMapping{
+			{
+				SlackUserID:   "U86",
+				BrigadeUserID: "max",
+			},
+			{
+				Email:         "Agent99@example.com",
+				BrigadeUserID: "99",
+			},
+		},
+	}
+	testCases := []struct {
+		name          string
+		matchEmails   bool
+		slackUserID   string
+		email         string
+		expectedID    string
+		expectedFound bool
+	}{
+		{
+			name:          "mapped by slack user ID",
+			slackUserID:   "U86",
+			email:         "max@example.com",
+			expectedID:    "max",
+			expectedFound: true,
+		},
+		{
+			name:          "mapped by email",
+			slackUserID:   "U99",
+			email:         "agent99@example.com",
+			expectedID:    "99",
+			expectedFound: true,
+		},
+		{
+			name:        "not mapped",
+			slackUserID: "U13",
+			email:       "agent13@example.com",
+		},
+		{
+			name:          "email matched",
+			matchEmails:   true,
+			slackUserID:   "U13",
+			email:         "agent13@example.com",
+			expectedID:    "agent13@example.com",
+			expectedFound: true,
+		},
+		{
+			name:        "no email to match",
+			matchEmails: true,
+			slackUserID: "U13",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			mapping.MatchEmails = testCase.matchEmails
+			id, found := mapping.BrigadeUserID(testCase.slackUserID, testCase.email)
+			require.Equal(t, testCase.expectedID, id)
+			require.Equal(t, testCase.expectedFound, found)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// serviceAccountDir is the directory into which Kubernetes mounts a Pod's
+// service account credentials.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// tokenTTL is how long a service account token read from disk is used before
+// it is read again. Projected service account tokens are rotated by the
+// kubelet, typically hourly, so they mustn't be cached for long.
+const tokenTTL = time.Minute
+
+// kubernetesSecretClient is an implementation of the SecretClient interface
+// that reads and writes a single Kubernetes Secret using the Kubernetes API.
+type kubernetesSecretClient struct {
+	// secretURL is the URL of the Secret in the Kubernetes API.
+	secretURL string
+	// tokenPath is the path to the file containing the service account token.
+	tokenPath string
+	// tokenMu guards token and tokenExpiry.
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	httpClient  *http.Client
+}
+
+// NewKubernetesSecretClient returns an implementation of the SecretClient
+// interface that reads and writes the specified Kubernetes Secret in the
+// namespace the calling Pod is running in, using the Pod's service account
+// credentials. The service account must be permitted to get and patch the
+// Secret, which must already exist.
+func NewKubernetesSecretClient(name string) (SecretClient, error) {
+	host := os.Getenv("KUBERNETES_SERVICE_HOST")
+	port := os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New(
+			"KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set; " +
+				"is this running in a Kubernetes cluster?",
+		)
+	}
+	namespaceBytes, err := ioutil.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading service account namespace")
+	}
+	caBytes, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading service account CA cert")
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caBytes) {
+		return nil, errors.New("error parsing service account CA cert")
+	}
+	client := &kubernetesSecretClient{
+		secretURL: fmt.Sprintf(
+			"https://%s/api/v1/namespaces/%s/secrets/%s",
+			net.JoinHostPort(host, port),
+			strings.TrimSpace(string(namespaceBytes)),
+			name,
+		),
+		tokenPath: serviceAccountDir + "/token",
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:    certPool,
+					MinVersion: tls.VersionTLS12,
+				},
+			},
+		},
+	}
+	// Read the token up front so that a missing token is reported immediately
+	// rather than on first use.
+	if _, err := client.getToken(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (k *kubernetesSecretClient) GetData(
+	ctx context.Context,
+) (map[string][]byte, error) {
+	req, err :=
+		http.NewRequestWithContext(ctx, http.MethodGet, k.secretURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error preparing http request for secret")
+	}
+	secret := struct {
+		Data map[string][]byte `json:"data"`
+	}{}
+	found, err := k.send(req, &secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting secret")
+	}
+	if !found || secret.Data == nil {
+		return map[string][]byte{}, nil
+	}
+	return secret.Data, nil
+}
+
+func (k *kubernetesSecretClient) SetData(
+	ctx context.Context,
+	key string,
+	value []byte,
+) error {
+	// A JSON merge patch replaces only the specified key. Values of type []byte
+	// are base64 encoded when marshaled, which is what Kubernetes expects.
+	patchBytes, err := json.Marshal(
+		struct {
+			Data map[string][]byte `json:"data"`
+		}{
+			Data: map[string][]byte{key: value},
+		},
+	)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling secret patch")
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPatch,
+		k.secretURL,
+		bytes.NewReader(patchBytes),
+	)
+	if err != nil {
+		return errors.Wrap(err, "error preparing http request for secret")
+	}
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	found, err := k.send(req, nil)
+	if err != nil {
+		return errors.Wrap(err, "error patching secret")
+	}
+	if !found {
+		return errors.Errorf("secret %s does not exist", k.secretURL)
+	}
+	return nil
+}
+
+// send sends the provided request to the Kubernetes API and unmarshals the
+// response body into the (optional) result. It returns a bool indicating
+// whether the requested resource was found.
+func (k *kubernetesSecretClient) send(
+	req *http.Request,
+	result interface{},
+) (bool, error) {
+	token, err := k.getToken()
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("received status code %d", resp.StatusCode)
+	}
+	if result == nil {
+		return true, nil
+	}
+	return true, json.NewDecoder(resp.Body).Decode(result)
+}
+
+// getToken returns the service account token, re-reading it from disk if the
+// copy previously read is more than tokenTTL old.
+func (k *kubernetesSecretClient) getToken() (string, error) {
+	k.tokenMu.Lock()
+	defer k.tokenMu.Unlock()
+	if k.token != "" && time.Now().Before(k.tokenExpiry) {
+		return k.token, nil
+	}
+	tokenBytes, err := ioutil.ReadFile(k.tokenPath)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading service account token")
+	}
+	k.token = strings.TrimSpace(string(tokenBytes))
+	k.tokenExpiry = time.Now().Add(tokenTTL)
+	return k.token, nil
+}
@@ -0,0 +1,176 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKubernetesSecretClient(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	_, err := NewKubernetesSecretClient("installations")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "KUBERNETES_SERVICE_HOST")
+}
+
+func TestKubernetesSecretClientGetData(t *testing.T) {
+	testCases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		assertions func(map[string][]byte, error)
+	}{
+		{
+			name: "secret not found",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			assertions: func(data map[string][]byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, data)
+			},
+		},
+		{
+			name: "unexpected status code",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			assertions: func(_ map[string][]byte, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "received status code 403")
+			},
+		},
+		{
+			name: "success",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodGet, r.Method)
+				require.Equal(t, "Bearer foo", r.Header.Get("Authorization"))
+				// "YmFy" is "bar", base64 encoded
+				w.Write([]byte(`{"data":{"foo":"YmFy"}}`)) // nolint: errcheck
+			},
+			assertions: func(data map[string][]byte, err error) {
+				require.NoError(t, err)
+				require.Equal(t, map[string][]byte{"foo": []byte("bar")}, data)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(testCase.handler)
+			defer server.Close()
+			client := &kubernetesSecretClient{
+				secretURL:  server.URL,
+				tokenPath:  writeToken(t, "foo"),
+				httpClient: server.Client(),
+			}
+			testCase.assertions(client.GetData(context.Background()))
+		})
+	}
+}
+
+func TestKubernetesSecretClientSetData(t *testing.T) {
+	testCases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		assertions func(error)
+	}{
+		{
+			name: "secret not found",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "does not exist")
+			},
+		},
+		{
+			name: "success",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPatch, r.Method)
+				require.Equal(t, "Bearer foo", r.Header.Get("Authorization"))
+				require.Equal(
+					t,
+					"application/merge-patch+json",
+					r.Header.Get("Content-Type"),
+				)
+				patch := map[string]map[string]string{}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&patch))
+				require.Equal(t, map[string]string{"foo": "YmFy"}, patch["data"])
+				w.Write([]byte(`{}`)) // nolint: errcheck
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(testCase.handler)
+			defer server.Close()
+			client := &kubernetesSecretClient{
+				secretURL:  server.URL,
+				tokenPath:  writeToken(t, "foo"),
+				httpClient: server.Client(),
+			}
+			testCase.assertions(
+				client.SetData(context.Background(), "foo", []byte("bar")),
+			)
+		})
+	}
+}
+
+func TestKubernetesSecretClientTokenRotation(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			w.Write([]byte(`{}`)) // nolint: errcheck
+		}),
+	)
+	defer server.Close()
+	tokenPath := writeToken(t, "foo")
+	client := &kubernetesSecretClient{
+		secretURL:  server.URL,
+		tokenPath:  tokenPath,
+		httpClient: server.Client(),
+	}
+	_, err := client.GetData(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Bearer foo", authorization)
+	// Rotate the token
+	require.NoError(t, ioutil.WriteFile(tokenPath, []byte("bar\n"), 0600))
+	// Until the cached token expires, it should still be used
+	_, err = client.GetData(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Bearer foo", authorization)
+	// Once it has expired, the rotated token should be read
+	client.tokenExpiry = time.Now().Add(-time.Second)
+	_, err = client.GetData(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Bearer bar", authorization)
+}
+
+func TestKubernetesSecretClientMissingToken(t *testing.T) {
+	client := &kubernetesSecretClient{
+		tokenPath:  filepath.Join(t.TempDir(), "token"),
+		httpClient: http.DefaultClient,
+	}
+	_, err := client.GetData(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error reading service account token")
+}
+
+// writeToken writes the provided service account token to a temporary file
+// and returns the path to that file.
+func writeToken(t *testing.T, token string) string {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, ioutil.WriteFile(tokenPath, []byte(token+"\n"), 0600))
+	return tokenPath
+}
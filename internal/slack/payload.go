@@ -0,0 +1,114 @@
+package slack
+
+import (
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PayloadFormatText indicates that the payload of an event emitted in
+	// response to a slash command should be the slash command's text, with any
+	// subcommand removed, or, if its parameters were collected using a form, the
+	// form's values, serialized as JSON. This is the default.
+	PayloadFormatText = "text"
+	// PayloadFormatJSON indicates that the payload of an event emitted in
+	// response to a slash command should be a versioned JSON document containing
+	// all details of the slash command as well as its parsed arguments.
+	PayloadFormatJSON = "json"
+)
+
+// ReservedLabels enumerates the keys of labels that the gateway itself adds to
+// events. Brigade projects may rely on these to subscribe to events and the
+// monitor relies on some of them to report status, so neither operators nor
+// users may supply labels having these keys.
+var ReservedLabels = []string{
+	"appID",
+	"teamID",
+	"channelID",
+	"userID",
+	"enterpriseID",
+	"userName",
+	"channelName",
+	"brigadeUserID",
+}
+
+// IsReservedLabel returns a bool indicating whether the specified label key is
+// one that only the gateway itself may add to events.
+func IsReservedLabel(key string) bool {
+	return contains(ReservedLabels, key)
+}
+
+// Payload encapsulates configuration for the payloads and additional labels of
+// events emitted in response to slash commands.
+type Payload struct {
+	// Format specifies the format of the payload. Valid values are "text" (the
+	// default) and "json".
+	Format string `json:"format,omitempty"`
+	// Template is an optional Go template used to render the payload. It takes
+	// precedence over Format and is executed against the same details that
+	// would otherwise be serialized as JSON.
+	Template string `json:"template,omitempty"`
+	// Labels optionally specifies additional labels for the event, indexed by
+	// key. Each value is a Go template that is executed against the same details
+	// as Template.
+	Labels map[string]string `json:"labels,omitempty"`
+	// ShortTitle is an optional Go template used to render the event's short
+	// title. It is executed against the same details as Template.
+	ShortTitle string `json:"shortTitle,omitempty"`
+	// LongTitle is an optional Go template used to render the event's long
+	// title. It is executed against the same details as Template.
+	LongTitle string `json:"longTitle,omitempty"`
+}
+
+// merge returns a copy of the Payload with any fields that are set in the
+// provided Payload overridden. Labels are merged key by key.
+func (p Payload) merge(override Payload) Payload {
+	if override.Format != "" {
+		p.Format = override.Format
+	}
+	if override.Template != "" {
+		p.Template = override.Template
+	}
+	if override.ShortTitle != "" {
+		p.ShortTitle = override.ShortTitle
+	}
+	if override.LongTitle != "" {
+		p.LongTitle = override.LongTitle
+	}
+	if len(override.Labels) > 0 {
+		labels := make(map[string]string, len(p.Labels)+len(override.Labels))
+		for key, value := range p.Labels {
+			labels[key] = value
+		}
+		for key, value := range override.Labels {
+			labels[key] = value
+		}
+		p.Labels = labels
+	}
+	return p
+}
+
+// Validate returns an error if the Payload specifies a label having a reserved
+// key or any template that cannot be parsed.
+func (p Payload) Validate() error {
+	texts := []string{p.Template, p.ShortTitle, p.LongTitle}
+	for key, text := range p.Labels {
+		if IsReservedLabel(key) {
+			return errors.Errorf("payload configuration uses reserved label %q", key)
+		}
+		texts = append(texts, text)
+	}
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		if _, err := template.New(
+			"template",
+		).Funcs(sprig.TxtFuncMap()).Parse(text); err != nil {
+			return errors.Wrap(err, "error parsing payload template")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package slack
+
+// Policy encapsulates rules governing who may invoke a Slack App's slash
+// commands and where they may invoke them. A request is permitted if it does
+// not match the Deny rule and, if an Allow rule is specified, it matches the
+// Allow rule.
+type Policy struct {
+	// Allow optionally specifies a rule that requests must match in order to be
+	// permitted. If not specified, all requests not matching the Deny rule are
+	// permitted.
+	Allow *PolicyRule `json:"allow,omitempty"`
+	// Deny optionally specifies a rule that, if matched, causes a request to be
+	// denied, regardless of the Allow rule.
+	Deny *PolicyRule `json:"deny,omitempty"`
+}
+
+// PolicyRule encapsulates criteria for matching requests. Criteria that are
+// left empty are ignored.
+type PolicyRule struct {
+	// EnterpriseIDs enumerates Enterprise Grid organizations. e.g. E0001
+	EnterpriseIDs []string `json:"enterpriseIDs,omitempty"`
+	// TeamIDs enumerates workspaces. e.g. T0001
+	TeamIDs []string `json:"teamIDs,omitempty"`
+	// ChannelIDs enumerates channels. e.g. C2147483705
+	ChannelIDs []string `json:"channelIDs,omitempty"`
+	// UserIDs enumerates users. e.g. U2147483697
+	UserIDs []string `json:"userIDs,omitempty"`
+	// UserGroupIDs enumerates user groups. A user matches if they are a member
+	// of any of these. e.g. S0614TZR7
+	UserGroupIDs []string `json:"userGroupIDs,omitempty"`
+}
+
+// Permits returns a bool indicating whether the Policy permits a request from
+// the specified user in the specified channel, team, and (for Enterprise Grid)
+// organization. Group membership is
+// determined using the provided function, which is only invoked as needed.
+//
+// Every non-empty criterion of the Allow rule must be matched for a request to
+// be allowed, except that UserIDs and UserGroupIDs are considered together: a
+// user who is listed in UserIDs OR who is a member of any group listed in
+// UserGroupIDs matches. Conversely, matching ANY criterion of the Deny rule
+// causes a request to be denied.
+func (p Policy) Permits(
+	enterpriseID string,
+	teamID string,
+	channelID string,
+	userID string,
+	isMember func(userGroupID string) bool,
+) bool {
+	if p.Deny != nil {
+		if contains(p.Deny.EnterpriseIDs, enterpriseID) ||
+			contains(p.Deny.TeamIDs, teamID) ||
+			contains(p.Deny.ChannelIDs, channelID) ||
+			contains(p.Deny.UserIDs, userID) ||
+			p.Deny.hasMember(isMember) {
+			return false
+		}
+	}
+	if p.Allow == nil {
+		return true
+	}
+	if len(p.Allow.EnterpriseIDs) > 0 &&
+		!contains(p.Allow.EnterpriseIDs, enterpriseID) {
+		return false
+	}
+	if len(p.Allow.TeamIDs) > 0 && !contains(p.Allow.TeamIDs, teamID) {
+		return false
+	}
+	if len(p.Allow.ChannelIDs) > 0 &&
+		!contains(p.Allow.ChannelIDs, channelID) {
+		return false
+	}
+	if len(p.Allow.UserIDs) == 0 && len(p.Allow.UserGroupIDs) == 0 {
+		return true
+	}
+	return contains(p.Allow.UserIDs, userID) || p.Allow.hasMember(isMember)
+}
+
+// hasMember returns a bool indicating whether the provided function reports
+// membership in any of the PolicyRule's user groups.
+func (p PolicyRule) hasMember(isMember func(userGroupID string) bool) bool {
+	for _, userGroupID := range p.UserGroupIDs {
+		if isMember(userGroupID) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns a bool indicating whether the provided slice contains the
+// provided value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
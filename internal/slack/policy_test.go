@@ -0,0 +1,149 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyPermits(t *testing.T) {
+	// ops is the only user group anyone is a member of
+	isMember := func(userGroupID string) bool {
+		return userGroupID == "ops"
+	}
+	testCases := []struct {
+		name     string
+		policy   Policy
+		expected bool
+	}{
+		{
+			name:     "empty policy",
+			policy:   Policy{},
+			expected: true,
+		},
+		{
+			name: "denied by team",
+			policy: Policy{
+				Deny: &PolicyRule{TeamIDs: []string{"control"}},
+			},
+			expected: false,
+		},
+		{
+			name: "denied by enterprise",
+			policy: Policy{
+				Deny: &PolicyRule{EnterpriseIDs: []string{"net"}},
+			},
+			expected: false,
+		},
+		{
+			name: "denied by user group",
+			policy: Policy{
+				Deny: &PolicyRule{UserGroupIDs: []string{"interns", "ops"}},
+			},
+			expected: false,
+		},
+		{
+			name: "not denied",
+			policy: Policy{
+				Deny: &PolicyRule{
+					TeamIDs:      []string{"kaos"},
+					ChannelIDs:   []string{"random"},
+					UserIDs:      []string{"99"},
+					UserGroupIDs: []string{"interns"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "allowed team and channel",
+			policy: Policy{
+				Allow: &PolicyRule{
+					TeamIDs:    []string{"control"},
+					ChannelIDs: []string{"cone-of-silence"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "allowed enterprise",
+			policy: Policy{
+				Allow: &PolicyRule{EnterpriseIDs: []string{"net"}},
+			},
+			expected: true,
+		},
+		{
+			name: "enterprise not allowed",
+			policy: Policy{
+				Allow: &PolicyRule{EnterpriseIDs: []string{"kaos"}},
+			},
+			expected: false,
+		},
+		{
+			name: "channel not allowed",
+			policy: Policy{
+				Allow: &PolicyRule{
+					TeamIDs:    []string{"control"},
+					ChannelIDs: []string{"random"},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "allowed by user ID",
+			policy: Policy{
+				Allow: &PolicyRule{
+					UserIDs:      []string{"86"},
+					UserGroupIDs: []string{"interns"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "allowed by user group",
+			policy: Policy{
+				Allow: &PolicyRule{
+					UserIDs:      []string{"99"},
+					UserGroupIDs: []string{"ops"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "user not allowed",
+			policy: Policy{
+				Allow: &PolicyRule{
+					UserIDs:      []string{"99"},
+					UserGroupIDs: []string{"interns"},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "allowed but also denied",
+			policy: Policy{
+				Allow: &PolicyRule{
+					UserGroupIDs: []string{"ops"},
+				},
+				Deny: &PolicyRule{
+					ChannelIDs: []string{"cone-of-silence"},
+				},
+			},
+			expected: false,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(
+				t,
+				testCase.expected,
+				testCase.policy.Permits(
+					"net",
+					"control",
+					"cone-of-silence",
+					"86",
+					isMember,
+				),
+			)
+		})
+	}
+}
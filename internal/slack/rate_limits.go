@@ -0,0 +1,37 @@
+package slack
+
+import "math"
+
+// RateLimits encapsulates configuration for limiting the rate at which slash
+// commands are accepted. Each limit is enforced independently using a token
+// bucket.
+type RateLimits struct {
+	// Total limits the rate of ALL requests, regardless of who made them or
+	// where.
+	Total *RateLimit `json:"total,omitempty"`
+	// PerUser limits the rate of requests from each individual user.
+	PerUser *RateLimit `json:"perUser,omitempty"`
+	// PerChannel limits the rate of requests from each individual channel.
+	PerChannel *RateLimit `json:"perChannel,omitempty"`
+}
+
+// RateLimit encapsulates configuration for a single token bucket.
+type RateLimit struct {
+	// RequestsPerMinute is the rate at which the bucket is refilled.
+	RequestsPerMinute float64 `json:"requestsPerMinute"`
+	// Burst is the capacity of the bucket, i.e. the number of requests that may
+	// be accepted in rapid succession. If not specified, RequestsPerMinute,
+	// rounded up, is used.
+	Burst int `json:"burst,omitempty"`
+}
+
+// BucketSize returns the capacity of the bucket, which is never less than one.
+func (r RateLimit) BucketSize() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	if burst := int(math.Ceil(r.RequestsPerMinute)); burst > 1 {
+		return burst
+	}
+	return 1
+}
@@ -0,0 +1,39 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitBucketSize(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rateLimit RateLimit
+		expected  int
+	}{
+		{
+			name: "burst specified",
+			rateLimit: RateLimit{
+				RequestsPerMinute: 10,
+				Burst:             3,
+			},
+			expected: 3,
+		},
+		{
+			name:      "burst not specified",
+			rateLimit: RateLimit{RequestsPerMinute: 2.5},
+			expected:  3,
+		},
+		{
+			name:      "slow rate",
+			rateLimit: RateLimit{RequestsPerMinute: 0.5},
+			expected:  1,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(t, testCase.expected, testCase.rateLimit.BucketSize())
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package testing
+
+import (
+	"context"
+)
+
+type MockAPIClient struct {
+	CallFn func(
+		ctx context.Context,
+		token string,
+		method string,
+		args interface{},
+		result interface{},
+	) error
+	RespondFn func(ctx context.Context, responseURL string, message []byte) error
+}
+
+func (m *MockAPIClient) Call(
+	ctx context.Context,
+	token string,
+	method string,
+	args interface{},
+	result interface{},
+) error {
+	return m.CallFn(ctx, token, method, args, result)
+}
+
+func (m *MockAPIClient) Respond(
+	ctx context.Context,
+	responseURL string,
+	message []byte,
+) error {
+	return m.RespondFn(ctx, responseURL, message)
+}
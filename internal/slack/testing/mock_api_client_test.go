@@ -0,0 +1,12 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockAPIClient(t *testing.T) {
+	require.Implements(t, (*slack.APIClient)(nil), &MockAPIClient{})
+}
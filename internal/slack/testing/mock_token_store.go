@@ -0,0 +1,33 @@
+package testing
+
+import (
+	"context"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+)
+
+type MockTokenStore struct {
+	PutFn func(context.Context, slack.Installation) error
+	GetFn func(
+		ctx context.Context,
+		appID string,
+		enterpriseID string,
+		teamID string,
+	) (slack.Installation, bool, error)
+}
+
+func (m *MockTokenStore) Put(
+	ctx context.Context,
+	installation slack.Installation,
+) error {
+	return m.PutFn(ctx, installation)
+}
+
+func (m *MockTokenStore) Get(
+	ctx context.Context,
+	appID string,
+	enterpriseID string,
+	teamID string,
+) (slack.Installation, bool, error) {
+	return m.GetFn(ctx, appID, enterpriseID, teamID)
+}
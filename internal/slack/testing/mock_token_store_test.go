@@ -0,0 +1,12 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockTokenStore(t *testing.T) {
+	require.Implements(t, (*slack.TokenStore)(nil), &MockTokenStore{})
+}
@@ -0,0 +1,289 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Installation encapsulates the details of a single installation of a Slack App
+// into a Slack workspace (team) or, for Enterprise Grid, an organization
+// (enterprise), as obtained via Slack's OAuth v2 flow.
+type Installation struct {
+	// AppID is the ID of the Slack App that was installed.
+	AppID string `json:"appID"`
+	// EnterpriseID is the ID of the Enterprise Grid organization the App was
+	// installed into, if any.
+	EnterpriseID string `json:"enterpriseID,omitempty"`
+	// TeamID is the ID of the workspace the App was installed into.
+	TeamID string `json:"teamID,omitempty"`
+	// BotToken is the bot token that was granted to the App by the
+	// installation.
+	BotToken string `json:"botToken"`
+	// BotUserID is the ID of the App's bot user in the workspace.
+	BotUserID string `json:"botUserID,omitempty"`
+	// Scope is a comma-delimited list of the scopes that were granted.
+	Scope string `json:"scope,omitempty"`
+}
+
+// key returns a key that uniquely identifies the Installation. It contains only
+// characters that are valid in the keys of a Kubernetes Secret.
+func (i Installation) key() string {
+	return installationKey(i.AppID, i.EnterpriseID, i.TeamID)
+}
+
+func installationKey(appID, enterpriseID, teamID string) string {
+	parts := []string{appID, enterpriseID, teamID}
+	for i, part := range parts {
+		if part == "" {
+			parts[i] = "-"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// TokenStore is an interface for components that can store and retrieve the
+// bot tokens granted when Slack Apps are installed into workspaces.
+type TokenStore interface {
+	// Put stores the provided Installation, replacing any existing Installation
+	// of the same App into the same workspace or organization.
+	Put(context.Context, Installation) error
+	// Get returns the Installation of the specified App into the specified
+	// workspace, along with a bool indicating whether any was found. If the App
+	// has not been installed into the workspace itself, but has been installed
+	// into the Enterprise Grid organization it belongs to, that Installation is
+	// returned instead.
+	Get(
+		ctx context.Context,
+		appID string,
+		enterpriseID string,
+		teamID string,
+	) (Installation, bool, error)
+}
+
+// getInstallation returns the Installation of the specified App into the
+// specified workspace, falling back to an Installation into the organization it
+// belongs to, from among the provided Installations, which are indexed by key.
+func getInstallation(
+	installations map[string]Installation,
+	appID string,
+	enterpriseID string,
+	teamID string,
+) (Installation, bool) {
+	if installation, ok :=
+		installations[installationKey(appID, enterpriseID, teamID)]; ok {
+		return installation, true
+	}
+	if enterpriseID != "" {
+		if installation, ok :=
+			installations[installationKey(appID, enterpriseID, "")]; ok {
+			return installation, true
+		}
+	}
+	return Installation{}, false
+}
+
+// AppForTeam returns the provided App configuration, modified to use the bot
+// token that applies to the specified workspace. A token granted by installing
+// the App into the workspace itself takes precedence, followed by a token
+// granted by installing the App into the Enterprise Grid organization it
+// belongs to, followed by an org-wide token configured for that organization.
+// If the provided TokenStore is nil, only configured tokens are considered. If
+// no token applies, the App configuration is returned unmodified.
+func AppForTeam(
+	ctx context.Context,
+	store TokenStore,
+	app App,
+	enterpriseID string,
+	teamID string,
+) (App, error) {
+	if enterprise, ok := app.Enterprise(enterpriseID); ok &&
+		enterprise.APIToken != "" {
+		app.APIToken = enterprise.APIToken
+		app.SecondaryAPIToken = ""
+	}
+	if store == nil || (enterpriseID == "" && teamID == "") {
+		return app, nil
+	}
+	installation, ok, err := store.Get(ctx, app.AppID, enterpriseID, teamID)
+	if err != nil {
+		return app, errors.Wrapf(
+			err,
+			"error getting installation of app %q for team %q",
+			app.AppID,
+			teamID,
+		)
+	}
+	if ok && installation.BotToken != "" {
+		app.APIToken = installation.BotToken
+		app.SecondaryAPIToken = ""
+	}
+	return app, nil
+}
+
+// fileTokenStore is an implementation of the TokenStore interface that stores
+// Installations in a JSON file.
+type fileTokenStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileTokenStore returns an implementation of the TokenStore interface that
+// stores Installations in the JSON file at the specified path. The file is
+// created if it does not exist. It is re-read on every lookup, so it may be
+// shared with other processes that only read from it.
+func NewFileTokenStore(path string) TokenStore {
+	return &fileTokenStore{
+		path: path,
+	}
+}
+
+func (f *fileTokenStore) Put(
+	_ context.Context,
+	installation Installation,
+) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	installations, err := f.read()
+	if err != nil {
+		return err
+	}
+	installations[installation.key()] = installation
+	list := make([]Installation, 0, len(installations))
+	for _, installation := range installations {
+		list = append(list, installation)
+	}
+	listBytes, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling installations")
+	}
+	// Write to a temporary file and rename it so that readers never see a
+	// partially written file.
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), ".installations-*")
+	if err != nil {
+		return errors.Wrap(err, "error creating temporary installations file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(listBytes); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "error writing installations")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "error writing installations")
+	}
+	return errors.Wrapf(
+		os.Rename(tmp.Name(), f.path),
+		"error writing installations to %s",
+		f.path,
+	)
+}
+
+func (f *fileTokenStore) Get(
+	_ context.Context,
+	appID string,
+	enterpriseID string,
+	teamID string,
+) (Installation, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	installations, err := f.read()
+	if err != nil {
+		return Installation{}, false, err
+	}
+	installation, ok :=
+		getInstallation(installations, appID, enterpriseID, teamID)
+	return installation, ok, nil
+}
+
+// read returns all Installations in the file, indexed by key. A file that does
+// not exist is treated as empty.
+func (f *fileTokenStore) read() (map[string]Installation, error) {
+	installations := map[string]Installation{}
+	listBytes, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return installations, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading installations from %s", f.path)
+	}
+	list := []Installation{}
+	if err = json.Unmarshal(listBytes, &list); err != nil {
+		return nil,
+			errors.Wrapf(err, "error unmarshaling installations from %s", f.path)
+	}
+	for _, installation := range list {
+		installations[installation.key()] = installation
+	}
+	return installations, nil
+}
+
+// SecretClient is an interface for components that can read and write the
+// data of a single Kubernetes Secret (or anything resembling one).
+type SecretClient interface {
+	// GetData returns all of the Secret's data, indexed by key. A Secret that
+	// does not exist is treated as empty.
+	GetData(context.Context) (map[string][]byte, error)
+	// SetData adds the provided key and value to the Secret's data, replacing
+	// any existing value for the key, without disturbing any other keys.
+	SetData(ctx context.Context, key string, value []byte) error
+}
+
+// secretTokenStore is an implementation of the TokenStore interface that stores
+// each Installation under its own key in a Kubernetes Secret.
+type secretTokenStore struct {
+	client SecretClient
+}
+
+// NewSecretTokenStore returns an implementation of the TokenStore interface
+// that stores each Installation, JSON-encoded, under its own key in the
+// Kubernetes Secret accessed by the provided SecretClient.
+func NewSecretTokenStore(client SecretClient) TokenStore {
+	return &secretTokenStore{
+		client: client,
+	}
+}
+
+func (s *secretTokenStore) Put(
+	ctx context.Context,
+	installation Installation,
+) error {
+	installationBytes, err := json.Marshal(installation)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling installation")
+	}
+	return errors.Wrap(
+		s.client.SetData(ctx, installation.key(), installationBytes),
+		"error storing installation",
+	)
+}
+
+func (s *secretTokenStore) Get(
+	ctx context.Context,
+	appID string,
+	enterpriseID string,
+	teamID string,
+) (Installation, bool, error) {
+	data, err := s.client.GetData(ctx)
+	if err != nil {
+		return Installation{}, false,
+			errors.Wrap(err, "error getting installations")
+	}
+	installations := map[string]Installation{}
+	for key, value := range data {
+		installation := Installation{}
+		if err = json.Unmarshal(value, &installation); err != nil {
+			return Installation{}, false,
+				errors.Wrapf(err, "error unmarshaling installation %q", key)
+		}
+		installations[key] = installation
+	}
+	installation, ok :=
+		getInstallation(installations, appID, enterpriseID, teamID)
+	return installation, ok, nil
+}
@@ -0,0 +1,70 @@
+package slack
+
+import "github.com/pkg/errors"
+
+const (
+	// VisibilityInChannel indicates that a message should be visible to everyone
+	// in the channel where a slash command was invoked. This is the default.
+	VisibilityInChannel = "inChannel"
+	// VisibilityEphemeral indicates that a message should be visible only to the
+	// user who invoked a slash command, and only in the channel where they
+	// invoked it.
+	VisibilityEphemeral = "ephemeral"
+	// VisibilityDM indicates that a message should be sent to the user who
+	// invoked a slash command as a direct message from the Slack App. This is
+	// only applicable to status reports.
+	VisibilityDM = "dm"
+)
+
+// Visibility encapsulates configuration for who can see the messages this
+// gateway sends in response to slash commands.
+type Visibility struct {
+	// Ack specifies the visibility of the acknowledgement that is sent
+	// immediately after a slash command is handled. Valid values are
+	// "inChannel" (the default) and "ephemeral".
+	Ack string `json:"ack,omitempty"`
+	// Status specifies the visibility of the status report that is sent once
+	// a resulting event's worker has reached a terminal phase. Valid values are
+	// "inChannel" (the default), "ephemeral", and "dm".
+	Status string `json:"status,omitempty"`
+}
+
+// merge returns a copy of the Visibility with any fields that are set in the
+// provided Visibility overridden.
+func (v Visibility) merge(override Visibility) Visibility {
+	if override.Ack != "" {
+		v.Ack = override.Ack
+	}
+	if override.Status != "" {
+		v.Status = override.Status
+	}
+	return v
+}
+
+// Validate returns an error if the Visibility specifies any value that isn't
+// valid for the kind of message it applies to. Without this, unrecognized
+// values would silently result in messages being visible to the whole channel.
+func (v Visibility) Validate() error {
+	switch v.Ack {
+	case "", VisibilityInChannel, VisibilityEphemeral:
+	default:
+		return errors.Errorf(
+			"invalid ack visibility %q; must be %q or %q",
+			v.Ack,
+			VisibilityInChannel,
+			VisibilityEphemeral,
+		)
+	}
+	switch v.Status {
+	case "", VisibilityInChannel, VisibilityEphemeral, VisibilityDM:
+	default:
+		return errors.Errorf(
+			"invalid status visibility %q; must be %q, %q, or %q",
+			v.Status,
+			VisibilityInChannel,
+			VisibilityEphemeral,
+			VisibilityDM,
+		)
+	}
+	return nil
+}
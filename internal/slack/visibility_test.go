@@ -0,0 +1,62 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisibilityValidate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		visibility Visibility
+		assertions func(error)
+	}{
+		{
+			name: "unspecified",
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "valid",
+			visibility: Visibility{
+				Ack:    VisibilityEphemeral,
+				Status: VisibilityDM,
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:       "invalid ack",
+			visibility: Visibility{Ack: "Ephemeral"},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), `invalid ack visibility "Ephemeral"`)
+			},
+		},
+		{
+			// Status reports can be sent as DMs, but acknowledgements can't
+			name:       "dm ack",
+			visibility: Visibility{Ack: VisibilityDM},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), `invalid ack visibility "dm"`)
+			},
+		},
+		{
+			name:       "invalid status",
+			visibility: Visibility{Status: "private"},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), `invalid status visibility "private"`)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.assertions(testCase.visibility.Validate())
+		})
+	}
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
-	"encoding/json"
 	"io/ioutil"
+	"strings"
 	"time"
 
-	"github.com/brigadecore/brigade-foundations/file"
+	"github.com/brigadecore/brigade-foundations/http"
 	"github.com/brigadecore/brigade-foundations/os"
+	"github.com/brigadecore/brigade-slack-gateway/internal/brigade"
+	"github.com/brigadecore/brigade-slack-gateway/internal/reload"
 	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
 	"github.com/brigadecore/brigade/sdk/v3/restmachinery"
 	"github.com/pkg/errors"
@@ -20,7 +22,7 @@ func apiClientConfig() (string, string, restmachinery.APIClientOptions, error) {
 	if err != nil {
 		return address, "", opts, err
 	}
-	token, err := os.GetRequiredEnvVar("API_TOKEN")
+	token, err := apiToken()
 	if err != nil {
 		return address, token, opts, err
 	}
@@ -29,36 +31,134 @@ func apiClientConfig() (string, string, restmachinery.APIClientOptions, error) {
 	return address, token, opts, err
 }
 
+// apiToken returns the Brigade API token. If the API_TOKEN_PATH environment
+// variable is set, the token is read from the file it indicates, which permits
+// the token to be reloaded when that file changes. Otherwise, it is read from
+// the API_TOKEN environment variable.
+func apiToken() (string, error) {
+	tokenPath := os.GetEnvVar("API_TOKEN_PATH", "")
+	if tokenPath == "" {
+		return os.GetRequiredEnvVar("API_TOKEN")
+	}
+	tokenBytes, err := ioutil.ReadFile(tokenPath)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading API token")
+	}
+	token := strings.TrimSpace(string(tokenBytes))
+	if token == "" {
+		return "", errors.Errorf("file %s does not contain an API token", tokenPath)
+	}
+	return token, nil
+}
+
+// slackApps loads Slack App configurations from the file indicated by the
+// SLACK_APPS_PATH environment variable and returns them indexed by App ID.
+func slackApps() (map[string]slack.App, error) {
+	slackAppsPath, err := os.GetRequiredEnvVar("SLACK_APPS_PATH")
+	if err != nil {
+		return nil, err
+	}
+	return slack.LoadApps(slackAppsPath)
+}
+
+// tokenStore returns the store of bot tokens granted when Slack Apps were
+// installed into workspaces using OAuth, as indicated by environment
+// variables. If no token store is configured, nil is returned.
+func tokenStore() (slack.TokenStore, error) {
+	switch backend := os.GetEnvVar("TOKEN_STORE_BACKEND", ""); backend {
+	case "":
+		return nil, nil
+	case "file":
+		path, err := os.GetRequiredEnvVar("TOKEN_STORE_PATH")
+		if err != nil {
+			return nil, err
+		}
+		return slack.NewFileTokenStore(path), nil
+	case "secret":
+		name, err := os.GetRequiredEnvVar("TOKEN_STORE_SECRET_NAME")
+		if err != nil {
+			return nil, err
+		}
+		client, err := slack.NewKubernetesSecretClient(name)
+		if err != nil {
+			return nil, err
+		}
+		return slack.NewSecretTokenStore(client), nil
+	default:
+		return nil, errors.Errorf(
+			"invalid value %q for TOKEN_STORE_BACKEND; must be \"file\" or "+
+				"\"secret\"",
+			backend,
+		)
+	}
+}
+
 // getMonitorConfig populates configuration for the monitor from environment
 // variables.
 func getMonitorConfig() (monitorConfig, error) {
 	config := monitorConfig{
 		healthcheckInterval: 30 * time.Second,
-		slackApps:           map[string]slack.App{},
 	}
-	slackAppsPath, err := os.GetRequiredEnvVar("SLACK_APPS_PATH")
+	apps, err := slackApps()
 	if err != nil {
 		return config, err
 	}
-	var exists bool
-	if exists, err = file.Exists(slackAppsPath); err != nil {
+	config.slackApps = slack.NewApps(apps)
+	if config.listEventsInterval, err = os.GetDurationFromEnvVar(
+		"LIST_EVENTS_INTERVAL",
+		30*time.Second,
+	); err != nil {
 		return config, err
 	}
-	if !exists {
-		return config, errors.Errorf("file %s does not exist", slackAppsPath)
-	}
-	slackAppsBytes, err := ioutil.ReadFile(slackAppsPath)
+	config.tokenStore, err = tokenStore()
+	return config, err
+}
+
+// configWatcher returns a reload.Watcher that invokes the provided function
+// whenever the files that Slack App configurations and the Brigade API token
+// are loaded from, as indicated by environment variables, change.
+func configWatcher(reloadFn func() error) (*reload.Watcher, error) {
+	slackAppsPath, err := os.GetRequiredEnvVar("SLACK_APPS_PATH")
 	if err != nil {
-		return config, err
+		return nil, err
 	}
-	slackApps := []slack.App{}
-	if err = json.Unmarshal(slackAppsBytes, &slackApps); err != nil {
-		return config, err
+	paths := []string{slackAppsPath}
+	if tokenPath := os.GetEnvVar("API_TOKEN_PATH", ""); tokenPath != "" {
+		paths = append(paths, tokenPath)
 	}
-	for _, slackApp := range slackApps {
-		config.slackApps[slackApp.AppID] = slackApp
+	interval, err :=
+		os.GetDurationFromEnvVar("CONFIG_RELOAD_INTERVAL", 10*time.Second)
+	if err != nil {
+		return nil, err
 	}
-	config.listEventsInterval, err =
-		os.GetDurationFromEnvVar("LIST_EVENTS_INTERVAL", 30*time.Second)
+	return reload.NewWatcher(paths, interval, reloadFn), nil
+}
+
+// reloadConfig returns a function, suitable for use with configWatcher, that
+// reloads Slack App configurations and Brigade API client configuration and
+// swaps them into use. Nothing is swapped in unless all of it has been loaded
+// and validated. Otherwise, the last good configuration remains in use.
+func reloadConfig(apps *slack.Apps, clients *brigade.Clients) func() error {
+	return func() error {
+		loadedApps, err := slackApps()
+		if err != nil {
+			return err
+		}
+		address, token, opts, err := apiClientConfig()
+		if err != nil {
+			return err
+		}
+		apps.Replace(loadedApps)
+		clients.Replace(address, token, opts)
+		return nil
+	}
+}
+
+// serverConfig populates configuration for the HTTP server that exposes the
+// monitor's metrics from environment variables.
+func serverConfig() (http.ServerConfig, error) {
+	config := http.ServerConfig{}
+	var err error
+	config.Port, err = os.GetIntFromEnvVar("PORT", 8080)
 	return config, err
 }
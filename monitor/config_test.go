@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/brigadecore/brigade-slack-gateway/internal/brigade"
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
 	"github.com/brigadecore/brigade/sdk/v3/restmachinery"
 	"github.com/stretchr/testify/require"
 )
@@ -74,6 +76,41 @@ func TestAPIClientConfig(t *testing.T) {
 				require.True(t, opts.AllowInsecureConnections)
 			},
 		},
+		{
+			name: "API_TOKEN_PATH path does not exist",
+			setup: func() {
+				t.Setenv("API_TOKEN_PATH", "/completely/bogus/path")
+			},
+			assertions: func(
+				_ string,
+				_ string,
+				_ restmachinery.APIClientOptions,
+				err error,
+			) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error reading API token")
+			},
+		},
+		{
+			name: "success with API_TOKEN_PATH",
+			setup: func() {
+				tokenFile, err := ioutil.TempFile("", "token")
+				require.NoError(t, err)
+				defer tokenFile.Close()
+				_, err = tokenFile.Write([]byte("baz\n"))
+				require.NoError(t, err)
+				t.Setenv("API_TOKEN_PATH", tokenFile.Name())
+			},
+			assertions: func(
+				_ string,
+				token string,
+				_ restmachinery.APIClientOptions,
+				err error,
+			) {
+				require.NoError(t, err)
+				require.Equal(t, "baz", token)
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -152,6 +189,28 @@ func TestGetMonitorConfig(t *testing.T) {
 				require.Contains(t, err.Error(), "was not parsable as a duration")
 			},
 		},
+		{
+			name: "invalid TOKEN_STORE_BACKEND",
+			setup: func() {
+				t.Setenv("LIST_EVENTS_INTERVAL", "1m")
+				t.Setenv("TOKEN_STORE_BACKEND", "foo")
+			},
+			assertions: func(_ monitorConfig, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "TOKEN_STORE_BACKEND")
+			},
+		},
+		{
+			name: "TOKEN_STORE_PATH not set",
+			setup: func() {
+				t.Setenv("TOKEN_STORE_BACKEND", "file")
+			},
+			assertions: func(_ monitorConfig, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "value not found for")
+				require.Contains(t, err.Error(), "TOKEN_STORE_PATH")
+			},
+		},
 		{
 			name: "success",
 			setup: func() {
@@ -163,13 +222,17 @@ func TestGetMonitorConfig(t *testing.T) {
 				require.NoError(t, err)
 				t.Setenv("SLACK_APPS_PATH", appsFile.Name())
 				t.Setenv("LIST_EVENTS_INTERVAL", "1m")
+				t.Setenv("TOKEN_STORE_PATH", "/tmp/installations.json")
 			},
 			assertions: func(cfg monitorConfig, err error) {
 				require.NoError(t, err)
-				require.Len(t, cfg.slackApps, 1)
-				require.Equal(t, "42", cfg.slackApps["42"].AppID)
-				require.Equal(t, "foobar", cfg.slackApps["42"].AppSigningSecret)
+				require.Len(t, cfg.slackApps.All(), 1)
+				app, ok := cfg.slackApps.Get("42")
+				require.True(t, ok)
+				require.Equal(t, "42", app.AppID)
+				require.Equal(t, "foobar", app.AppSigningSecret)
 				require.Equal(t, time.Minute, cfg.listEventsInterval)
+				require.NotNil(t, cfg.tokenStore)
 			},
 		},
 	}
@@ -183,3 +246,71 @@ func TestGetMonitorConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigWatcher(t *testing.T) {
+	reloadFn := func() error { return nil }
+	_, err := configWatcher(reloadFn)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "SLACK_APPS_PATH")
+	t.Setenv("SLACK_APPS_PATH", "/completely/bogus/path")
+	t.Setenv("CONFIG_RELOAD_INTERVAL", "foo")
+	_, err = configWatcher(reloadFn)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as a duration")
+	t.Setenv("CONFIG_RELOAD_INTERVAL", "1m")
+	watcher, err := configWatcher(reloadFn)
+	require.NoError(t, err)
+	require.NotNil(t, watcher)
+}
+
+func TestReloadConfig(t *testing.T) {
+	appsFile, err := ioutil.TempFile("", "apps.json")
+	require.NoError(t, err)
+	defer appsFile.Close()
+	t.Setenv("SLACK_APPS_PATH", appsFile.Name())
+	t.Setenv("API_ADDRESS", "foo")
+	t.Setenv("API_TOKEN", "bar")
+	apps := slack.NewApps(map[string]slack.App{"42": {AppID: "42"}})
+	clients := brigade.NewClients("foo", "bar", restmachinery.APIClientOptions{})
+	reloadFn := reloadConfig(apps, clients)
+	// Invalid configuration is rejected and the previous apps remain in use
+	require.NoError(
+		t,
+		ioutil.WriteFile(
+			appsFile.Name(),
+			[]byte(`[{"appID":"43","visibility":{"status":"private"}}]`),
+			0600,
+		),
+	)
+	err = reloadFn()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid status visibility "private"`)
+	_, ok := apps.Get("42")
+	require.True(t, ok)
+	_, ok = apps.Get("43")
+	require.False(t, ok)
+	// Valid configuration is swapped in
+	require.NoError(
+		t,
+		ioutil.WriteFile(appsFile.Name(), []byte(`[{"appID":"43"}]`), 0600),
+	)
+	require.NoError(t, reloadFn())
+	_, ok = apps.Get("42")
+	require.False(t, ok)
+	_, ok = apps.Get("43")
+	require.True(t, ok)
+}
+
+func TestServerConfig(t *testing.T) {
+	config, err := serverConfig()
+	require.NoError(t, err)
+	require.Equal(t, 8080, config.Port)
+	t.Setenv("PORT", "foo")
+	_, err = serverConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as an int")
+	t.Setenv("PORT", "9090")
+	config, err = serverConfig()
+	require.NoError(t, err)
+	require.Equal(t, 9090, config.Port)
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
 	"github.com/brigadecore/brigade/sdk/v3"
 	"github.com/brigadecore/brigade/sdk/v3/meta"
 	"github.com/pkg/errors"
@@ -17,6 +19,7 @@ func (m *monitor) monitorEvents(ctx context.Context) {
 	ticker := time.NewTicker(m.config.listEventsInterval)
 	defer ticker.Stop()
 	for {
+		start := time.Now()
 		listOpts := &meta.ListOptions{Limit: 100}
 		for {
 			events, err := m.eventsClient.List(
@@ -41,7 +44,12 @@ func (m *monitor) monitorEvents(ctx context.Context) {
 				return
 			}
 			for _, event := range events.Items {
-				if err := m.reportEventStatusFn(event); err != nil {
+				err := m.reportEventStatusFn(event)
+				eventsReportedCounter.WithLabelValues(
+					event.Qualifiers["appID"],
+					outcome(err),
+				).Inc()
+				if err != nil {
 					log.Println(err)
 				}
 			}
@@ -51,6 +59,7 @@ func (m *monitor) monitorEvents(ctx context.Context) {
 				break
 			}
 		}
+		listEventsLoopDurationHistogram.Observe(time.Since(start).Seconds())
 		select {
 		case <-ticker.C:
 		case <-ctx.Done():
@@ -67,7 +76,7 @@ func (m *monitor) reportEventStatus(event sdk.Event) error {
 			event.ID,
 		)
 	}
-	app, ok := m.config.slackApps[appID]
+	app, ok := m.config.slackApps.Get(appID)
 	if !ok {
 		return errors.Errorf(
 			"no configuration found for app ID %q from event %q labels",
@@ -75,14 +84,109 @@ func (m *monitor) reportEventStatus(event sdk.Event) error {
 			event.ID,
 		)
 	}
+	// If the app was installed into the event's workspace, or into the
+	// Enterprise Grid organization it belongs to, using OAuth, the bot token
+	// granted by that installation must be used to post into it.
+	app, err := slack.AppForTeam(
+		context.Background(),
+		m.config.tokenStore,
+		app,
+		eventEnterpriseID(event),
+		event.Labels["teamID"],
+	)
+	if err != nil {
+		return err
+	}
+	tokens := app.APITokens()
+	if len(tokens) == 0 {
+		return errors.Errorf(
+			"no API token configured for app ID %q or installation found for "+
+				"team %q",
+			appID,
+			event.Labels["teamID"],
+		)
+	}
 	buffer, err := m.prepareEventStatusMessageFn(event)
 	if err != nil {
 		return errors.Wrap(err, "error rendering status message for for event %q")
 	}
+	message := buffer.Bytes()
+	method, _, _ := statusDestination(event)
+	// Try each of the app's API tokens in turn, moving on to the next only if
+	// Slack rejects the current one as invalid or revoked. This permits tokens to
+	// be rotated without any status messages being lost.
+	var sendErr error
+	for i, token := range tokens {
+		if sendErr =
+			m.sendStatusMessage(event, method, token, message); sendErr == nil {
+			break
+		}
+		if i < len(tokens)-1 && slack.IsTokenError(sendErr) {
+			log.Printf(
+				"API token rejected for app ID %q; falling back to secondary token: "+
+					"%s",
+				appID,
+				sendErr,
+			)
+			continue
+		}
+		// Errors that may go away on their own, e.g. rate limiting, are returned
+		// so that reporting is retried the next time events are listed. Others,
+		// e.g. the channel having been archived, would recur on every retry, so we
+		// give up on reporting the event's status.
+		if !slack.IsPermanentError(sendErr) {
+			return sendErr
+		}
+		break
+	}
+	if sendErr == nil && event.Worker != nil &&
+		event.Worker.Status.Ended != nil {
+		reportingLagHistogram.Observe(
+			time.Since(*event.Worker.Status.Ended).Seconds(),
+		)
+	}
+	// Blank out the Event's source state to reflect that we're done following
+	// up on it, even if we gave up
+	if err := m.eventsClient.UpdateSourceState(
+		context.Background(),
+		event.ID,
+		sdk.SourceState{},
+		nil,
+	); err != nil {
+		return errors.Wrapf(
+			err,
+			"error clearing source state for event %q",
+			event.ID,
+		)
+	}
+	return errors.Wrap(sendErr, "gave up reporting status")
+}
+
+// eventEnterpriseID returns the ID of the Enterprise Grid organization the
+// provided event originated from, if any. Events emitted by older versions of
+// the receiver carry this in an enterprise_id label instead of an enterpriseID
+// label.
+func eventEnterpriseID(event sdk.Event) string {
+	if enterpriseID, ok := event.Labels["enterpriseID"]; ok {
+		return enterpriseID
+	}
+	return event.Labels["enterprise_id"]
+}
+
+// sendStatusMessage posts the provided, JSON-encoded status message for the
+// provided event to Slack using the specified Slack Web API method and the
+// provided API token. If Slack indicates the message was not posted, a
+// *slack.APIError is returned.
+func (m *monitor) sendStatusMessage(
+	event sdk.Event,
+	method string,
+	token string,
+	message []byte,
+) error {
 	req, err := http.NewRequest(
 		http.MethodPost,
-		"https://slack.com/api/chat.postMessage",
-		buffer,
+		fmt.Sprintf("https://slack.com/api/%s", method),
+		bytes.NewReader(message),
 	)
 	if err != nil {
 		return errors.Wrapf(
@@ -94,7 +198,7 @@ func (m *monitor) reportEventStatus(event sdk.Event) error {
 	req.Header.Add("Content-type", "application/json")
 	req.Header.Add(
 		"Authorization",
-		fmt.Sprintf("Bearer %s", app.APIToken),
+		fmt.Sprintf("Bearer %s", token),
 	)
 	resp, err := m.httpSendFn(req)
 	if err != nil {
@@ -117,34 +221,84 @@ func (m *monitor) reportEventStatus(event sdk.Event) error {
 			resp.StatusCode,
 		)
 	}
-	// Blank out the Event's source state to reflect that we're done following
-	// up on it
-	if err := m.eventsClient.UpdateSourceState(
-		context.Background(),
+	if resp.Body == nil {
+		return nil
+	}
+	// Slack reports most errors, including those pertaining to the API token,
+	// using a 200 status code and an error code in the response body.
+	result := struct {
+		OK    *bool  `json:"ok"`
+		Error string `json:"error"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil ||
+		result.OK == nil || *result.OK {
+		return nil
+	}
+	slackAPIErrorsCounter.WithLabelValues(method, result.Error).Inc()
+	return errors.Wrapf(
+		&slack.APIError{
+			Method: method,
+			Code:   result.Error,
+		},
+		"error sending slack status message for event %q",
 		event.ID,
-		sdk.SourceState{},
-		nil,
-	); err != nil {
-		return errors.Wrapf(
-			err,
-			"error clearing source state for event %q",
-			event.ID,
-		)
+	)
+}
+
+// statusDestination returns the Slack Web API method that should be used to
+// report the provided event's status, along with the channel and (only for
+// ephemeral messages) the user the report should be delivered to. This is
+// determined by the status visibility recorded in the event's source state by
+// the receiver.
+func statusDestination(event sdk.Event) (string, string, string) {
+	var visibility string
+	if event.SourceState != nil {
+		visibility = event.SourceState.State["statusVisibility"]
+	}
+	channelID := event.Labels["channelID"]
+	userID := event.Labels["userID"]
+	if userID == "" {
+		// We can't deliver anything privately if we don't know who to deliver it
+		// to.
+		return "chat.postMessage", channelID, ""
+	}
+	switch visibility {
+	case slack.VisibilityEphemeral:
+		return "chat.postEphemeral", channelID, userID
+	case slack.VisibilityDM:
+		// Posting to a user ID delivers the message as a direct message from the
+		// app's bot user.
+		return "chat.postMessage", userID, ""
+	default:
+		return "chat.postMessage", channelID, ""
 	}
-	return nil
 }
 
 func (m *monitor) prepareEventStatusMessage(
 	event sdk.Event,
 ) (*bytes.Buffer, error) {
+	_, channel, user := statusDestination(event)
 	buffer := &bytes.Buffer{}
-	err := m.statusMsgTemplate.Execute(buffer, event)
+	err := m.statusMsgTemplate.Execute(
+		buffer,
+		struct {
+			sdk.Event
+			Channel string
+			User    string
+		}{
+			Event:   event,
+			Channel: channel,
+			User:    user,
+		},
+	)
 	return buffer, err
 }
 
 var statusMsgTemplate = `{
-  "response_type": "in_channel",
-  "channel": {{ quote .Labels.channelID }},
+  "channel": {{ quote .Channel }},
+  {{- if .User }}
+  "user": {{ quote .User }},
+  {{- end }}
   "blocks": [
     {
       "type": "header",
@@ -1,19 +1,26 @@
 package main
 
+// nolint: lll
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 	"text/template"
 	"time"
 
 	"github.com/Masterminds/sprig"
 	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
 	"github.com/brigadecore/brigade/sdk/v3"
 	"github.com/brigadecore/brigade/sdk/v3/meta"
 	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -63,6 +70,9 @@ func TestMonitorEvents(t *testing.T) {
 									ObjectMeta: meta.ObjectMeta{
 										ID: "tunguska",
 									},
+									Qualifiers: map[string]string{
+										"appID": "42",
+									},
 								},
 							},
 						}, nil
@@ -74,6 +84,12 @@ func TestMonitorEvents(t *testing.T) {
 			},
 			assertions: func(err error) {
 				require.NoError(t, err)
+				require.Positive(
+					t,
+					testutil.ToFloat64(
+						eventsReportedCounter.WithLabelValues("42", outcomeSuccess),
+					),
+				)
 			},
 		},
 	}
@@ -126,12 +142,33 @@ func TestMonitorReportEventStatus(t *testing.T) {
 			},
 		},
 		{
-			name: "error rendering status message",
+			name: "no API token configured for appID",
 			monitor: &monitor{
 				config: monitorConfig{
-					slackApps: map[string]slack.App{
+					slackApps: slack.NewApps(map[string]slack.App{
 						"42": {},
-					},
+					}),
+				},
+			},
+			event: sdk.Event{
+				Qualifiers: map[string]string{
+					"appID": "42",
+				},
+			},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "no API token configured")
+			},
+		},
+		{
+			name: "error rendering status message",
+			monitor: &monitor{
+				config: monitorConfig{
+					slackApps: slack.NewApps(map[string]slack.App{
+						"42": {
+							APIToken: "foo",
+						},
+					}),
 				},
 				prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
 					return nil, errors.New("something went wrong")
@@ -156,9 +193,11 @@ func TestMonitorReportEventStatus(t *testing.T) {
 			name: "error sending message",
 			monitor: &monitor{
 				config: monitorConfig{
-					slackApps: map[string]slack.App{
-						"42": {},
-					},
+					slackApps: slack.NewApps(map[string]slack.App{
+						"42": {
+							APIToken: "foo",
+						},
+					}),
 				},
 				prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
 					return bytes.NewBufferString("this is a status message"), nil
@@ -186,9 +225,11 @@ func TestMonitorReportEventStatus(t *testing.T) {
 			name: "non-200 response when sending message",
 			monitor: &monitor{
 				config: monitorConfig{
-					slackApps: map[string]slack.App{
-						"42": {},
-					},
+					slackApps: slack.NewApps(map[string]slack.App{
+						"42": {
+							APIToken: "foo",
+						},
+					}),
 				},
 				prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
 					return bytes.NewBufferString("this is a status message"), nil
@@ -215,13 +256,144 @@ func TestMonitorReportEventStatus(t *testing.T) {
 			},
 		},
 		{
-			name: "error updating source state",
+			name: "permanent error returned by slack",
 			monitor: &monitor{
 				config: monitorConfig{
-					slackApps: map[string]slack.App{
-						"42": {},
+					slackApps: slack.NewApps(map[string]slack.App{
+						"42": {
+							APIToken:          "foo",
+							SecondaryAPIToken: "bar",
+						},
+					}),
+				},
+				prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
+					return bytes.NewBufferString("this is a status message"), nil
+				},
+				httpSendFn: func(*http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body: ioutil.NopCloser(
+							strings.NewReader(`{"ok":false,"error":"channel_not_found"}`),
+						),
+					}, nil
+				},
+				eventsClient: &sdkTesting.MockEventsClient{
+					// Retrying would be futile, so the event should no longer be tracked
+					UpdateSourceStateFn: func(
+						_ context.Context,
+						id string,
+						sourceState sdk.SourceState,
+						_ *sdk.EventSourceStateUpdateOptions,
+					) error {
+						require.Equal(t, "123", id)
+						require.Empty(t, sourceState.State)
+						return nil
 					},
 				},
+			},
+			event: sdk.Event{
+				ObjectMeta: meta.ObjectMeta{
+					ID: "123",
+				},
+				Qualifiers: map[string]string{
+					"appID": "42",
+				},
+			},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "gave up reporting status")
+				require.Contains(t, err.Error(), "channel_not_found")
+				require.False(t, slack.IsTokenError(err))
+			},
+		},
+		{
+			name: "transient error returned by slack",
+			monitor: &monitor{
+				config: monitorConfig{
+					slackApps: slack.NewApps(map[string]slack.App{
+						"42": {
+							APIToken: "foo",
+						},
+					}),
+				},
+				prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
+					return bytes.NewBufferString("this is a status message"), nil
+				},
+				httpSendFn: func(*http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body: ioutil.NopCloser(
+							strings.NewReader(`{"ok":false,"error":"ratelimited"}`),
+						),
+					}, nil
+				},
+				eventsClient: &sdkTesting.MockEventsClient{
+					// Reporting should be retried, so the event should still be tracked
+					UpdateSourceStateFn: func(
+						context.Context,
+						string,
+						sdk.SourceState,
+						*sdk.EventSourceStateUpdateOptions,
+					) error {
+						require.Fail(t, "source state should not have been updated")
+						return nil
+					},
+				},
+			},
+			event: sdk.Event{
+				Qualifiers: map[string]string{
+					"appID": "42",
+				},
+			},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "ratelimited")
+				require.NotContains(t, err.Error(), "gave up")
+			},
+		},
+		{
+			name: "all API tokens rejected",
+			monitor: &monitor{
+				config: monitorConfig{
+					slackApps: slack.NewApps(map[string]slack.App{
+						"42": {
+							APIToken:          "foo",
+							SecondaryAPIToken: "bar",
+						},
+					}),
+				},
+				prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
+					return bytes.NewBufferString("this is a status message"), nil
+				},
+				httpSendFn: func(*http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body: ioutil.NopCloser(
+							strings.NewReader(`{"ok":false,"error":"token_revoked"}`),
+						),
+					}, nil
+				},
+			},
+			event: sdk.Event{
+				Qualifiers: map[string]string{
+					"appID": "42",
+				},
+			},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.True(t, slack.IsTokenError(err))
+			},
+		},
+		{
+			name: "error updating source state",
+			monitor: &monitor{
+				config: monitorConfig{
+					slackApps: slack.NewApps(map[string]slack.App{
+						"42": {
+							APIToken: "foo",
+						},
+					}),
+				},
 				prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
 					return bytes.NewBufferString("this is a status message"), nil
 				},
@@ -260,9 +432,11 @@ func TestMonitorReportEventStatus(t *testing.T) {
 			name: "success",
 			monitor: &monitor{
 				config: monitorConfig{
-					slackApps: map[string]slack.App{
-						"42": {},
-					},
+					slackApps: slack.NewApps(map[string]slack.App{
+						"42": {
+							APIToken: "foo",
+						},
+					}),
 				},
 				prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
 					return bytes.NewBufferString("this is a status message"), nil
@@ -300,6 +474,233 @@ func TestMonitorReportEventStatus(t *testing.T) {
 	}
 }
 
+func TestMonitorReportEventStatusWithTokenFallback(t *testing.T) {
+	usedTokens := []string{}
+	sourceStateCleared := false
+	errorsCounter := slackAPIErrorsCounter.WithLabelValues(
+		"chat.postMessage",
+		"invalid_auth",
+	)
+	startingErrorCount := testutil.ToFloat64(errorsCounter)
+	lagSampleCount := func() uint64 {
+		metric := &dto.Metric{}
+		require.NoError(t, reportingLagHistogram.Write(metric))
+		return metric.GetHistogram().GetSampleCount()
+	}
+	startingLagSampleCount := lagSampleCount()
+	ended := time.Now().Add(-time.Minute)
+	m := &monitor{
+		config: monitorConfig{
+			slackApps: slack.NewApps(map[string]slack.App{
+				"42": {
+					APIToken:          "foo",
+					SecondaryAPIToken: "bar",
+				},
+			}),
+		},
+		prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
+			return bytes.NewBufferString("this is a status message"), nil
+		},
+		httpSendFn: func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "/api/chat.postMessage", req.URL.Path)
+			token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			usedTokens = append(usedTokens, token)
+			// The message should be sent intact with every attempt
+			body, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			require.Equal(t, "this is a status message", string(body))
+			resBody := `{"ok":true}`
+			if token == "foo" {
+				resBody = `{"ok":false,"error":"invalid_auth"}`
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(resBody)),
+			}, nil
+		},
+		eventsClient: &sdkTesting.MockEventsClient{
+			UpdateSourceStateFn: func(
+				context.Context,
+				string,
+				sdk.SourceState,
+				*sdk.EventSourceStateUpdateOptions,
+			) error {
+				sourceStateCleared = true
+				return nil
+			},
+		},
+	}
+	err := m.reportEventStatus(
+		sdk.Event{
+			Qualifiers: map[string]string{
+				"appID": "42",
+			},
+			Worker: &sdk.Worker{
+				Status: sdk.WorkerStatus{
+					Ended: &ended,
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "bar"}, usedTokens)
+	require.True(t, sourceStateCleared)
+	require.Equal(t, startingErrorCount+1, testutil.ToFloat64(errorsCounter))
+	require.Equal(t, startingLagSampleCount+1, lagSampleCount())
+}
+
+func TestMonitorReportEventStatusWithTokenStore(t *testing.T) {
+	testCases := []struct {
+		name       string
+		tokenStore slack.TokenStore
+		assertions func(usedTokens []string, err error)
+	}{
+		{
+			name: "error getting installation",
+			tokenStore: &slackTesting.MockTokenStore{
+				GetFn: func(
+					context.Context,
+					string,
+					string,
+					string,
+				) (slack.Installation, bool, error) {
+					return slack.Installation{}, false,
+						errors.New("something went wrong")
+				},
+			},
+			assertions: func(usedTokens []string, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "something went wrong")
+				// Nothing should have been sent
+				require.Empty(t, usedTokens)
+			},
+		},
+		{
+			name: "not installed into team",
+			tokenStore: &slackTesting.MockTokenStore{
+				GetFn: func(
+					context.Context,
+					string,
+					string,
+					string,
+				) (slack.Installation, bool, error) {
+					return slack.Installation{}, false, nil
+				},
+			},
+			assertions: func(usedTokens []string, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []string{"foo"}, usedTokens)
+			},
+		},
+		{
+			name: "installed into team",
+			tokenStore: &slackTesting.MockTokenStore{
+				GetFn: func(
+					_ context.Context,
+					appID string,
+					_ string,
+					teamID string,
+				) (slack.Installation, bool, error) {
+					require.Equal(t, "42", appID)
+					require.Equal(t, "control", teamID)
+					return slack.Installation{BotToken: "xoxb-control"}, true, nil
+				},
+			},
+			assertions: func(usedTokens []string, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []string{"xoxb-control"}, usedTokens)
+			},
+		},
+		{
+			name: "installed into organization",
+			tokenStore: &slackTesting.MockTokenStore{
+				GetFn: func(
+					_ context.Context,
+					_ string,
+					enterpriseID string,
+					_ string,
+				) (slack.Installation, bool, error) {
+					require.Equal(t, "net", enterpriseID)
+					return slack.Installation{BotToken: "xoxb-net"}, true, nil
+				},
+			},
+			assertions: func(usedTokens []string, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []string{"xoxb-net"}, usedTokens)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			usedTokens := []string{}
+			m := &monitor{
+				config: monitorConfig{
+					slackApps: slack.NewApps(map[string]slack.App{
+						"42": {
+							AppID:    "42",
+							APIToken: "foo",
+						},
+					}),
+					tokenStore: testCase.tokenStore,
+				},
+				prepareEventStatusMessageFn: func(sdk.Event) (*bytes.Buffer, error) {
+					return bytes.NewBufferString("this is a status message"), nil
+				},
+				httpSendFn: func(req *http.Request) (*http.Response, error) {
+					usedTokens = append(
+						usedTokens,
+						strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "),
+					)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(strings.NewReader(`{"ok":true}`)),
+					}, nil
+				},
+				eventsClient: &sdkTesting.MockEventsClient{
+					UpdateSourceStateFn: func(
+						context.Context,
+						string,
+						sdk.SourceState,
+						*sdk.EventSourceStateUpdateOptions,
+					) error {
+						return nil
+					},
+				},
+			}
+			err := m.reportEventStatus(
+				sdk.Event{
+					Qualifiers: map[string]string{
+						"appID": "42",
+					},
+					Labels: map[string]string{
+						"enterpriseID": "net",
+						"teamID":       "control",
+					},
+				},
+			)
+			testCase.assertions(usedTokens, err)
+		})
+	}
+}
+
+func TestEventEnterpriseID(t *testing.T) {
+	require.Empty(t, eventEnterpriseID(sdk.Event{}))
+	require.Equal(
+		t,
+		"net",
+		eventEnterpriseID(
+			sdk.Event{Labels: map[string]string{"enterpriseID": "net"}},
+		),
+	)
+	require.Equal(
+		t,
+		"net",
+		eventEnterpriseID(
+			sdk.Event{Labels: map[string]string{"enterprise_id": "net"}},
+		),
+	)
+}
+
 func TestMonitorPrepareStatusMessage(t *testing.T) {
 	testEvent := sdk.Event{
 		ObjectMeta: meta.ObjectMeta{
@@ -331,4 +732,98 @@ func TestMonitorPrepareStatusMessage(t *testing.T) {
 	require.Contains(t, buffer.String(), testEvent.ProjectID)
 	require.Contains(t, buffer.String(), testEvent.Worker.Status.Phase)
 	require.Contains(t, buffer.String(), testEvent.Summary)
+	// Ephemeral status reports should be addressed to the user
+	testEvent.Labels["userID"] = "86"
+	testEvent.SourceState = &sdk.SourceState{
+		State: map[string]string{
+			"statusVisibility": slack.VisibilityEphemeral,
+		},
+	}
+	buffer, err = monitor.prepareEventStatusMessage(testEvent)
+	require.NoError(t, err)
+	msg := struct {
+		Channel string `json:"channel"`
+		User    string `json:"user"`
+	}{}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &msg))
+	require.Equal(t, "hbo", msg.Channel)
+	require.Equal(t, "86", msg.User)
+}
+
+func TestStatusDestination(t *testing.T) {
+	testCases := []struct {
+		name            string
+		event           sdk.Event
+		expectedMethod  string
+		expectedChannel string
+		expectedUser    string
+	}{
+		{
+			name: "no status visibility",
+			event: sdk.Event{
+				Labels: map[string]string{
+					"channelID": "hbo",
+					"userID":    "86",
+				},
+			},
+			expectedMethod:  "chat.postMessage",
+			expectedChannel: "hbo",
+		},
+		{
+			name: "ephemeral",
+			event: sdk.Event{
+				Labels: map[string]string{
+					"channelID": "hbo",
+					"userID":    "86",
+				},
+				SourceState: &sdk.SourceState{
+					State: map[string]string{
+						"statusVisibility": slack.VisibilityEphemeral,
+					},
+				},
+			},
+			expectedMethod:  "chat.postEphemeral",
+			expectedChannel: "hbo",
+			expectedUser:    "86",
+		},
+		{
+			name: "dm",
+			event: sdk.Event{
+				Labels: map[string]string{
+					"channelID": "hbo",
+					"userID":    "86",
+				},
+				SourceState: &sdk.SourceState{
+					State: map[string]string{
+						"statusVisibility": slack.VisibilityDM,
+					},
+				},
+			},
+			expectedMethod:  "chat.postMessage",
+			expectedChannel: "86",
+		},
+		{
+			name: "private but user unknown",
+			event: sdk.Event{
+				Labels: map[string]string{
+					"channelID": "hbo",
+				},
+				SourceState: &sdk.SourceState{
+					State: map[string]string{
+						"statusVisibility": slack.VisibilityDM,
+					},
+				},
+			},
+			expectedMethod:  "chat.postMessage",
+			expectedChannel: "hbo",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			method, channel, user := statusDestination(testCase.event)
+			require.Equal(t, testCase.expectedMethod, method)
+			require.Equal(t, testCase.expectedChannel, channel)
+			require.Equal(t, testCase.expectedUser, user)
+		})
+	}
 }
@@ -2,10 +2,15 @@ package main
 
 import (
 	"log"
+	"net/http"
 
+	libHTTP "github.com/brigadecore/brigade-foundations/http"
 	"github.com/brigadecore/brigade-foundations/signals"
 	"github.com/brigadecore/brigade-foundations/version"
-	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade-slack-gateway/internal/brigade"
+	"github.com/brigadecore/brigade-slack-gateway/internal/reload"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -16,30 +21,68 @@ func main() {
 		version.Commit(),
 	)
 
-	// Brigade System and Events API clients
-	var systemClient sdk.SystemClient
-	var eventsClient sdk.EventsClient
+	// Brigade System and Events API clients. These can be replaced while in use
+	// when the file the Brigade API token is loaded from changes.
+	var clients *brigade.Clients
 	{
 		address, token, opts, err := apiClientConfig()
 		if err != nil {
 			log.Fatal(err)
 		}
-		systemClient = sdk.NewSystemClient(address, token, &opts)
-		eventsClient = sdk.NewEventsClient(address, token, &opts)
+		clients = brigade.NewClients(address, token, opts)
+	}
+
+	var config monitorConfig
+	{
+		var err error
+		if config, err = getMonitorConfig(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var watcher *reload.Watcher
+	{
+		var err error
+		if watcher, err = configWatcher(
+			reloadConfig(config.slackApps, clients),
+		); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	var monitor *monitor
 	{
-		config, err := getMonitorConfig()
-		if err != nil {
+		var err error
+		if monitor, err = newMonitor(
+			clients.System(),
+			clients.Events(),
+			config,
+		); err != nil {
 			log.Fatal(err)
 		}
-		monitor, err = newMonitor(systemClient, eventsClient, config)
+	}
+
+	// A small HTTP server exposes the monitor's metrics
+	var server libHTTP.Server
+	{
+		router := mux.NewRouter()
+		router.StrictSlash(true)
+		router.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
+		serverConfig, err := serverConfig()
 		if err != nil {
 			log.Fatal(err)
 		}
+		server = libHTTP.NewServer(router, &serverConfig)
 	}
 
+	ctx := signals.Context()
+
+	go watcher.Run(ctx)
+
+	go func() {
+		log.Println(server.ListenAndServe(ctx))
+	}()
+
 	// Run it!
-	log.Println(monitor.run(signals.Context()))
+	log.Println(monitor.run(ctx))
 }
@@ -0,0 +1,76 @@
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const metricsNamespace = "brigade_slack_gateway"
+
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+var (
+	// listEventsLoopDurationHistogram observes how long each pass over events
+	// whose status should be reported to Slack takes.
+	listEventsLoopDurationHistogram = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "monitor_list_events_loop_duration_seconds",
+			Help: "Time taken to list and report the status of all events whose " +
+				"workers have reached a terminal phase",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+	// eventsReportedCounter counts attempts to report event status to Slack, by
+	// app and outcome.
+	eventsReportedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "monitor_events_reported_total",
+			Help: "Total number of attempts to report event status to Slack, by " +
+				"app and outcome",
+		},
+		[]string{"app_id", "outcome"},
+	)
+	// slackAPIErrorsCounter counts errors returned by the Slack Web API when
+	// reporting event status, by method and error code.
+	slackAPIErrorsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "monitor_slack_api_errors_total",
+			Help: "Total number of errors returned by the Slack Web API when " +
+				"reporting event status, by method and error code",
+		},
+		[]string{"method", "code"},
+	)
+	// reportingLagHistogram observes the time between an event's worker
+	// reaching a terminal phase and the event's status being reported to Slack.
+	reportingLagHistogram = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "monitor_reporting_lag_seconds",
+			Help: "Time between an event's worker ending and the event's status " +
+				"being reported to Slack",
+			// 1s to roughly 34m
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		listEventsLoopDurationHistogram,
+		eventsReportedCounter,
+		slackAPIErrorsCounter,
+		reportingLagHistogram,
+	)
+}
+
+// outcome returns a label value describing the outcome of an operation that
+// returned the provided error.
+func outcome(err error) string {
+	if err != nil {
+		return outcomeError
+	}
+	return outcomeSuccess
+}
@@ -21,7 +21,11 @@ import (
 type monitorConfig struct {
 	healthcheckInterval time.Duration
 	listEventsInterval  time.Duration
-	slackApps           map[string]slack.App
+	// slackApps is the set of Slack App configurations, which may be reloaded.
+	slackApps *slack.Apps
+	// tokenStore is where the bot tokens granted when Slack Apps were installed
+	// into workspaces using OAuth are found. It may be nil.
+	tokenStore slack.TokenStore
 }
 
 // monitor is a component that continuously monitors events that the Brigade
@@ -2,12 +2,14 @@ package main
 
 // nolint: lll
 import (
-	"encoding/json"
 	"io/ioutil"
+	"strings"
+	"time"
 
-	"github.com/brigadecore/brigade-foundations/file"
 	"github.com/brigadecore/brigade-foundations/http"
 	"github.com/brigadecore/brigade-foundations/os"
+	"github.com/brigadecore/brigade-slack-gateway/internal/brigade"
+	"github.com/brigadecore/brigade-slack-gateway/internal/reload"
 	libSlack "github.com/brigadecore/brigade-slack-gateway/internal/slack"
 	"github.com/brigadecore/brigade-slack-gateway/receiver/internal/slack"
 	"github.com/brigadecore/brigade/sdk/v3/restmachinery"
@@ -22,7 +24,7 @@ func apiClientConfig() (string, string, restmachinery.APIClientOptions, error) {
 	if err != nil {
 		return address, "", opts, err
 	}
-	token, err := os.GetRequiredEnvVar("API_TOKEN")
+	token, err := apiToken()
 	if err != nil {
 		return address, token, opts, err
 	}
@@ -31,36 +33,182 @@ func apiClientConfig() (string, string, restmachinery.APIClientOptions, error) {
 	return address, token, opts, err
 }
 
+// apiToken returns the Brigade API token. If the API_TOKEN_PATH environment
+// variable is set, the token is read from the file it indicates, which permits
+// the token to be reloaded when that file changes. Otherwise, it is read from
+// the API_TOKEN environment variable.
+func apiToken() (string, error) {
+	tokenPath := os.GetEnvVar("API_TOKEN_PATH", "")
+	if tokenPath == "" {
+		return os.GetRequiredEnvVar("API_TOKEN")
+	}
+	tokenBytes, err := ioutil.ReadFile(tokenPath)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading API token")
+	}
+	token := strings.TrimSpace(string(tokenBytes))
+	if token == "" {
+		return "", errors.Errorf("file %s does not contain an API token", tokenPath)
+	}
+	return token, nil
+}
+
+// slackApps loads Slack App configurations from the file indicated by the
+// SLACK_APPS_PATH environment variable and returns them indexed by App ID.
+func slackApps() (map[string]libSlack.App, error) {
+	slackAppsPath, err := os.GetRequiredEnvVar("SLACK_APPS_PATH")
+	if err != nil {
+		return nil, err
+	}
+	return libSlack.LoadApps(slackAppsPath)
+}
+
+// tokenStore returns the store of bot tokens granted when Slack Apps were
+// installed into workspaces using OAuth, as indicated by environment
+// variables. If no token store is configured, nil is returned.
+func tokenStore() (libSlack.TokenStore, error) {
+	switch backend := os.GetEnvVar("TOKEN_STORE_BACKEND", ""); backend {
+	case "":
+		return nil, nil
+	case "file":
+		path, err := os.GetRequiredEnvVar("TOKEN_STORE_PATH")
+		if err != nil {
+			return nil, err
+		}
+		return libSlack.NewFileTokenStore(path), nil
+	case "secret":
+		name, err := os.GetRequiredEnvVar("TOKEN_STORE_SECRET_NAME")
+		if err != nil {
+			return nil, err
+		}
+		client, err := libSlack.NewKubernetesSecretClient(name)
+		if err != nil {
+			return nil, err
+		}
+		return libSlack.NewSecretTokenStore(client), nil
+	default:
+		return nil, errors.Errorf(
+			"invalid value %q for TOKEN_STORE_BACKEND; must be \"file\" or "+
+				"\"secret\"",
+			backend,
+		)
+	}
+}
+
+// signatureVerificationFilterConfig populates configuration for the signature
+// verification filter from environment variables.
 func signatureVerificationFilterConfig() (
 	slack.SignatureVerificationFilterConfig,
 	error,
 ) {
-	config := slack.SignatureVerificationFilterConfig{
-		SlackApps: map[string]libSlack.App{},
+	config := slack.SignatureVerificationFilterConfig{}
+	var err error
+	if config.TimestampTolerance, err = os.GetDurationFromEnvVar(
+		"SIGNATURE_TIMESTAMP_TOLERANCE",
+		5*time.Minute,
+	); err != nil {
+		return config, err
 	}
-	slackAppsPath, err := os.GetRequiredEnvVar("SLACK_APPS_PATH")
-	if err != nil {
+	maxRequestBodyBytes, err :=
+		os.GetIntFromEnvVar("MAX_REQUEST_BODY_BYTES", 1<<20)
+	config.MaxRequestBodyBytes = int64(maxRequestBodyBytes)
+	return config, err
+}
+
+// slashCommandServiceConfig populates configuration for the slash command
+// service from environment variables.
+func slashCommandServiceConfig() (slack.SlashCommandServiceConfig, error) {
+	config := slack.SlashCommandServiceConfig{}
+	var err error
+	if config.AsyncAck, err =
+		os.GetBoolFromEnvVar("ASYNC_ACK", false); err != nil {
 		return config, err
 	}
-	var exists bool
-	if exists, err = file.Exists(slackAppsPath); err != nil {
+	if config.UserGroupCacheTTL, err = os.GetDurationFromEnvVar(
+		"USER_GROUP_CACHE_TTL",
+		5*time.Minute,
+	); err != nil {
 		return config, err
 	}
-	if !exists {
-		return config, errors.Errorf("file %s does not exist", slackAppsPath)
+	if config.EnrichmentCacheTTL, err = os.GetDurationFromEnvVar(
+		"ENRICHMENT_CACHE_TTL",
+		5*time.Minute,
+	); err != nil {
+		return config, err
 	}
-	slackAppsBytes, err := ioutil.ReadFile(slackAppsPath)
+	config.ConfirmationTimeout, err =
+		os.GetDurationFromEnvVar("CONFIRMATION_TIMEOUT", 5*time.Minute)
+	return config, err
+}
+
+// interactionServiceConfig populates configuration for the interaction service
+// from environment variables.
+func interactionServiceConfig() (slack.InteractionServiceConfig, error) {
+	config := slack.InteractionServiceConfig{}
+	var err error
+	config.UserGroupCacheTTL, err =
+		os.GetDurationFromEnvVar("USER_GROUP_CACHE_TTL", 5*time.Minute)
+	return config, err
+}
+
+// eventsAPIServiceConfig populates configuration for the Events API service
+// from environment variables.
+func eventsAPIServiceConfig() (slack.EventsAPIServiceConfig, error) {
+	config := slack.EventsAPIServiceConfig{}
+	var err error
+	config.UserGroupCacheTTL, err =
+		os.GetDurationFromEnvVar("USER_GROUP_CACHE_TTL", 5*time.Minute)
+	return config, err
+}
+
+// deduplicationConfig populates configuration for the deduplication of requests
+// from Slack from environment variables.
+func deduplicationConfig() (slack.DeduplicationConfig, error) {
+	config := slack.DeduplicationConfig{}
+	var err error
+	config.TTL, err =
+		os.GetDurationFromEnvVar("DEDUPLICATION_TTL", 10*time.Minute)
+	return config, err
+}
+
+// configWatcher returns a reload.Watcher that invokes the provided function
+// whenever the files that Slack App configurations and the Brigade API token
+// are loaded from, as indicated by environment variables, change.
+func configWatcher(reloadFn func() error) (*reload.Watcher, error) {
+	slackAppsPath, err := os.GetRequiredEnvVar("SLACK_APPS_PATH")
 	if err != nil {
-		return config, err
+		return nil, err
 	}
-	slackApps := []libSlack.App{}
-	if err := json.Unmarshal(slackAppsBytes, &slackApps); err != nil {
-		return config, err
+	paths := []string{slackAppsPath}
+	if tokenPath := os.GetEnvVar("API_TOKEN_PATH", ""); tokenPath != "" {
+		paths = append(paths, tokenPath)
 	}
-	for _, slackApp := range slackApps {
-		config.SlackApps[slackApp.AppID] = slackApp
+	interval, err :=
+		os.GetDurationFromEnvVar("CONFIG_RELOAD_INTERVAL", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return reload.NewWatcher(paths, interval, reloadFn), nil
+}
+
+// reloadConfig returns a function, suitable for use with configWatcher, that
+// reloads Slack App configurations and Brigade API client configuration and
+// swaps them into use. Nothing is swapped in unless all of it has been loaded
+// and validated. Otherwise, the last good configuration remains in use.
+func reloadConfig(apps *libSlack.Apps, clients *brigade.Clients) func() error {
+	return func() error {
+		loadedApps, err := slackApps()
+		if err != nil {
+			return err
+		}
+		address, token, opts, err := apiClientConfig()
+		if err != nil {
+			return err
+		}
+		apps.Replace(loadedApps)
+		clients.Replace(address, token, opts)
+		return nil
 	}
-	return config, nil
 }
 
 // serverConfig populates configuration for the HTTP/S server from environment
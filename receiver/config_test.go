@@ -4,8 +4,11 @@ package main
 import (
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/brigadecore/brigade-foundations/http"
+	"github.com/brigadecore/brigade-slack-gateway/internal/brigade"
+	libSlack "github.com/brigadecore/brigade-slack-gateway/internal/slack"
 	"github.com/brigadecore/brigade-slack-gateway/receiver/internal/slack"
 	"github.com/brigadecore/brigade/sdk/v3/restmachinery"
 	"github.com/stretchr/testify/require"
@@ -75,6 +78,59 @@ func TestAPIClientConfig(t *testing.T) {
 				require.True(t, opts.AllowInsecureConnections)
 			},
 		},
+		{
+			name: "API_TOKEN_PATH path does not exist",
+			setup: func() {
+				t.Setenv("API_TOKEN_PATH", "/completely/bogus/path")
+			},
+			assertions: func(
+				_ string,
+				_ string,
+				_ restmachinery.APIClientOptions,
+				err error,
+			) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error reading API token")
+			},
+		},
+		{
+			name: "API_TOKEN_PATH file is empty",
+			setup: func() {
+				tokenFile, err := ioutil.TempFile("", "token")
+				require.NoError(t, err)
+				defer tokenFile.Close()
+				t.Setenv("API_TOKEN_PATH", tokenFile.Name())
+			},
+			assertions: func(
+				_ string,
+				_ string,
+				_ restmachinery.APIClientOptions,
+				err error,
+			) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "does not contain an API token")
+			},
+		},
+		{
+			name: "success with API_TOKEN_PATH",
+			setup: func() {
+				tokenFile, err := ioutil.TempFile("", "token")
+				require.NoError(t, err)
+				defer tokenFile.Close()
+				_, err = tokenFile.Write([]byte("baz\n"))
+				require.NoError(t, err)
+				t.Setenv("API_TOKEN_PATH", tokenFile.Name())
+			},
+			assertions: func(
+				_ string,
+				token string,
+				_ restmachinery.APIClientOptions,
+				err error,
+			) {
+				require.NoError(t, err)
+				require.Equal(t, "baz", token)
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -85,15 +141,16 @@ func TestAPIClientConfig(t *testing.T) {
 	}
 }
 
-func TestSignatureVerificationFilterConfig(t *testing.T) {
+func TestSlackApps(t *testing.T) {
 	testCases := []struct {
 		name       string
 		setup      func()
-		assertions func(slack.SignatureVerificationFilterConfig, error)
+		assertions func(map[string]libSlack.App, error)
 	}{
 		{
-			name: "SLACK_APPS_PATH not set",
-			assertions: func(_ slack.SignatureVerificationFilterConfig, err error) {
+			name:  "SLACK_APPS_PATH not set",
+			setup: func() {},
+			assertions: func(_ map[string]libSlack.App, err error) {
 				require.Error(t, err)
 				require.Contains(t, err.Error(), "value not found for")
 				require.Contains(t, err.Error(), "SLACK_APPS_PATH")
@@ -104,7 +161,7 @@ func TestSignatureVerificationFilterConfig(t *testing.T) {
 			setup: func() {
 				t.Setenv("SLACK_APPS_PATH", "/completely/bogus/path")
 			},
-			assertions: func(_ slack.SignatureVerificationFilterConfig, err error) {
+			assertions: func(_ map[string]libSlack.App, err error) {
 				require.Error(t, err)
 				require.Contains(
 					t,
@@ -123,7 +180,7 @@ func TestSignatureVerificationFilterConfig(t *testing.T) {
 				require.NoError(t, err)
 				t.Setenv("SLACK_APPS_PATH", appsFile.Name())
 			},
-			assertions: func(_ slack.SignatureVerificationFilterConfig, err error) {
+			assertions: func(_ map[string]libSlack.App, err error) {
 				require.Error(t, err)
 				require.Contains(
 					t, err.Error(), "invalid character",
@@ -136,19 +193,93 @@ func TestSignatureVerificationFilterConfig(t *testing.T) {
 				appsFile, err := ioutil.TempFile("", "apps.json")
 				require.NoError(t, err)
 				defer appsFile.Close()
-				_, err =
-					appsFile.Write([]byte(`[{"appID":"42","appSigningSecret":"foobar"}]`))
+				_, err = appsFile.Write(
+					[]byte(
+						`[{"appID":"42","appSigningSecret":"foobar","commands":[{"command":"/deploy","parameters":[{"name":"environment","type":"select","options":["staging","prod"]}]}]}]`, // nolint: lll
+					),
+				)
 				require.NoError(t, err)
 				t.Setenv("SLACK_APPS_PATH", appsFile.Name())
 			},
+			assertions: func(apps map[string]libSlack.App, err error) {
+				require.NoError(t, err)
+				require.Len(t, apps, 1)
+				require.Equal(t, "42", apps["42"].AppID)
+				require.Equal(t, "foobar", apps["42"].AppSigningSecret)
+				cmd, ok := apps["42"].Command("/deploy")
+				require.True(t, ok)
+				require.Len(t, cmd.Parameters, 1)
+				require.Equal(t, "environment", cmd.Parameters[0].Name)
+				require.Equal(
+					t,
+					[]string{"staging", "prod"},
+					cmd.Parameters[0].Options,
+				)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.setup()
+			apps, err := slackApps()
+			testCase.assertions(apps, err)
+		})
+	}
+}
+
+func TestSignatureVerificationFilterConfig(t *testing.T) {
+	testCases := []struct {
+		name       string
+		setup      func()
+		assertions func(slack.SignatureVerificationFilterConfig, error)
+	}{
+		{
+			name: "success",
 			assertions: func(
 				config slack.SignatureVerificationFilterConfig,
 				err error,
 			) {
 				require.NoError(t, err)
-				require.Len(t, config.SlackApps, 1)
-				require.Equal(t, "42", config.SlackApps["42"].AppID)
-				require.Equal(t, "foobar", config.SlackApps["42"].AppSigningSecret)
+				require.Equal(t, 5*time.Minute, config.TimestampTolerance)
+				require.Equal(t, int64(1<<20), config.MaxRequestBodyBytes)
+			},
+		},
+		{
+			name: "SIGNATURE_TIMESTAMP_TOLERANCE not parsable as duration",
+			setup: func() {
+				t.Setenv("SIGNATURE_TIMESTAMP_TOLERANCE", "foo")
+			},
+			assertions: func(_ slack.SignatureVerificationFilterConfig, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "was not parsable as a duration")
+				require.Contains(t, err.Error(), "SIGNATURE_TIMESTAMP_TOLERANCE")
+			},
+		},
+		{
+			name: "MAX_REQUEST_BODY_BYTES not parsable as int",
+			setup: func() {
+				t.Setenv("SIGNATURE_TIMESTAMP_TOLERANCE", "1m")
+				t.Setenv("MAX_REQUEST_BODY_BYTES", "foo")
+			},
+			assertions: func(_ slack.SignatureVerificationFilterConfig, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "was not parsable as an int")
+				require.Contains(t, err.Error(), "MAX_REQUEST_BODY_BYTES")
+			},
+		},
+		{
+			name: "success with overrides",
+			setup: func() {
+				t.Setenv("SIGNATURE_TIMESTAMP_TOLERANCE", "1m")
+				t.Setenv("MAX_REQUEST_BODY_BYTES", "1024")
+			},
+			assertions: func(
+				config slack.SignatureVerificationFilterConfig,
+				err error,
+			) {
+				require.NoError(t, err)
+				require.Equal(t, time.Minute, config.TimestampTolerance)
+				require.Equal(t, int64(1024), config.MaxRequestBodyBytes)
 			},
 		},
 	}
@@ -163,6 +294,165 @@ func TestSignatureVerificationFilterConfig(t *testing.T) {
 	}
 }
 
+func TestTokenStore(t *testing.T) {
+	store, err := tokenStore()
+	require.NoError(t, err)
+	require.Nil(t, store)
+	t.Setenv("TOKEN_STORE_BACKEND", "foo")
+	_, err = tokenStore()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "TOKEN_STORE_BACKEND")
+	t.Setenv("TOKEN_STORE_BACKEND", "file")
+	_, err = tokenStore()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "TOKEN_STORE_PATH")
+	t.Setenv("TOKEN_STORE_PATH", "/tmp/installations.json")
+	store, err = tokenStore()
+	require.NoError(t, err)
+	require.NotNil(t, store)
+	t.Setenv("TOKEN_STORE_BACKEND", "secret")
+	_, err = tokenStore()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "TOKEN_STORE_SECRET_NAME")
+}
+
+func TestSlashCommandServiceConfig(t *testing.T) {
+	config, err := slashCommandServiceConfig()
+	require.NoError(t, err)
+	require.False(t, config.AsyncAck)
+	t.Setenv("ASYNC_ACK", "nope")
+	_, err = slashCommandServiceConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as a bool")
+	t.Setenv("ASYNC_ACK", "true")
+	config, err = slashCommandServiceConfig()
+	require.NoError(t, err)
+	require.True(t, config.AsyncAck)
+	require.Equal(t, 5*time.Minute, config.UserGroupCacheTTL)
+	t.Setenv("USER_GROUP_CACHE_TTL", "foo")
+	_, err = slashCommandServiceConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as a duration")
+	t.Setenv("USER_GROUP_CACHE_TTL", "1m")
+	config, err = slashCommandServiceConfig()
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, config.UserGroupCacheTTL)
+	require.Equal(t, 5*time.Minute, config.EnrichmentCacheTTL)
+	t.Setenv("ENRICHMENT_CACHE_TTL", "foo")
+	_, err = slashCommandServiceConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as a duration")
+	t.Setenv("ENRICHMENT_CACHE_TTL", "1h")
+	config, err = slashCommandServiceConfig()
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, config.EnrichmentCacheTTL)
+	require.Equal(t, 5*time.Minute, config.ConfirmationTimeout)
+	t.Setenv("CONFIRMATION_TIMEOUT", "foo")
+	_, err = slashCommandServiceConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as a duration")
+	t.Setenv("CONFIRMATION_TIMEOUT", "30s")
+	config, err = slashCommandServiceConfig()
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, config.ConfirmationTimeout)
+}
+
+func TestInteractionServiceConfig(t *testing.T) {
+	config, err := interactionServiceConfig()
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, config.UserGroupCacheTTL)
+	t.Setenv("USER_GROUP_CACHE_TTL", "foo")
+	_, err = interactionServiceConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as a duration")
+	t.Setenv("USER_GROUP_CACHE_TTL", "1m")
+	config, err = interactionServiceConfig()
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, config.UserGroupCacheTTL)
+}
+
+func TestEventsAPIServiceConfig(t *testing.T) {
+	config, err := eventsAPIServiceConfig()
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, config.UserGroupCacheTTL)
+	t.Setenv("USER_GROUP_CACHE_TTL", "foo")
+	_, err = eventsAPIServiceConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as a duration")
+	t.Setenv("USER_GROUP_CACHE_TTL", "1m")
+	config, err = eventsAPIServiceConfig()
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, config.UserGroupCacheTTL)
+}
+
+func TestDeduplicationConfig(t *testing.T) {
+	config, err := deduplicationConfig()
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Minute, config.TTL)
+	t.Setenv("DEDUPLICATION_TTL", "foo")
+	_, err = deduplicationConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as a duration")
+	t.Setenv("DEDUPLICATION_TTL", "1h")
+	config, err = deduplicationConfig()
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, config.TTL)
+}
+
+func TestConfigWatcher(t *testing.T) {
+	reloadFn := func() error { return nil }
+	_, err := configWatcher(reloadFn)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "SLACK_APPS_PATH")
+	t.Setenv("SLACK_APPS_PATH", "/completely/bogus/path")
+	t.Setenv("CONFIG_RELOAD_INTERVAL", "foo")
+	_, err = configWatcher(reloadFn)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not parsable as a duration")
+	t.Setenv("CONFIG_RELOAD_INTERVAL", "1m")
+	watcher, err := configWatcher(reloadFn)
+	require.NoError(t, err)
+	require.NotNil(t, watcher)
+}
+
+func TestReloadConfig(t *testing.T) {
+	appsFile, err := ioutil.TempFile("", "apps.json")
+	require.NoError(t, err)
+	defer appsFile.Close()
+	t.Setenv("SLACK_APPS_PATH", appsFile.Name())
+	t.Setenv("API_ADDRESS", "foo")
+	t.Setenv("API_TOKEN", "bar")
+	apps := libSlack.NewApps(map[string]libSlack.App{"42": {AppID: "42"}})
+	clients := brigade.NewClients("foo", "bar", restmachinery.APIClientOptions{})
+	reloadFn := reloadConfig(apps, clients)
+	// Invalid configuration is rejected and the previous apps remain in use
+	require.NoError(
+		t,
+		ioutil.WriteFile(
+			appsFile.Name(),
+			[]byte(`[{"appID":"43","visibility":{"status":"private"}}]`),
+			0600,
+		),
+	)
+	err = reloadFn()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid status visibility "private"`)
+	_, ok := apps.Get("42")
+	require.True(t, ok)
+	_, ok = apps.Get("43")
+	require.False(t, ok)
+	// Valid configuration is swapped in
+	require.NoError(
+		t,
+		ioutil.WriteFile(appsFile.Name(), []byte(`[{"appID":"43"}]`), 0600),
+	)
+	require.NoError(t, reloadFn())
+	_, ok = apps.Get("42")
+	require.False(t, ok)
+	_, ok = apps.Get("43")
+	require.True(t, ok)
+}
+
 func TestServerConfig(t *testing.T) {
 	testCases := []struct {
 		name       string
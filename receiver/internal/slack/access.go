@@ -0,0 +1,97 @@
+package slack
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/brigadecore/brigade/sdk/v3"
+)
+
+// accessChecker decides whether events resulting from requests other than
+// slash commands, e.g. interactions and Events API callbacks, may be emitted
+// into Brigade. The same checks that apply to slash commands are applied: the
+// App's policy and that of the Enterprise Grid organization the request
+// originated from must permit the request and, if the App maps Slack users to
+// Brigade users, the user must map to a Brigade user holding the required role
+// for every project subscribed to the event.
+type accessChecker struct {
+	authorizer *authorizer
+	identities *identityResolver
+}
+
+// newAccessChecker returns an accessChecker that caches user group membership
+// and users' email addresses for the specified TTL.
+func newAccessChecker(
+	projectsClient sdk.ProjectsClient,
+	apiClient slack.APIClient,
+	cacheTTL time.Duration,
+) *accessChecker {
+	return &accessChecker{
+		authorizer: newAuthorizer(apiClient, cacheTTL),
+		identities: newIdentityResolver(projectsClient, apiClient, cacheTTL),
+	}
+}
+
+// permits returns a bool indicating whether the provided event, which resulted
+// from a request with the provided origin, may be emitted into Brigade. If the
+// App maps Slack users to Brigade users, the Brigade user's ID is added to the
+// event's labels. Denials are logged.
+func (a *accessChecker) permits(
+	ctx context.Context,
+	app slack.App,
+	o origin,
+	event *sdk.Event,
+) (bool, error) {
+	enterprise, _ := app.Enterprise(o.EnterpriseID)
+	if !a.authorizer.authorize(ctx, app, o, app.Policy, enterprise.Policy) {
+		log.Printf(
+			"denied event %q for app %q from user %q in channel %q of team %q",
+			event.Type,
+			o.AppID,
+			o.UserID,
+			o.ChannelID,
+			o.TeamID,
+		)
+		return false, nil
+	}
+	if app.Identities == nil {
+		return true, nil
+	}
+	brigadeUserID, ok, err := a.identities.brigadeUserID(ctx, app, o.UserID)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		log.Printf(
+			"denied event %q for app %q from unmapped user %q",
+			event.Type,
+			o.AppID,
+			o.UserID,
+		)
+		return false, nil
+	}
+	// The label is added before checking roles because projects' subscriptions
+	// may match on it.
+	event.Labels["brigadeUserID"] = brigadeUserID
+	projectIDs, err :=
+		a.identities.unauthorizedProjects(ctx, app, *event, brigadeUserID)
+	if err != nil {
+		return false, err
+	}
+	if len(projectIDs) > 0 {
+		log.Printf(
+			"denied event %q for app %q from user %q (brigade user %q) lacking "+
+				"required role for project(s) %s",
+			event.Type,
+			o.AppID,
+			o.UserID,
+			brigadeUserID,
+			strings.Join(projectIDs, ", "),
+		)
+		return false, nil
+	}
+	return true, nil
+}
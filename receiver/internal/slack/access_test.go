@@ -0,0 +1,199 @@
+package slack
+
+// nolint: lll
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAccessChecker(t *testing.T) {
+	a := newAccessChecker(
+		&sdkTesting.MockProjectsClient{},
+		&slackTesting.MockAPIClient{},
+		time.Minute,
+	)
+	require.NotNil(t, a.authorizer)
+	require.Equal(t, time.Minute, a.authorizer.userGroups.ttl)
+	require.NotNil(t, a.identities)
+	require.Equal(t, time.Minute, a.identities.emails.ttl)
+}
+
+func TestAccessCheckerPermits(t *testing.T) {
+	testOrigin := origin{
+		AppID:        "control-app",
+		EnterpriseID: "agency",
+		TeamID:       "control",
+		ChannelID:    "cone-of-silence",
+		UserID:       "86",
+	}
+	maxOnly := &slack.IdentityMapping{
+		Users: []slack.UserMapping{
+			{
+				SlackUserID:   "86",
+				BrigadeUserID: "max",
+			},
+		},
+	}
+	testCases := []struct {
+		name                string
+		app                 slack.App
+		listRoleAssignments func(
+			context.Context,
+			*sdk.ProjectRoleAssignmentsSelector,
+			*meta.ListOptions,
+		) (sdk.ProjectRoleAssignmentList, error)
+		assertions func(sdk.Event, bool, error)
+	}{
+		{
+			name: "no policies or identity mapping",
+			app:  slack.App{AppID: "control-app"},
+			assertions: func(event sdk.Event, permitted bool, err error) {
+				require.NoError(t, err)
+				require.True(t, permitted)
+				require.NotContains(t, event.Labels, "brigadeUserID")
+			},
+		},
+		{
+			name: "denied by app policy",
+			app: slack.App{
+				AppID: "control-app",
+				Policy: slack.Policy{
+					Deny: &slack.PolicyRule{
+						UserIDs: []string{"86"},
+					},
+				},
+			},
+			assertions: func(_ sdk.Event, permitted bool, err error) {
+				require.NoError(t, err)
+				require.False(t, permitted)
+			},
+		},
+		{
+			name: "denied by enterprise policy",
+			app: slack.App{
+				AppID: "control-app",
+				Enterprises: []slack.Enterprise{
+					{
+						EnterpriseID: "agency",
+						Policy: slack.Policy{
+							Deny: &slack.PolicyRule{
+								ChannelIDs: []string{"cone-of-silence"},
+							},
+						},
+					},
+				},
+			},
+			assertions: func(_ sdk.Event, permitted bool, err error) {
+				require.NoError(t, err)
+				require.False(t, permitted)
+			},
+		},
+		{
+			name: "unmapped user",
+			app: slack.App{
+				AppID:      "control-app",
+				Identities: &slack.IdentityMapping{},
+			},
+			assertions: func(_ sdk.Event, permitted bool, err error) {
+				require.NoError(t, err)
+				require.False(t, permitted)
+			},
+		},
+		{
+			name: "error listing role assignments",
+			app: slack.App{
+				AppID:      "control-app",
+				Identities: maxOnly,
+			},
+			listRoleAssignments: func(
+				context.Context,
+				*sdk.ProjectRoleAssignmentsSelector,
+				*meta.ListOptions,
+			) (sdk.ProjectRoleAssignmentList, error) {
+				return sdk.ProjectRoleAssignmentList{},
+					errors.New("something went wrong")
+			},
+			assertions: func(_ sdk.Event, permitted bool, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "something went wrong")
+				require.False(t, permitted)
+			},
+		},
+		{
+			name: "mapped user lacking required role",
+			app: slack.App{
+				AppID:      "control-app",
+				Identities: maxOnly,
+			},
+			listRoleAssignments: func(
+				context.Context,
+				*sdk.ProjectRoleAssignmentsSelector,
+				*meta.ListOptions,
+			) (sdk.ProjectRoleAssignmentList, error) {
+				return sdk.ProjectRoleAssignmentList{}, nil
+			},
+			assertions: func(_ sdk.Event, permitted bool, err error) {
+				require.NoError(t, err)
+				require.False(t, permitted)
+			},
+		},
+		{
+			name: "mapped user holding required role",
+			app: slack.App{
+				AppID:      "control-app",
+				Identities: maxOnly,
+			},
+			listRoleAssignments: func(
+				context.Context,
+				*sdk.ProjectRoleAssignmentsSelector,
+				*meta.ListOptions,
+			) (sdk.ProjectRoleAssignmentList, error) {
+				return sdk.ProjectRoleAssignmentList{
+					Items: []sdk.ProjectRoleAssignment{{}},
+				}, nil
+			},
+			assertions: func(event sdk.Event, permitted bool, err error) {
+				require.NoError(t, err)
+				require.True(t, permitted)
+				require.Equal(t, "max", event.Labels["brigadeUserID"])
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			a := newAccessChecker(
+				&sdkTesting.MockProjectsClient{
+					ListFn: func(
+						context.Context,
+						*sdk.ProjectsSelector,
+						*meta.ListOptions,
+					) (sdk.ProjectList, error) {
+						return sdk.ProjectList{
+							Items: []sdk.Project{testProject("italian", "control-app")},
+						}, nil
+					},
+					AuthzClient: &sdkTesting.MockProjectAuthzClient{
+						RoleAssignmentsClient: &sdkTesting.MockProjectRoleAssignmentsClient{ // nolint: lll
+							ListFn: testCase.listRoleAssignments,
+						},
+					},
+				},
+				&slackTesting.MockAPIClient{},
+				time.Minute,
+			)
+			event := newEvent(testOrigin, "approve", "")
+			permitted, err :=
+				a.permits(context.Background(), testCase.app, testOrigin, &event)
+			testCase.assertions(event, permitted, err)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package slack
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/pkg/errors"
+)
+
+// authorizer evaluates the policies that govern who may invoke slash commands
+// and where. User group membership is looked up using the Slack Web API and
+// cached.
+type authorizer struct {
+	apiClient  slack.APIClient
+	userGroups *cache
+}
+
+// newAuthorizer returns an authorizer that caches user group membership for
+// the specified TTL.
+func newAuthorizer(
+	apiClient slack.APIClient,
+	userGroupCacheTTL time.Duration,
+) *authorizer {
+	return &authorizer{
+		apiClient:  apiClient,
+		userGroups: newCache(userGroupCacheTTL),
+	}
+}
+
+// authorize returns a bool indicating whether ALL of the provided policies
+// permit a request from the provided origin. Failure to look up user group
+// membership is logged and causes the request to be denied, since treating it
+// as non-membership would silently disable any policy that denies a group.
+func (a *authorizer) authorize(
+	ctx context.Context,
+	app slack.App,
+	o origin,
+	policies ...slack.Policy,
+) bool {
+	var lookupFailed bool
+	isMember := func(userGroupID string) bool {
+		members, err := a.userGroupMembers(ctx, app, userGroupID)
+		if err != nil {
+			log.Println(err)
+			lookupFailed = true
+			return false
+		}
+		_, ok := members[o.UserID]
+		return ok
+	}
+	for _, policy := range policies {
+		if !policy.Permits(
+			o.EnterpriseID,
+			o.TeamID,
+			o.ChannelID,
+			o.UserID,
+			isMember,
+		) || lookupFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// userGroupMembers returns the set of IDs of users who are members of the
+// specified user group.
+func (a *authorizer) userGroupMembers(
+	ctx context.Context,
+	app slack.App,
+	userGroupID string,
+) (map[string]struct{}, error) {
+	// Different apps may be installed in different workspaces, so cache entries
+	// are scoped to the app.
+	cacheKey := app.AppID + ":" + userGroupID
+	if members, ok := a.userGroups.get(cacheKey); ok {
+		return members.(map[string]struct{}), nil
+	}
+	result := struct {
+		Users []string `json:"users"`
+	}{}
+	if err := slack.CallWithFallback(
+		ctx,
+		a.apiClient,
+		app.APITokens(),
+		"usergroups.users.list",
+		url.Values{
+			"usergroup": []string{userGroupID},
+		},
+		&result,
+	); err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"error listing members of user group %q",
+			userGroupID,
+		)
+	}
+	members := make(map[string]struct{}, len(result.Users))
+	for _, userID := range result.Users {
+		members[userID] = struct{}{}
+	}
+	a.userGroups.set(cacheKey, members)
+	return members, nil
+}
@@ -0,0 +1,203 @@
+package slack
+
+// nolint: lll
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuthorizer(t *testing.T) {
+	a := newAuthorizer(&slackTesting.MockAPIClient{}, time.Minute)
+	require.NotNil(t, a.apiClient)
+	require.NotNil(t, a.userGroups)
+	require.Equal(t, time.Minute, a.userGroups.ttl)
+}
+
+func TestAuthorizerAuthorize(t *testing.T) {
+	testApp := slack.App{
+		AppID:    "control-app",
+		APIToken: "foo",
+	}
+	testOrigin := origin{
+		AppID:     "control-app",
+		TeamID:    "control",
+		ChannelID: "cone-of-silence",
+		UserID:    "86",
+	}
+	opsOnly := slack.Policy{
+		Allow: &slack.PolicyRule{
+			UserGroupIDs: []string{"ops"},
+		},
+	}
+	testCases := []struct {
+		name       string
+		apiClient  slack.APIClient
+		policies   []slack.Policy
+		assertions func(authorizer *authorizer, authorized bool)
+	}{
+		{
+			name:      "no policies",
+			apiClient: &slackTesting.MockAPIClient{},
+			assertions: func(_ *authorizer, authorized bool) {
+				require.True(t, authorized)
+			},
+		},
+		{
+			name:      "denied by one of several policies",
+			apiClient: &slackTesting.MockAPIClient{},
+			policies: []slack.Policy{
+				{},
+				{
+					Deny: &slack.PolicyRule{
+						ChannelIDs: []string{"cone-of-silence"},
+					},
+				},
+			},
+			assertions: func(_ *authorizer, authorized bool) {
+				require.False(t, authorized)
+			},
+		},
+		{
+			name: "error listing user group members",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					context.Context,
+					string,
+					string,
+					interface{},
+					interface{},
+				) error {
+					return errors.New("something went wrong")
+				},
+			},
+			policies: []slack.Policy{opsOnly},
+			assertions: func(authorizer *authorizer, authorized bool) {
+				require.False(t, authorized)
+				_, ok := authorizer.userGroups.get("control-app:ops")
+				require.False(t, ok)
+			},
+		},
+		{
+			name: "error listing members of denied user group",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					context.Context,
+					string,
+					string,
+					interface{},
+					interface{},
+				) error {
+					return errors.New("ratelimited")
+				},
+			},
+			policies: []slack.Policy{
+				{
+					Deny: &slack.PolicyRule{
+						UserGroupIDs: []string{"kaos"},
+					},
+				},
+			},
+			assertions: func(_ *authorizer, authorized bool) {
+				require.False(t, authorized)
+			},
+		},
+		{
+			name: "not a user group member",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					_ string,
+					_ string,
+					_ interface{},
+					result interface{},
+				) error {
+					result.(*struct {
+						Users []string `json:"users"`
+					}).Users = []string{"99"}
+					return nil
+				},
+			},
+			policies: []slack.Policy{opsOnly},
+			assertions: func(_ *authorizer, authorized bool) {
+				require.False(t, authorized)
+			},
+		},
+		{
+			name: "user group member",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					token string,
+					method string,
+					args interface{},
+					result interface{},
+				) error {
+					require.Equal(t, "foo", token)
+					require.Equal(t, "usergroups.users.list", method)
+					require.Equal(t, "ops", args.(url.Values).Get("usergroup"))
+					result.(*struct {
+						Users []string `json:"users"`
+					}).Users = []string{"86", "99"}
+					return nil
+				},
+			},
+			policies: []slack.Policy{opsOnly},
+			assertions: func(authorizer *authorizer, authorized bool) {
+				require.True(t, authorized)
+				// Membership should have been cached
+				members, ok := authorizer.userGroups.get("control-app:ops")
+				require.True(t, ok)
+				require.Len(t, members, 2)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			a := newAuthorizer(testCase.apiClient, time.Minute)
+			testCase.assertions(
+				a,
+				a.authorize(
+					context.Background(),
+					testApp,
+					testOrigin,
+					testCase.policies...,
+				),
+			)
+		})
+	}
+}
+
+func TestAuthorizerUserGroupMembersCached(t *testing.T) {
+	calls := 0
+	a := newAuthorizer(
+		&slackTesting.MockAPIClient{
+			CallFn: func(
+				context.Context,
+				string,
+				string,
+				interface{},
+				interface{},
+			) error {
+				calls++
+				return nil
+			},
+		},
+		time.Minute,
+	)
+	app := slack.App{
+		AppID:    "control-app",
+		APIToken: "foo",
+	}
+	for i := 0; i < 3; i++ {
+		_, err := a.userGroupMembers(context.Background(), app, "ops")
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, calls)
+}
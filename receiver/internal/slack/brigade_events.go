@@ -0,0 +1,58 @@
+package slack
+
+import "github.com/brigadecore/brigade/sdk/v3"
+
+const eventSource = "brigade.sh/slack"
+
+// origin encapsulates details of where, within Slack, an inbound request
+// originated.
+type origin struct {
+	AppID        string
+	EnterpriseID string
+	TeamID       string
+	ChannelID    string
+	UserID       string
+}
+
+// newEvent returns a Brigade event of the specified type, qualified and labeled
+// using details from the provided origin. Every kind of inbound request that
+// this gateway handles (slash commands, Events API callbacks, interactions,
+// etc.) should use this function so that Brigade projects can subscribe to all
+// of them in a uniform manner.
+func newEvent(o origin, eventType string, payload string) sdk.Event {
+	event := sdk.Event{
+		Source: eventSource,
+		Type:   eventType,
+		// A workspace can have multiple apps installed that all use the same slash
+		// command, so events are qualified with WHICH app produced them.
+		Qualifiers: map[string]string{
+			"appID": o.AppID,
+		},
+		Labels:  map[string]string{},
+		Payload: payload,
+	}
+	// Not every kind of inbound request carries all of these details. We only
+	// include labels for the details we have rather than having their values
+	// often be the empty string.
+	if o.TeamID != "" {
+		event.Labels["teamID"] = o.TeamID
+	}
+	if o.ChannelID != "" {
+		event.Labels["channelID"] = o.ChannelID
+		// The monitor can only report status back to a channel, so events are only
+		// tracked if we know which channel they originated from.
+		event.SourceState = &sdk.SourceState{
+			State: map[string]string{
+				"tracking": "true",
+			},
+		}
+	}
+	if o.UserID != "" {
+		event.Labels["userID"] = o.UserID
+	}
+	// This information is only present for Slack Enterprise Grid customers.
+	if o.EnterpriseID != "" {
+		event.Labels["enterpriseID"] = o.EnterpriseID
+	}
+	return event
+}
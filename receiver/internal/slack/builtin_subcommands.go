@@ -0,0 +1,537 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/Masterminds/sprig"
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	"github.com/pkg/errors"
+)
+
+const (
+	builtinSubcommandHelp   = "help"
+	builtinSubcommandStatus = "status"
+	builtinSubcommandList   = "list"
+	builtinSubcommandLogs   = "logs"
+
+	// listLimit is the maximum number of events listed by the list subcommand.
+	listLimit = 10
+	// maxLogLines is the maximum number of lines of logs returned by the logs
+	// subcommand.
+	maxLogLines = 50
+	// maxLogBytes is the maximum number of bytes of logs returned by the logs
+	// subcommand. Slack limits the text of a section block to 3000 characters.
+	maxLogBytes = 2800
+)
+
+// builtinSubcommands handles the reserved help, status, list, and logs
+// subcommands, which report on the projects subscribed to events from this
+// gateway and on events previously emitted into Brigade, without emitting any
+// new events. Responses are visible only to the user who invoked them.
+type builtinSubcommands struct {
+	projectsClient    sdk.ProjectsClient
+	eventsClient      sdk.EventsClient
+	helpMsgTemplate   *template.Template
+	statusMsgTemplate *template.Template
+	listMsgTemplate   *template.Template
+	logsMsgTemplate   *template.Template
+}
+
+// newBuiltinSubcommands returns a new builtinSubcommands.
+func newBuiltinSubcommands(
+	projectsClient sdk.ProjectsClient,
+	eventsClient sdk.EventsClient,
+) (*builtinSubcommands, error) {
+	b := &builtinSubcommands{
+		projectsClient: projectsClient,
+		eventsClient:   eventsClient,
+	}
+	var err error
+	for _, t := range []struct {
+		template **template.Template
+		text     string
+	}{
+		{&b.helpMsgTemplate, helpMsgTemplate},
+		{&b.statusMsgTemplate, statusMsgTemplate},
+		{&b.listMsgTemplate, listMsgTemplate},
+		{&b.logsMsgTemplate, logsMsgTemplate},
+	} {
+		if *t.template, err = template.New(
+			"template",
+		).Funcs(sprig.TxtFuncMap()).Parse(t.text); err != nil {
+			return nil, errors.Wrap(err, "error parsing built-in subcommand template")
+		}
+	}
+	return b, nil
+}
+
+// handle handles the provided slash command if the first word of its text is
+// the name of a built-in subcommand. The bool returned indicates whether it
+// was.
+func (b *builtinSubcommands) handle(
+	ctx context.Context,
+	command SlashCommand,
+) ([]byte, bool, error) {
+	args := strings.Fields(command.Text)
+	if len(args) == 0 {
+		return nil, false, nil
+	}
+	var response []byte
+	var err error
+	switch strings.ToLower(args[0]) {
+	case builtinSubcommandHelp:
+		response, err = b.help(ctx, command)
+	case builtinSubcommandStatus:
+		if len(args) != 2 {
+			return ephemeralMessage(
+				fmt.Sprintf("Usage: %s status <event ID>", command.Command),
+			), true, nil
+		}
+		response, err = b.status(ctx, command, args[1])
+	case builtinSubcommandList:
+		response, err = b.list(ctx, command)
+	case builtinSubcommandLogs:
+		if len(args) < 2 || len(args) > 3 {
+			return ephemeralMessage(
+				fmt.Sprintf("Usage: %s logs <event ID> [job]", command.Command),
+			), true, nil
+		}
+		var job string
+		if len(args) == 3 {
+			job = args[2]
+		}
+		response, err = b.logs(ctx, command, args[1], job)
+	default:
+		return nil, false, nil
+	}
+	return response, true, err
+}
+
+// help responds with a list of all projects subscribed to events emitted by
+// this gateway on behalf of the Slack App that the provided slash command was
+// sent to, along with the event types each project subscribes to.
+func (b *builtinSubcommands) help(
+	ctx context.Context,
+	command SlashCommand,
+) ([]byte, error) {
+	projects, err :=
+		subscribedProjects(ctx, b.projectsClient, command.APIAppID)
+	if err != nil {
+		return nil, err
+	}
+	type projectHelp struct {
+		ID          string
+		Description string
+		Types       []string
+	}
+	help := make([]projectHelp, len(projects))
+	for i, project := range projects {
+		help[i] = projectHelp{
+			ID:          project.ID,
+			Description: project.Description,
+			Types:       []string{},
+		}
+		for _, sub := range appSubscriptions(project, command.APIAppID) {
+			help[i].Types = append(help[i].Types, sub.Types...)
+		}
+	}
+	return b.render(
+		b.helpMsgTemplate,
+		struct {
+			Usage    string
+			Projects []projectHelp
+		}{
+			Usage: fmt.Sprintf(
+				"Built-in subcommands: %[1]s help, %[1]s status <event ID>, "+
+					"%[1]s list, %[1]s logs <event ID> [job]",
+				command.Command,
+			),
+			Projects: help,
+		},
+	)
+}
+
+// status responds with the phase of the specified event's worker and of each
+// of its jobs.
+func (b *builtinSubcommands) status(
+	ctx context.Context,
+	command SlashCommand,
+	eventID string,
+) ([]byte, error) {
+	event, found, err := b.getEvent(ctx, command, eventID)
+	if err != nil || !found {
+		return eventNotFoundMessage(eventID), err
+	}
+	return b.render(b.statusMsgTemplate, event)
+}
+
+// list responds with the events most recently emitted into Brigade from the
+// channel where the provided slash command was invoked.
+func (b *builtinSubcommands) list(
+	ctx context.Context,
+	command SlashCommand,
+) ([]byte, error) {
+	events, err := b.eventsClient.List(
+		ctx,
+		&sdk.EventsSelector{
+			Source: eventSource,
+			Qualifiers: map[string]string{
+				"appID": command.APIAppID,
+			},
+			Labels: map[string]string{
+				"channelID": command.ChannelID,
+			},
+		},
+		&meta.ListOptions{Limit: listLimit},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing events")
+	}
+	return b.render(b.listMsgTemplate, events.Items)
+}
+
+// logs responds with the tail of the logs of the specified event's worker or,
+// if a job is specified, of that job.
+func (b *builtinSubcommands) logs(
+	ctx context.Context,
+	command SlashCommand,
+	eventID string,
+	job string,
+) ([]byte, error) {
+	event, found, err := b.getEvent(ctx, command, eventID)
+	if err != nil || !found {
+		return eventNotFoundMessage(eventID), err
+	}
+	if job != "" && event.Worker != nil {
+		if _, ok := event.Worker.Job(job); !ok {
+			return ephemeralMessage(
+				fmt.Sprintf("Event %s has no job named %s.", eventID, job),
+			), nil
+		}
+	}
+	logCh, errCh, err := b.eventsClient.Logs().Stream(
+		ctx,
+		eventID,
+		&sdk.LogsSelector{Job: job},
+		&sdk.LogStreamOptions{},
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error streaming logs for event %q", eventID)
+	}
+	lines := []string{}
+	truncated := false
+	for logCh != nil {
+		select {
+		case entry, ok := <-logCh:
+			if !ok {
+				logCh = nil
+				continue
+			}
+			lines = append(lines, entry.Message)
+			if len(lines) > maxLogLines {
+				lines = lines[1:]
+				truncated = true
+			}
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			return nil,
+				errors.Wrapf(err, "error streaming logs for event %q", eventID)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	logs := strings.Join(lines, "\n")
+	if len(logs) > maxLogBytes {
+		// Don't begin in the middle of a multi-byte character
+		start := len(logs) - maxLogBytes
+		for start < len(logs) && !utf8.RuneStart(logs[start]) {
+			start++
+		}
+		logs = logs[start:]
+		truncated = true
+	}
+	return b.render(
+		b.logsMsgTemplate,
+		struct {
+			EventID   string
+			Job       string
+			Logs      string
+			Truncated bool
+		}{
+			EventID:   eventID,
+			Job:       job,
+			Logs:      logs,
+			Truncated: truncated,
+		},
+	)
+}
+
+// getEvent retrieves the specified event and returns it along with a bool
+// indicating whether it was found. Events that were not emitted by this gateway
+// on behalf of the same Slack App, from the same channel, as the provided
+// slash command, are treated as not found so that users cannot snoop on events
+// they would otherwise have no visibility into.
+func (b *builtinSubcommands) getEvent(
+	ctx context.Context,
+	command SlashCommand,
+	eventID string,
+) (sdk.Event, bool, error) {
+	event, err := b.eventsClient.Get(ctx, eventID, nil)
+	if err != nil {
+		if _, ok := errors.Cause(err).(*meta.ErrNotFound); ok {
+			return event, false, nil
+		}
+		return event, false,
+			errors.Wrapf(err, "error retrieving event %q", eventID)
+	}
+	if event.Source != eventSource ||
+		event.Qualifiers["appID"] != command.APIAppID ||
+		event.Labels["channelID"] != command.ChannelID {
+		return event, false, nil
+	}
+	return event, true, nil
+}
+
+// render renders the provided template using the provided data.
+func (b *builtinSubcommands) render(
+	tmpl *template.Template,
+	data interface{},
+) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	err := tmpl.Execute(buffer, data)
+	return buffer.Bytes(), errors.Wrap(err, "error rendering response")
+}
+
+// eventNotFoundMessage returns a message informing the user that the specified
+// event could not be found.
+func eventNotFoundMessage(eventID string) []byte {
+	return ephemeralMessage(
+		fmt.Sprintf("No event %s was found for this channel.", eventID),
+	)
+}
+
+var helpMsgTemplate = `{
+  "response_type": "ephemeral",
+  "blocks": [
+    {
+      "type": "header",
+      "text": {
+        "type": "plain_text",
+        "text": "Subscribed Projects"
+      }
+    },
+    {{- if eq (len .Projects) 0 }}
+    {
+      "type": "section",
+      "text": {
+        "type": "plain_text",
+        "text": "No projects subscribe to events from this app."
+      }
+    },
+    {{- end }}
+    {{- range .Projects }}
+    {
+      "type": "section",
+      "text": {
+        "type": "mrkdwn",
+        {{- $text := printf "*%s*" .ID }}
+        {{- if .Description }}
+        {{- $text = printf "%s\n%s" $text .Description }}
+        {{- end }}
+        {{- $text = printf "%s\nEvent types: %s" $text (join ", " .Types) }}
+        "text": {{ toJson $text }}
+      }
+    },
+    {{- end }}
+    {
+      "type": "context",
+      "elements": [
+        {
+          "type": "mrkdwn",
+          "text": {{ toJson .Usage }}
+        }
+      ]
+    }
+  ]
+}`
+
+var statusMsgTemplate = `{
+  "response_type": "ephemeral",
+  "blocks": [
+    {
+      "type": "header",
+      "text": {
+        "type": "plain_text",
+        "text": "Event Status"
+      }
+    },
+    {
+      "type": "section",
+      "fields": [
+        {
+          "type": "mrkdwn",
+          "text": {{ printf "*Event ID*\n%s" .ID | toJson }}
+        },
+        {
+          "type": "mrkdwn",
+          "text": {{ printf "*Project ID*\n%s" .ProjectID | toJson }}
+        },
+        {
+          "type": "mrkdwn",
+          "text": {{ printf "*Type*\n%s" .Type | toJson }}
+        },
+        {
+          "type": "mrkdwn",
+          {{- if .Worker }}
+          {{- $phase := .Worker.Status.Phase }}
+          "text": {{ printf "*Worker Phase*\n%s" $phase | toJson }}
+          {{- else }}
+          "text": "*Worker Phase*\nUNKNOWN"
+          {{- end }}
+        }
+      ]
+    }
+    {{- if and .Worker .Worker.Jobs }}
+    ,
+    {
+      "type": "section",
+      "fields": [
+        {
+          "type": "mrkdwn",
+          "text": "*Job*"
+        },
+        {
+          "type": "mrkdwn",
+          "text": "*Phase*"
+        }
+      ]
+    }
+    {{- range .Worker.Jobs }}
+    ,
+    {
+      "type": "section",
+      "fields": [
+        {
+          "type": "plain_text",
+          "text": {{ toJson .Name }}
+        },
+        {
+          "type": "plain_text",
+          {{- if .Status }}
+          "text": {{ toJson .Status.Phase }}
+          {{- else }}
+          "text": "PENDING"
+          {{- end }}
+        }
+      ]
+    }
+    {{- end }}
+    {{- end }}
+  ]
+}`
+
+var listMsgTemplate = `{
+  "response_type": "ephemeral",
+  "blocks": [
+    {
+      "type": "header",
+      "text": {
+        "type": "plain_text",
+        "text": "Recent Events in this Channel"
+      }
+    },
+    {{- if eq (len .) 0 }}
+    {
+      "type": "section",
+      "text": {
+        "type": "plain_text",
+        "text": "No events found."
+      }
+    }
+    {{- else }}
+    {
+      "type": "section",
+      "fields": [
+        {
+          "type": "mrkdwn",
+          "text": "*Event ID*"
+        },
+        {
+          "type": "mrkdwn",
+          "text": "*Type / Worker Phase*"
+        }
+      ]
+    }
+    {{- range . }}
+    ,
+    {
+      "type": "section",
+      "fields": [
+        {
+          "type": "plain_text",
+          "text": {{ toJson .ID }}
+        },
+        {
+          "type": "plain_text",
+          {{- if .Worker }}
+          "text": {{ printf "%s / %s" .Type .Worker.Status.Phase | toJson }}
+          {{- else }}
+          "text": {{ printf "%s / UNKNOWN" .Type | toJson }}
+          {{- end }}
+        }
+      ]
+    }
+    {{- end }}
+    {{- end }}
+  ]
+}`
+
+var logsMsgTemplate = `{
+  "response_type": "ephemeral",
+  "blocks": [
+    {
+      "type": "header",
+      "text": {
+        "type": "plain_text",
+        {{- if .Job }}
+        "text": {{ printf "Logs for Job %s" .Job | trunc 150 | toJson }}
+        {{- else }}
+        "text": "Logs for Worker"
+        {{- end }}
+      }
+    },
+    {
+      "type": "context",
+      "elements": [
+        {
+          "type": "plain_text",
+          {{- if .Truncated }}
+          "text": {{ printf "Event %s (truncated)" .EventID | toJson }}
+          {{- else }}
+          "text": {{ printf "Event %s" .EventID | toJson }}
+          {{- end }}
+        }
+      ]
+    },
+    {
+      "type": "section",
+      "text": {
+        "type": "mrkdwn",
+        {{- if .Logs }}
+        "text": {{ printf "` + "```%s```" + `" .Logs | toJson }}
+        {{- else }}
+        "text": "No logs found."
+        {{- end }}
+      }
+    }
+  ]
+}`
@@ -0,0 +1,492 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBuiltinSubcommands(t *testing.T) {
+	b, err := newBuiltinSubcommands(
+		&sdkTesting.MockProjectsClient{},
+		&sdkTesting.MockEventsClient{},
+	)
+	require.NoError(t, err)
+	require.NotNil(t, b.projectsClient)
+	require.NotNil(t, b.eventsClient)
+	require.NotNil(t, b.helpMsgTemplate)
+	require.NotNil(t, b.statusMsgTemplate)
+	require.NotNil(t, b.listMsgTemplate)
+	require.NotNil(t, b.logsMsgTemplate)
+}
+
+func TestBuiltinSubcommandsHandle(t *testing.T) {
+	testEvent := sdk.Event{
+		ObjectMeta: meta.ObjectMeta{
+			ID: "123",
+		},
+		ProjectID:  "italian",
+		Source:     eventSource,
+		Type:       "deploy",
+		Qualifiers: map[string]string{"appID": "control-app"},
+		Labels:     map[string]string{"channelID": "cone-of-silence"},
+		Worker: &sdk.Worker{
+			Status: sdk.WorkerStatus{
+				Phase: sdk.WorkerPhaseSucceeded,
+			},
+			Jobs: []sdk.Job{
+				{
+					Name: "build",
+					Status: &sdk.JobStatus{
+						Phase: sdk.JobPhaseSucceeded,
+					},
+				},
+			},
+		},
+	}
+	getFn := func(
+		_ context.Context,
+		id string,
+		_ *sdk.EventGetOptions,
+	) (sdk.Event, error) {
+		if id == testEvent.ID {
+			return testEvent, nil
+		}
+		if id == "456" {
+			event := testEvent
+			event.Labels = map[string]string{"channelID": "elsewhere"}
+			return event, nil
+		}
+		return sdk.Event{}, &meta.ErrNotFound{}
+	}
+	streamLogs := func(lines ...string) func(
+		context.Context,
+		string,
+		*sdk.LogsSelector,
+		*sdk.LogStreamOptions,
+	) (<-chan sdk.LogEntry, <-chan error, error) {
+		return func(
+			context.Context,
+			string,
+			*sdk.LogsSelector,
+			*sdk.LogStreamOptions,
+		) (<-chan sdk.LogEntry, <-chan error, error) {
+			logCh := make(chan sdk.LogEntry, len(lines))
+			errCh := make(chan error)
+			for _, line := range lines {
+				logCh <- sdk.LogEntry{Message: line}
+			}
+			close(logCh)
+			close(errCh)
+			return logCh, errCh, nil
+		}
+	}
+	testCases := []struct {
+		name         string
+		text         string
+		eventsClient sdk.EventsClient
+		assertions   func(response []byte, handled bool, err error)
+	}{
+		{
+			name:         "not a built-in subcommand",
+			text:         "prod",
+			eventsClient: &sdkTesting.MockEventsClient{},
+			assertions: func(_ []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.False(t, handled)
+			},
+		},
+		{
+			name:         "no text",
+			eventsClient: &sdkTesting.MockEventsClient{},
+			assertions: func(_ []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.False(t, handled)
+			},
+		},
+		{
+			name:         "status without event ID",
+			text:         "status",
+			eventsClient: &sdkTesting.MockEventsClient{},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.Contains(t, string(response), "Usage: /brigade status")
+			},
+		},
+		{
+			name: "status of event not found",
+			text: "status 789",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: getFn,
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.Contains(t, string(response), "No event 789 was found")
+			},
+		},
+		{
+			name: "status of event from another channel",
+			text: "status 456",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: getFn,
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.Contains(t, string(response), "No event 456 was found")
+			},
+		},
+		{
+			name: "error getting event",
+			text: "status 123",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: func(
+					context.Context,
+					string,
+					*sdk.EventGetOptions,
+				) (sdk.Event, error) {
+					return sdk.Event{}, errors.New("something went wrong")
+				},
+			},
+			assertions: func(_ []byte, handled bool, err error) {
+				require.True(t, handled)
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error retrieving event")
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name: "status",
+			text: "STATUS 123",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: getFn,
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.True(t, json.Valid(response), string(response))
+				require.Contains(t, string(response), responseTypeEphemeral)
+				require.Contains(t, string(response), "SUCCEEDED")
+				require.Contains(t, string(response), "build")
+			},
+		},
+		{
+			name: "error listing events",
+			text: "list",
+			eventsClient: &sdkTesting.MockEventsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.EventsSelector,
+					*meta.ListOptions,
+				) (sdk.EventList, error) {
+					return sdk.EventList{}, errors.New("something went wrong")
+				},
+			},
+			assertions: func(_ []byte, handled bool, err error) {
+				require.True(t, handled)
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error listing events")
+			},
+		},
+		{
+			name: "list",
+			text: "list",
+			eventsClient: &sdkTesting.MockEventsClient{
+				ListFn: func(
+					_ context.Context,
+					selector *sdk.EventsSelector,
+					opts *meta.ListOptions,
+				) (sdk.EventList, error) {
+					require.Equal(t, eventSource, selector.Source)
+					require.Equal(t, "control-app", selector.Qualifiers["appID"])
+					require.Equal(t, "cone-of-silence", selector.Labels["channelID"])
+					require.Equal(t, int64(listLimit), opts.Limit)
+					return sdk.EventList{
+						Items: []sdk.Event{testEvent, {Type: "rollback"}},
+					}, nil
+				},
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.True(t, json.Valid(response), string(response))
+				require.Contains(t, string(response), "deploy / SUCCEEDED")
+				require.Contains(t, string(response), "rollback / UNKNOWN")
+			},
+		},
+		{
+			name: "list with no events",
+			text: "list",
+			eventsClient: &sdkTesting.MockEventsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.EventsSelector,
+					*meta.ListOptions,
+				) (sdk.EventList, error) {
+					return sdk.EventList{}, nil
+				},
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.True(t, json.Valid(response), string(response))
+				require.Contains(t, string(response), "No events found.")
+			},
+		},
+		{
+			name:         "logs with too many arguments",
+			text:         "logs 123 build extra",
+			eventsClient: &sdkTesting.MockEventsClient{},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.Contains(t, string(response), "Usage: /brigade logs")
+			},
+		},
+		{
+			name: "logs for unknown job",
+			text: "logs 123 test",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: getFn,
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.Contains(t, string(response), "has no job named test")
+			},
+		},
+		{
+			name: "error streaming logs",
+			text: "logs 123",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: getFn,
+				LogsClient: &sdkTesting.MockLogsClient{
+					StreamFn: func(
+						context.Context,
+						string,
+						*sdk.LogsSelector,
+						*sdk.LogStreamOptions,
+					) (<-chan sdk.LogEntry, <-chan error, error) {
+						return nil, nil, errors.New("something went wrong")
+					},
+				},
+			},
+			assertions: func(_ []byte, handled bool, err error) {
+				require.True(t, handled)
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error streaming logs")
+			},
+		},
+		{
+			name: "logs",
+			text: "logs 123 build",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: getFn,
+				LogsClient: &sdkTesting.MockLogsClient{
+					StreamFn: streamLogs("building...", "done!"),
+				},
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.True(t, json.Valid(response), string(response))
+				require.Contains(t, string(response), "Logs for Job build")
+				require.Contains(t, string(response), `building...\ndone!`)
+				require.NotContains(t, string(response), "truncated")
+			},
+		},
+		{
+			name: "logs truncated",
+			text: "logs 123",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: getFn,
+				LogsClient: &sdkTesting.MockLogsClient{
+					StreamFn: streamLogs(
+						strings.Split(strings.Repeat("x\n", maxLogLines*2), "\n")...,
+					),
+				},
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.True(t, json.Valid(response), string(response))
+				require.Contains(t, string(response), "Logs for Worker")
+				require.Contains(t, string(response), "truncated")
+			},
+		},
+		{
+			name: "logs with control characters",
+			text: "logs 123 build",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: getFn,
+				LogsClient: &sdkTesting.MockLogsClient{
+					StreamFn: streamLogs("\x1b[32mok\x1b[0m", "tab\tbell\a"),
+				},
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.True(t, json.Valid(response), string(response))
+				msg := struct {
+					Blocks []struct {
+						Text struct {
+							Text string `json:"text"`
+						} `json:"text"`
+					} `json:"blocks"`
+				}{}
+				require.NoError(t, json.Unmarshal(response, &msg))
+				require.Equal(
+					t,
+					"```\x1b[32mok\x1b[0m\ntab\tbell\a```",
+					msg.Blocks[len(msg.Blocks)-1].Text.Text,
+				)
+			},
+		},
+		{
+			name: "logs truncated mid-character",
+			text: "logs 123",
+			eventsClient: &sdkTesting.MockEventsClient{
+				GetFn: getFn,
+				LogsClient: &sdkTesting.MockLogsClient{
+					// Each "é" is two bytes, so naively keeping the last maxLogBytes
+					// bytes would begin in the middle of one
+					StreamFn: streamLogs(strings.Repeat("é", maxLogBytes/2+1) + "x"),
+				},
+			},
+			assertions: func(response []byte, handled bool, err error) {
+				require.NoError(t, err)
+				require.True(t, handled)
+				require.True(t, utf8.Valid(response))
+				require.True(t, json.Valid(response), string(response))
+				require.Contains(t, string(response), "truncated")
+				require.Contains(
+					t,
+					string(response),
+					"```"+strings.Repeat("é", maxLogBytes/2-1)+"x```",
+				)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			b, err := newBuiltinSubcommands(nil, testCase.eventsClient)
+			require.NoError(t, err)
+			response, handled, err := b.handle(
+				context.Background(),
+				SlashCommand{
+					Command:   "/brigade",
+					APIAppID:  "control-app",
+					ChannelID: "cone-of-silence",
+					Text:      testCase.text,
+				},
+			)
+			testCase.assertions(response, handled, err)
+		})
+	}
+}
+
+func TestBuiltinSubcommandsHelp(t *testing.T) {
+	testCases := []struct {
+		name           string
+		projectsClient sdk.ProjectsClient
+		assertions     func(response []byte, err error)
+	}{
+		{
+			name: "error listing projects",
+			projectsClient: &sdkTesting.MockProjectsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.ProjectsSelector,
+					*meta.ListOptions,
+				) (sdk.ProjectList, error) {
+					return sdk.ProjectList{}, errors.New("something went wrong")
+				},
+			},
+			assertions: func(_ []byte, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error listing projects")
+			},
+		},
+		{
+			name: "no subscribed projects",
+			projectsClient: &sdkTesting.MockProjectsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.ProjectsSelector,
+					*meta.ListOptions,
+				) (sdk.ProjectList, error) {
+					return sdk.ProjectList{
+						Items: []sdk.Project{testProject("german", "kaos-app")},
+					}, nil
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.True(t, json.Valid(response), string(response))
+				require.Contains(t, string(response), "No projects subscribe")
+			},
+		},
+		{
+			name: "subscribed projects",
+			projectsClient: &sdkTesting.MockProjectsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.ProjectsSelector,
+					*meta.ListOptions,
+				) (sdk.ProjectList, error) {
+					deployer := testProject("deployer", "control-app")
+					deployer.Spec.EventSubscriptions[0].Types =
+						[]string{"deploy", "rollback"}
+					return sdk.ProjectList{
+						Items: []sdk.Project{
+							testProject("italian", "control-app"),
+							deployer,
+						},
+					}, nil
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.True(t, json.Valid(response), string(response))
+				require.Contains(t, string(response), "The italian project")
+				require.Contains(t, string(response), "Event types: *")
+				require.Contains(t, string(response), "Event types: deploy, rollback")
+				// Text is JSON encoded, which escapes angle brackets
+				require.Contains(
+					t,
+					string(response),
+					`/brigade status \u003cevent ID\u003e`,
+				)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			b, err := newBuiltinSubcommands(
+				testCase.projectsClient,
+				&sdkTesting.MockEventsClient{},
+			)
+			require.NoError(t, err)
+			response, handled, err := b.handle(
+				context.Background(),
+				SlashCommand{
+					Command:  "/brigade",
+					APIAppID: "control-app",
+					Text:     "help",
+				},
+			)
+			require.True(t, handled)
+			testCase.assertions(response, err)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package slack
+
+import (
+	"sync"
+	"time"
+)
+
+// cache is a simple, concurrency-safe, in-memory cache whose entries expire
+// after a fixed TTL.
+type cache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	nowFn   func() time.Time
+}
+
+type cacheEntry struct {
+	value      interface{}
+	expiration time.Time
+}
+
+// newCache returns a cache whose entries expire after the specified TTL.
+func newCache(ttl time.Duration) *cache {
+	return &cache{
+		ttl:     ttl,
+		entries: map[string]cacheEntry{},
+		nowFn:   time.Now,
+	}
+}
+
+// get returns the unexpired value stored under the specified key and a bool
+// indicating whether any was found.
+func (c *cache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.nowFn().After(entry.expiration) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// take removes the value stored under the specified key and returns it, if it
+// is unexpired, along with a bool indicating whether any was found. Because
+// this is done atomically, only one caller can take any given value.
+func (c *cache) take(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	delete(c.entries, key)
+	if c.nowFn().After(entry.expiration) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// set stores the provided value under the specified key. Expired entries are
+// pruned as a side effect.
+func (c *cache) set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.nowFn()
+	for k, entry := range c.entries {
+		if now.After(entry.expiration) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{
+		value:      value,
+		expiration: now.Add(c.ttl),
+	}
+}
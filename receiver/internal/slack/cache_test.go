@@ -0,0 +1,44 @@
+package slack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	c := newCache(time.Minute)
+	c.nowFn = func() time.Time {
+		return now
+	}
+	_, ok := c.get("foo")
+	require.False(t, ok)
+	c.set("foo", "bar")
+	val, ok := c.get("foo")
+	require.True(t, ok)
+	require.Equal(t, "bar", val)
+	// Travel forward in time so the entry expires
+	now = now.Add(2 * time.Minute)
+	_, ok = c.get("foo")
+	require.False(t, ok)
+	require.Empty(t, c.entries)
+	// Expired entries should be pruned when new ones are set
+	c.set("foo", "bar")
+	now = now.Add(2 * time.Minute)
+	c.set("bat", "baz")
+	require.Len(t, c.entries, 1)
+	// Taking an entry should remove it
+	val, ok = c.take("bat")
+	require.True(t, ok)
+	require.Equal(t, "baz", val)
+	_, ok = c.take("bat")
+	require.False(t, ok)
+	// Expired entries cannot be taken
+	c.set("foo", "bar")
+	now = now.Add(2 * time.Minute)
+	_, ok = c.take("foo")
+	require.False(t, ok)
+	require.Empty(t, c.entries)
+}
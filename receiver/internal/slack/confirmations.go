@@ -0,0 +1,252 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Masterminds/sprig"
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	// confirmActionID is the action ID of the button used to confirm a slash
+	// command.
+	confirmActionID = "brigade-confirm"
+	// cancelActionID is the action ID of the button used to cancel a slash
+	// command.
+	cancelActionID = "brigade-cancel"
+	// maxConfirmationPayloadBytes is the maximum number of bytes of an event's
+	// payload that are shown when requesting confirmation. Slack limits the text
+	// of a section block to 3000 characters.
+	maxConfirmationPayloadBytes = 2000
+)
+
+// Confirmation encapsulates a user's response to a request to confirm what
+// will be emitted into Brigade in response to a slash command.
+type Confirmation struct {
+	// ID identifies the pending slash command being confirmed or cancelled.
+	ID string
+	// UserID is the ID of the user who responded.
+	UserID string
+	// Confirmed indicates whether the user confirmed (as opposed to cancelled)
+	// the slash command.
+	Confirmed bool
+	// ResponseURL is the URL that can be used to respond to the user.
+	ResponseURL string
+}
+
+// pendingConfirmation encapsulates a slash command and the event it resulted
+// in, which is being held until the user who invoked the slash command
+// confirms it.
+type pendingConfirmation struct {
+	command SlashCommand
+	event   sdk.Event
+}
+
+// confirmations holds events emitted in response to slash commands that
+// require confirmation until they are confirmed or cancelled. Events that are
+// neither confirmed nor cancelled expire after a fixed timeout.
+type confirmations struct {
+	projectsClient sdk.ProjectsClient
+	pending        *cache
+	msgTemplate    *template.Template
+}
+
+// newConfirmations returns a confirmations that holds events pending
+// confirmation for the specified timeout.
+func newConfirmations(
+	projectsClient sdk.ProjectsClient,
+	timeout time.Duration,
+) (*confirmations, error) {
+	msgTemplate, err := template.New(
+		"template",
+	).Funcs(sprig.TxtFuncMap()).Parse(confirmationMsgTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing confirmation template")
+	}
+	return &confirmations{
+		projectsClient: projectsClient,
+		pending:        newCache(timeout),
+		msgTemplate:    msgTemplate,
+	}, nil
+}
+
+// request holds the provided event, which is to be emitted in response to the
+// provided slash command, pending confirmation and returns a message, visible
+// only to the user who invoked the slash command, summarizing exactly what will
+// be emitted and offering buttons to confirm or cancel.
+func (c *confirmations) request(
+	ctx context.Context,
+	command SlashCommand,
+	event sdk.Event,
+) ([]byte, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, errors.Wrap(err, "error generating confirmation ID")
+	}
+	id := hex.EncodeToString(idBytes)
+	payload := "*Payload:* none"
+	if event.Payload != "" {
+		payload = event.Payload
+		if len(payload) > maxConfirmationPayloadBytes {
+			// Don't end in the middle of a multi-byte character
+			end := maxConfirmationPayloadBytes
+			for end > 0 && !utf8.RuneStart(payload[end]) {
+				end--
+			}
+			payload = payload[:end] + "..."
+		}
+		payload = "*Payload:*\n```" + payload + "```"
+	}
+	invocation := strings.TrimSpace(command.Command + " " + command.Text)
+	buffer := &bytes.Buffer{}
+	if err := c.msgTemplate.Execute(
+		buffer,
+		struct {
+			ID              string
+			ConfirmActionID string
+			CancelActionID  string
+			Summary         string
+			EventType       string
+			Payload         string
+			Projects        string
+			TimeoutMsg      string
+		}{
+			ID:              id,
+			ConfirmActionID: confirmActionID,
+			CancelActionID:  cancelActionID,
+			Summary:         "Please confirm `" + invocation + "`",
+			EventType:       "*Event type:* `" + event.Type + "`",
+			Payload:         payload,
+			Projects:        "*Projects:* " + c.projects(ctx, event),
+			TimeoutMsg:      "Expires in " + c.pending.ttl.String() + ".",
+		},
+	); err != nil {
+		return nil, errors.Wrap(err, "error rendering confirmation")
+	}
+	c.pending.set(id, pendingConfirmation{
+		command: command,
+		event:   event,
+	})
+	return buffer.Bytes(), nil
+}
+
+// projects returns a comma-delimited list of the IDs of all projects that
+// subscribe to the provided event. Since this is merely informative, failure to
+// list projects is logged and described in the returned text.
+func (c *confirmations) projects(ctx context.Context, event sdk.Event) string {
+	projects, err :=
+		subscribedProjects(ctx, c.projectsClient, event.Qualifiers["appID"])
+	if err != nil {
+		log.Printf("error listing projects for confirmation: %s", err)
+		return "unknown"
+	}
+	projectIDs := []string{}
+	for _, project := range projects {
+		if projectSubscribesTo(project, event) {
+			projectIDs = append(projectIDs, project.ID)
+		}
+	}
+	if len(projectIDs) == 0 {
+		return "none"
+	}
+	return strings.Join(projectIDs, ", ")
+}
+
+// get returns the unexpired pending confirmation with the specified ID, along
+// with a bool indicating whether any was found.
+func (c *confirmations) get(id string) (pendingConfirmation, bool) {
+	pending, ok := c.pending.get(id)
+	if !ok {
+		return pendingConfirmation{}, false
+	}
+	return pending.(pendingConfirmation), true
+}
+
+// take removes the unexpired pending confirmation with the specified ID and
+// returns it, along with a bool indicating whether any was found. Only one
+// caller can take any given pending confirmation, so an event cannot be
+// emitted twice by clicking a button twice.
+func (c *confirmations) take(id string) (pendingConfirmation, bool) {
+	pending, ok := c.pending.take(id)
+	if !ok {
+		return pendingConfirmation{}, false
+	}
+	return pending.(pendingConfirmation), true
+}
+
+var confirmationMsgTemplate = `{
+  "response_type": "ephemeral",
+  "text": {{ toJson .Summary }},
+  "blocks": [
+    {
+      "type": "section",
+      "text": {
+        "type": "mrkdwn",
+        "text": {{ toJson .Summary }}
+      }
+    },
+    {
+      "type": "section",
+      "text": {
+        "type": "mrkdwn",
+        "text": {{ toJson .EventType }}
+      }
+    },
+    {
+      "type": "section",
+      "text": {
+        "type": "mrkdwn",
+        "text": {{ toJson .Payload }}
+      }
+    },
+    {
+      "type": "section",
+      "text": {
+        "type": "mrkdwn",
+        "text": {{ toJson .Projects }}
+      }
+    },
+    {
+      "type": "actions",
+      "elements": [
+        {
+          "type": "button",
+          "style": "danger",
+          "action_id": {{ toJson .ConfirmActionID }},
+          "value": {{ toJson .ID }},
+          "text": {
+            "type": "plain_text",
+            "text": "Confirm"
+          }
+        },
+        {
+          "type": "button",
+          "action_id": {{ toJson .CancelActionID }},
+          "value": {{ toJson .ID }},
+          "text": {
+            "type": "plain_text",
+            "text": "Cancel"
+          }
+        }
+      ]
+    },
+    {
+      "type": "context",
+      "elements": [
+        {
+          "type": "mrkdwn",
+          "text": {{ toJson .TimeoutMsg }}
+        }
+      ]
+    }
+  ]
+}`
@@ -0,0 +1,161 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfirmations(t *testing.T) {
+	c, err := newConfirmations(&sdkTesting.MockProjectsClient{}, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, c.projectsClient)
+	require.NotNil(t, c.pending)
+	require.Equal(t, time.Minute, c.pending.ttl)
+	require.NotNil(t, c.msgTemplate)
+}
+
+func TestConfirmationsRequest(t *testing.T) {
+	testCommand := SlashCommand{
+		Command:  "/deploy",
+		APIAppID: "control-app",
+		UserID:   "86",
+		Text:     "prod",
+	}
+	testEvent := newEvent(
+		origin{
+			AppID:     "control-app",
+			ChannelID: "cone-of-silence",
+		},
+		"deploy",
+		strings.Repeat("x", maxConfirmationPayloadBytes+1),
+	)
+	testCases := []struct {
+		name           string
+		projectsClient sdk.ProjectsClient
+		assertions     func(*confirmations, string)
+	}{
+		{
+			name: "error listing projects",
+			projectsClient: &sdkTesting.MockProjectsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.ProjectsSelector,
+					*meta.ListOptions,
+				) (sdk.ProjectList, error) {
+					return sdk.ProjectList{}, errors.New("something went wrong")
+				},
+			},
+			assertions: func(_ *confirmations, msg string) {
+				require.Contains(t, msg, "*Projects:* unknown")
+			},
+		},
+		{
+			name: "success",
+			projectsClient: &sdkTesting.MockProjectsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.ProjectsSelector,
+					*meta.ListOptions,
+				) (sdk.ProjectList, error) {
+					return sdk.ProjectList{
+						Items: []sdk.Project{
+							testProject("deployer", "control-app"),
+							testProject("bystander", "kaos-app"),
+						},
+					}, nil
+				},
+			},
+			assertions: func(c *confirmations, msg string) {
+				require.Contains(t, msg, "Please confirm `/deploy prod`")
+				require.Contains(t, msg, "*Event type:* `deploy`")
+				require.Contains(t, msg, "*Projects:* deployer")
+				require.NotContains(t, msg, "bystander")
+				// The payload should have been truncated
+				require.Contains(
+					t,
+					msg,
+					strings.Repeat("x", maxConfirmationPayloadBytes)+"...",
+				)
+				require.NotContains(
+					t,
+					msg,
+					strings.Repeat("x", maxConfirmationPayloadBytes+1),
+				)
+				// The event should be pending
+				require.Len(t, c.pending.entries, 1)
+				for id := range c.pending.entries {
+					require.Contains(t, msg, id)
+					pending, ok := c.get(id)
+					require.True(t, ok)
+					require.Equal(t, testCommand, pending.command)
+					require.Equal(t, testEvent, pending.event)
+					_, ok = c.take(id)
+					require.True(t, ok)
+					_, ok = c.get(id)
+					require.False(t, ok)
+				}
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			c, err := newConfirmations(testCase.projectsClient, time.Minute)
+			require.NoError(t, err)
+			msgBytes, err := c.request(context.Background(), testCommand, testEvent)
+			require.NoError(t, err)
+			// Test that the message is valid JSON
+			msg := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(msgBytes, &msg))
+			require.Equal(t, responseTypeEphemeral, msg["response_type"])
+			require.Contains(t, string(msgBytes), confirmActionID)
+			require.Contains(t, string(msgBytes), cancelActionID)
+			testCase.assertions(c, string(msgBytes))
+		})
+	}
+}
+
+func TestConfirmationsRequestWithMultiByteCharacters(t *testing.T) {
+	c, err := newConfirmations(
+		&sdkTesting.MockProjectsClient{
+			ListFn: func(
+				context.Context,
+				*sdk.ProjectsSelector,
+				*meta.ListOptions,
+			) (sdk.ProjectList, error) {
+				return sdk.ProjectList{}, nil
+			},
+		},
+		time.Minute,
+	)
+	require.NoError(t, err)
+	// Each "é" is two bytes, so naively keeping the first
+	// maxConfirmationPayloadBytes bytes would end in the middle of one
+	payload := "x" + strings.Repeat("é", maxConfirmationPayloadBytes/2)
+	msgBytes, err := c.request(
+		context.Background(),
+		SlashCommand{
+			Command:  "/deploy",
+			APIAppID: "control-app",
+			UserID:   "86",
+		},
+		newEvent(origin{AppID: "control-app"}, "deploy", payload),
+	)
+	require.NoError(t, err)
+	require.True(t, utf8.Valid(msgBytes))
+	require.True(t, json.Valid(msgBytes), string(msgBytes))
+	require.Contains(
+		t,
+		string(msgBytes),
+		"x"+strings.Repeat("é", maxConfirmationPayloadBytes/2-1)+"...",
+	)
+}
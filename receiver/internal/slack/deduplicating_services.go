@@ -0,0 +1,116 @@
+package slack
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DeduplicationConfig encapsulates configuration for the deduplication of
+// requests from Slack.
+type DeduplicationConfig struct {
+	// TTL specifies how long the responses to requests are remembered. Slack
+	// retries a request up to three times over the course of roughly five
+	// minutes, so this should be longer than that. If not specified, a default
+	// of ten minutes is used.
+	TTL time.Duration
+}
+
+type deduplicatingSlashCommandService struct {
+	SlashCommandService
+	deduplicator *deduplicator
+}
+
+// NewDeduplicatingSlashCommandService returns an implementation of the
+// SlashCommandService interface that delegates to the provided
+// SlashCommandService unless a slash command with the same trigger ID was
+// recently handled, in which case the original acknowledgement is returned
+// instead.
+func NewDeduplicatingSlashCommandService(
+	service SlashCommandService,
+	config DeduplicationConfig,
+) SlashCommandService {
+	return &deduplicatingSlashCommandService{
+		SlashCommandService: service,
+		deduplicator:        newDeduplicator(dedupeTTL(config)),
+	}
+}
+
+func (d *deduplicatingSlashCommandService) Handle(
+	ctx context.Context,
+	command SlashCommand,
+) ([]byte, error) {
+	var key string
+	if command.TriggerID != "" {
+		key = command.APIAppID + ":" + command.TriggerID
+	}
+	response, duplicate, err := d.deduplicator.do(
+		ctx,
+		key,
+		func() ([]byte, error) {
+			return d.SlashCommandService.Handle(ctx, command)
+		},
+	)
+	if duplicate {
+		recordOutcome(ctx, outcomeDuplicate)
+		log.Printf(
+			"command %q for app %q with trigger ID %q was already handled",
+			command.Command,
+			command.APIAppID,
+			command.TriggerID,
+		)
+	}
+	return response, err
+}
+
+type deduplicatingEventsAPIService struct {
+	EventsAPIService
+	deduplicator *deduplicator
+}
+
+// NewDeduplicatingEventsAPIService returns an implementation of the
+// EventsAPIService interface that delegates to the provided EventsAPIService
+// unless an Events API callback with the same event ID was recently handled, in
+// which case the original response is returned instead.
+func NewDeduplicatingEventsAPIService(
+	service EventsAPIService,
+	config DeduplicationConfig,
+) EventsAPIService {
+	return &deduplicatingEventsAPIService{
+		EventsAPIService: service,
+		deduplicator:     newDeduplicator(dedupeTTL(config)),
+	}
+}
+
+func (d *deduplicatingEventsAPIService) Handle(
+	ctx context.Context,
+	envelope EventsAPIEnvelope,
+) ([]byte, error) {
+	var key string
+	if envelope.EventID != "" {
+		key = envelope.APIAppID + ":" + envelope.EventID
+	}
+	response, duplicate, err := d.deduplicator.do(
+		ctx,
+		key,
+		func() ([]byte, error) {
+			return d.EventsAPIService.Handle(ctx, envelope)
+		},
+	)
+	if duplicate {
+		log.Printf(
+			"Events API callback for app %q with event ID %q was already handled",
+			envelope.APIAppID,
+			envelope.EventID,
+		)
+	}
+	return response, err
+}
+
+// dedupeTTL returns the TTL from the provided configuration or a default.
+func dedupeTTL(config DeduplicationConfig) time.Duration {
+	if config.TTL <= 0 {
+		return 10 * time.Minute
+	}
+	return config.TTL
+}
@@ -0,0 +1,100 @@
+package slack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeduplicatingSlashCommandService(t *testing.T) {
+	s := NewDeduplicatingSlashCommandService(
+		&mockSlashCommandService{},
+		DeduplicationConfig{},
+	)
+	svc, ok := s.(*deduplicatingSlashCommandService)
+	require.True(t, ok)
+	require.NotNil(t, svc.SlashCommandService)
+	require.NotNil(t, svc.deduplicator)
+	require.Equal(t, 10*time.Minute, svc.deduplicator.responses.ttl)
+}
+
+func TestDeduplicatingSlashCommandServiceHandle(t *testing.T) {
+	calls := 0
+	service := NewDeduplicatingSlashCommandService(
+		&mockSlashCommandService{
+			HandleFn: func(context.Context, SlashCommand) ([]byte, error) {
+				calls++
+				return []byte("ack"), nil
+			},
+		},
+		DeduplicationConfig{TTL: time.Minute},
+	)
+	command := SlashCommand{
+		Command:   "/deploy",
+		APIAppID:  "control-app",
+		TriggerID: "13345224609.738474920.8088930838d88f008e0",
+	}
+	for i := 0; i < 3; i++ {
+		ctx, recorder := withOutcomeRecorder(context.Background())
+		response, err := service.Handle(ctx, command)
+		require.NoError(t, err)
+		require.Equal(t, "ack", string(response))
+		// Retries should be recorded as such
+		if i > 0 {
+			require.Equal(t, outcomeDuplicate, recorder.outcome)
+		} else {
+			require.Empty(t, recorder.outcome)
+		}
+	}
+	require.Equal(t, 1, calls)
+	// The same trigger ID for a different app is not a duplicate
+	command.APIAppID = "kaos-app"
+	_, err := service.Handle(context.Background(), command)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestNewDeduplicatingEventsAPIService(t *testing.T) {
+	s := NewDeduplicatingEventsAPIService(
+		&mockEventsAPIService{},
+		DeduplicationConfig{TTL: time.Minute},
+	)
+	svc, ok := s.(*deduplicatingEventsAPIService)
+	require.True(t, ok)
+	require.NotNil(t, svc.EventsAPIService)
+	require.NotNil(t, svc.deduplicator)
+	require.Equal(t, time.Minute, svc.deduplicator.responses.ttl)
+}
+
+func TestDeduplicatingEventsAPIServiceHandle(t *testing.T) {
+	calls := 0
+	service := NewDeduplicatingEventsAPIService(
+		&mockEventsAPIService{
+			HandleFn: func(context.Context, EventsAPIEnvelope) ([]byte, error) {
+				calls++
+				return nil, nil
+			},
+		},
+		DeduplicationConfig{},
+	)
+	envelope := EventsAPIEnvelope{
+		Type:     eventsAPITypeEventCallback,
+		APIAppID: "control-app",
+		EventID:  "Ev08MFMKH6",
+	}
+	for i := 0; i < 3; i++ {
+		_, err := service.Handle(context.Background(), envelope)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, calls)
+	// Requests without an event ID, e.g. URL verification, are never
+	// deduplicated
+	envelope = EventsAPIEnvelope{Type: eventsAPITypeURLVerification}
+	for i := 0; i < 2; i++ {
+		_, err := service.Handle(context.Background(), envelope)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, calls)
+}
@@ -0,0 +1,94 @@
+package slack
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// deduplicator remembers the outcome of recently handled requests so that
+// duplicates of those requests, e.g. retries sent by Slack when it thinks a
+// request has timed out, can be answered without handling them again.
+// Duplicates that arrive while the original request is still being handled wait
+// for its outcome.
+type deduplicator struct {
+	mu        sync.Mutex
+	inFlight  map[string]*dedupedCall
+	responses *cache
+}
+
+// dedupedCall represents the handling of a request that may be in progress.
+type dedupedCall struct {
+	done     chan struct{}
+	response []byte
+	err      error
+}
+
+// newDeduplicator returns a deduplicator that remembers responses for the
+// specified TTL.
+func newDeduplicator(ttl time.Duration) *deduplicator {
+	return &deduplicator{
+		inFlight:  map[string]*dedupedCall{},
+		responses: newCache(ttl),
+	}
+}
+
+// do invokes the provided function and returns its response unless a request
+// with the same key was handled recently or is currently being handled, in
+// which case the response to that request is returned instead. The bool
+// returned indicates whether the request was a duplicate. Errors are not
+// remembered, so a request that failed may be retried. An empty key disables
+// deduplication.
+func (d *deduplicator) do(
+	ctx context.Context,
+	key string,
+	fn func() ([]byte, error),
+) ([]byte, bool, error) {
+	if key == "" {
+		response, err := fn()
+		return response, false, err
+	}
+	d.mu.Lock()
+	if response, ok := d.responses.get(key); ok {
+		d.mu.Unlock()
+		return response.([]byte), true, nil
+	}
+	if call, ok := d.inFlight[key]; ok {
+		d.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.response, true, call.err
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		}
+	}
+	call := &dedupedCall{
+		done: make(chan struct{}),
+	}
+	d.inFlight[key] = call
+	d.mu.Unlock()
+	call.response, call.err = fn()
+	d.mu.Lock()
+	if call.err == nil {
+		d.responses.set(key, call.response)
+	}
+	delete(d.inFlight, key)
+	d.mu.Unlock()
+	close(call.done)
+	return call.response, false, call.err
+}
+
+// logRetry logs the provided request if Slack has marked it as a retry of an
+// earlier request.
+func logRetry(r *http.Request) {
+	if retryNum := r.Header.Get("X-Slack-Retry-Num"); retryNum != "" {
+		log.Printf(
+			"received retry #%s of request to %s; reason: %q",
+			retryNum,
+			r.URL.Path,
+			r.Header.Get("X-Slack-Retry-Reason"),
+		)
+	}
+}
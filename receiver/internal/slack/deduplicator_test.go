@@ -0,0 +1,89 @@
+package slack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeduplicator(t *testing.T) {
+	d := newDeduplicator(time.Minute)
+	require.NotNil(t, d.inFlight)
+	require.NotNil(t, d.responses)
+	require.Equal(t, time.Minute, d.responses.ttl)
+}
+
+func TestDeduplicatorDo(t *testing.T) {
+	calls := 0
+	fn := func() ([]byte, error) {
+		calls++
+		return []byte("ack"), nil
+	}
+	d := newDeduplicator(time.Minute)
+
+	// Requests without a key are never deduplicated
+	for i := 0; i < 2; i++ {
+		response, duplicate, err := d.do(context.Background(), "", fn)
+		require.NoError(t, err)
+		require.False(t, duplicate)
+		require.Equal(t, "ack", string(response))
+	}
+	require.Equal(t, 2, calls)
+
+	// Errors should not be remembered
+	_, duplicate, err := d.do(
+		context.Background(),
+		"foo",
+		func() ([]byte, error) {
+			return nil, errors.New("something went wrong")
+		},
+	)
+	require.Error(t, err)
+	require.False(t, duplicate)
+
+	// Successful responses should be remembered
+	response, duplicate, err := d.do(context.Background(), "foo", fn)
+	require.NoError(t, err)
+	require.False(t, duplicate)
+	require.Equal(t, "ack", string(response))
+	response, duplicate, err = d.do(context.Background(), "foo", fn)
+	require.NoError(t, err)
+	require.True(t, duplicate)
+	require.Equal(t, "ack", string(response))
+	require.Equal(t, 3, calls)
+}
+
+func TestDeduplicatorDoInFlight(t *testing.T) {
+	d := newDeduplicator(time.Minute)
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	go func() {
+		_, _, _ = d.do( // nolint: errcheck
+			context.Background(),
+			"foo",
+			func() ([]byte, error) {
+				close(started)
+				<-finish
+				return []byte("ack"), nil
+			},
+		)
+	}()
+	<-started
+
+	// A duplicate whose context is canceled should give up waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, duplicate, err := d.do(ctx, "foo", nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.True(t, duplicate)
+
+	// Otherwise, a duplicate should wait for the original's response
+	close(finish)
+	response, duplicate, err := d.do(context.Background(), "foo", nil)
+	require.NoError(t, err)
+	require.True(t, duplicate)
+	require.Equal(t, "ack", string(response))
+}
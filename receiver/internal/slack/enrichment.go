@@ -0,0 +1,216 @@
+package slack
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/pkg/errors"
+)
+
+// enrichmentTimeout is how long each lookup of a Slack user or channel may
+// take. Slack gives up on slash commands that aren't acknowledged within three
+// seconds, so lookups that are slow to complete must be abandoned quickly.
+const enrichmentTimeout = time.Second
+
+// enrichmentFailureTTL is how long a failure to look up a Slack user or channel
+// is remembered. Until it expires, the lookup isn't retried, so a lookup that
+// keeps failing, e.g. because the App lacks a required scope, doesn't delay
+// every slash command.
+const enrichmentFailureTTL = time.Minute
+
+// slackUser encapsulates details of a Slack user that events may be enriched
+// with.
+type slackUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName,omitempty"`
+	RealName    string `json:"realName,omitempty"`
+	Email       string `json:"email,omitempty"`
+	TimeZone    string `json:"timeZone,omitempty"`
+}
+
+// slackChannel encapsulates details of a Slack channel that events may be
+// enriched with.
+type slackChannel struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	IsPrivate bool   `json:"isPrivate"`
+}
+
+// enricher looks up details of Slack users and channels using the Slack Web
+// API so that events can be enriched with them. Details, and failures to look
+// them up, are cached to avoid calling Slack for every event.
+type enricher struct {
+	apiClient slack.APIClient
+	users     *cache
+	channels  *cache
+	// failures records recent failed lookups.
+	failures *cache
+	// missingScopes records the Apps and API methods for which a missing scope
+	// has already been logged.
+	missingScopes sync.Map
+	// timeout is how long each lookup may take.
+	timeout time.Duration
+}
+
+// newEnricher returns an enricher that caches details of Slack users and
+// channels for the specified TTL.
+func newEnricher(apiClient slack.APIClient, ttl time.Duration) *enricher {
+	return &enricher{
+		apiClient: apiClient,
+		users:     newCache(ttl),
+		channels:  newCache(ttl),
+		failures:  newCache(enrichmentFailureTTL),
+		timeout:   enrichmentTimeout,
+	}
+}
+
+// enrich returns details of the specified Slack user and channel. Enrichment is
+// merely a courtesy, so failure to look up either (including failure to do so
+// within the enricher's timeout) is logged and results in nil details instead
+// of an error. A failed lookup isn't retried until enrichmentFailureTTL has
+// elapsed.
+func (e *enricher) enrich(
+	ctx context.Context,
+	app slack.App,
+	userID string,
+	channelID string,
+) (*slackUser, *slackChannel) {
+	var user *slackUser
+	var channel *slackChannel
+	var err error
+	if userID != "" {
+		if user, err = e.user(ctx, app, userID); err != nil {
+			e.logFailure(app, err)
+		}
+	}
+	if channelID != "" {
+		if channel, err = e.channel(ctx, app, channelID); err != nil {
+			e.logFailure(app, err)
+		}
+	}
+	return user, channel
+}
+
+// logFailure logs a failed lookup. A scope that the App lacks remains missing
+// until the App is reinstalled, so this is logged only once per App and API
+// method.
+func (e *enricher) logFailure(app slack.App, err error) {
+	var apiErr *slack.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "missing_scope" {
+		log.Printf("error enriching event: %s", err)
+		return
+	}
+	if _, logged := e.missingScopes.LoadOrStore(
+		app.AppID+":"+apiErr.Method,
+		struct{}{},
+	); logged {
+		return
+	}
+	log.Printf(
+		"error enriching event: app %q lacks the scope required to call %s; "+
+			"events will not be enriched with the details it provides until the "+
+			"app is reinstalled with that scope",
+		app.AppID,
+		apiErr.Method,
+	)
+}
+
+// user returns details of the specified Slack user.
+func (e *enricher) user(
+	ctx context.Context,
+	app slack.App,
+	userID string,
+) (*slackUser, error) {
+	cacheKey := app.AppID + ":" + userID
+	if user, ok := e.users.get(cacheKey); ok {
+		return user.(*slackUser), nil
+	}
+	if _, ok := e.failures.get("users.info:" + cacheKey); ok {
+		return nil, nil
+	}
+	result := struct {
+		User struct {
+			Name     string `json:"name"`
+			TZ       string `json:"tz"`
+			RealName string `json:"real_name"`
+			Profile  struct {
+				DisplayName string `json:"display_name"`
+				Email       string `json:"email"`
+			} `json:"profile"`
+		} `json:"user"`
+	}{}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	if err := slack.CallWithFallback(
+		ctx,
+		e.apiClient,
+		app.APITokens(),
+		"users.info",
+		url.Values{
+			"user": []string{userID},
+		},
+		&result,
+	); err != nil {
+		e.failures.set("users.info:"+cacheKey, err)
+		return nil, errors.Wrapf(err, "error getting info for user %q", userID)
+	}
+	user := &slackUser{
+		ID:          userID,
+		Name:        result.User.Name,
+		DisplayName: result.User.Profile.DisplayName,
+		RealName:    result.User.RealName,
+		Email:       result.User.Profile.Email,
+		TimeZone:    result.User.TZ,
+	}
+	e.users.set(cacheKey, user)
+	return user, nil
+}
+
+// channel returns details of the specified Slack channel.
+func (e *enricher) channel(
+	ctx context.Context,
+	app slack.App,
+	channelID string,
+) (*slackChannel, error) {
+	cacheKey := app.AppID + ":" + channelID
+	if channel, ok := e.channels.get(cacheKey); ok {
+		return channel.(*slackChannel), nil
+	}
+	if _, ok := e.failures.get("conversations.info:" + cacheKey); ok {
+		return nil, nil
+	}
+	result := struct {
+		Channel struct {
+			Name      string `json:"name"`
+			IsPrivate bool   `json:"is_private"`
+		} `json:"channel"`
+	}{}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	if err := slack.CallWithFallback(
+		ctx,
+		e.apiClient,
+		app.APITokens(),
+		"conversations.info",
+		url.Values{
+			"channel": []string{channelID},
+		},
+		&result,
+	); err != nil {
+		e.failures.set("conversations.info:"+cacheKey, err)
+		return nil,
+			errors.Wrapf(err, "error getting info for channel %q", channelID)
+	}
+	channel := &slackChannel{
+		ID:        channelID,
+		Name:      result.Channel.Name,
+		IsPrivate: result.Channel.IsPrivate,
+	}
+	e.channels.set(cacheKey, channel)
+	return channel, nil
+}
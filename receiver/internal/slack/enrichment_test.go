@@ -0,0 +1,218 @@
+package slack
+
+// nolint: lll
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEnricher(t *testing.T) {
+	e := newEnricher(&slackTesting.MockAPIClient{}, time.Minute)
+	require.NotNil(t, e.apiClient)
+	require.NotNil(t, e.users)
+	require.Equal(t, time.Minute, e.users.ttl)
+	require.NotNil(t, e.channels)
+	require.Equal(t, time.Minute, e.channels.ttl)
+	require.NotNil(t, e.failures)
+	require.Equal(t, enrichmentFailureTTL, e.failures.ttl)
+	require.Equal(t, enrichmentTimeout, e.timeout)
+}
+
+func TestEnricherEnrich(t *testing.T) {
+	testCases := []struct {
+		name       string
+		apiClient  slack.APIClient
+		assertions func(*enricher, *slackUser, *slackChannel)
+	}{
+		{
+			name: "errors are not fatal",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					context.Context,
+					string,
+					string,
+					interface{},
+					interface{},
+				) error {
+					return errors.New("something went wrong")
+				},
+			},
+			assertions: func(e *enricher, user *slackUser, channel *slackChannel) {
+				require.Nil(t, user)
+				require.Nil(t, channel)
+				// Failures should have been cached, so no further API calls should be
+				// made
+				e.apiClient = &slackTesting.MockAPIClient{}
+				user, channel = e.enrich(
+					context.Background(),
+					slack.App{AppID: "control-app"},
+					"86",
+					"cone-of-silence",
+				)
+				require.Nil(t, user)
+				require.Nil(t, channel)
+			},
+		},
+		{
+			name: "missing scope",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					_ string,
+					method string,
+					_ interface{},
+					_ interface{},
+				) error {
+					return &slack.APIError{
+						Method: method,
+						Code:   "missing_scope",
+					}
+				},
+			},
+			assertions: func(e *enricher, user *slackUser, channel *slackChannel) {
+				require.Nil(t, user)
+				require.Nil(t, channel)
+				// The missing scope should have been recorded as logged for each
+				// method
+				_, ok := e.missingScopes.Load("control-app:users.info")
+				require.True(t, ok)
+				_, ok = e.missingScopes.Load("control-app:conversations.info")
+				require.True(t, ok)
+			},
+		},
+		{
+			name: "lookups time out",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					ctx context.Context,
+					_ string,
+					_ string,
+					_ interface{},
+					_ interface{},
+				) error {
+					_, ok := ctx.Deadline()
+					require.True(t, ok)
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+			assertions: func(e *enricher, user *slackUser, channel *slackChannel) {
+				require.Nil(t, user)
+				require.Nil(t, channel)
+				// Nothing should have been cached
+				_, ok := e.users.get("control-app:86")
+				require.False(t, ok)
+				_, ok = e.channels.get("control-app:cone-of-silence")
+				require.False(t, ok)
+			},
+		},
+		{
+			name: "success",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					token string,
+					method string,
+					args interface{},
+					result interface{},
+				) error {
+					require.Equal(t, "foo", token)
+					switch method {
+					case "users.info":
+						require.Equal(t, "86", args.(url.Values).Get("user"))
+						return json.Unmarshal(
+							[]byte(`{
+								"user": {
+									"name": "max",
+									"real_name": "Maxwell Smart",
+									"tz": "America/New_York",
+									"profile": {
+										"display_name": "Agent 86",
+										"email": "max@example.com"
+									}
+								}
+							}`),
+							result,
+						)
+					case "conversations.info":
+						require.Equal(
+							t,
+							"cone-of-silence",
+							args.(url.Values).Get("channel"),
+						)
+						return json.Unmarshal(
+							[]byte(`{"channel":{"name":"control","is_private":true}}`),
+							result,
+						)
+					}
+					require.Fail(t, "unexpected method", method)
+					return nil
+				},
+			},
+			assertions: func(e *enricher, user *slackUser, channel *slackChannel) {
+				require.Equal(
+					t,
+					&slackUser{
+						ID:          "86",
+						Name:        "max",
+						DisplayName: "Agent 86",
+						RealName:    "Maxwell Smart",
+						Email:       "max@example.com",
+						TimeZone:    "America/New_York",
+					},
+					user,
+				)
+				require.Equal(
+					t,
+					&slackChannel{
+						ID:        "cone-of-silence",
+						Name:      "control",
+						IsPrivate: true,
+					},
+					channel,
+				)
+				// Both should have been cached
+				cachedUser, ok := e.users.get("control-app:86")
+				require.True(t, ok)
+				require.Equal(t, user, cachedUser)
+				cachedChannel, ok := e.channels.get("control-app:cone-of-silence")
+				require.True(t, ok)
+				require.Equal(t, channel, cachedChannel)
+				// So no further API calls should be made
+				e.apiClient = &slackTesting.MockAPIClient{}
+				user, channel = e.enrich(
+					context.Background(),
+					slack.App{AppID: "control-app"},
+					"86",
+					"cone-of-silence",
+				)
+				require.NotNil(t, user)
+				require.NotNil(t, channel)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			e := newEnricher(testCase.apiClient, time.Minute)
+			e.timeout = 10 * time.Millisecond
+			user, channel := e.enrich(
+				context.Background(),
+				slack.App{
+					AppID:    "control-app",
+					APIToken: "foo",
+				},
+				"86",
+				"cone-of-silence",
+			)
+			testCase.assertions(e, user, channel)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package slack
+
+// EventsAPIEnvelope encapsulates details of a request from the Slack Events
+// API. Depending on its Type, an envelope either carries a URL verification
+// challenge or a wrapped Event.
+//
+// nolint: lll
+type EventsAPIEnvelope struct {
+	Token        string         `json:"token"`         // e.g. Jhj5dZrVaK7ZwHHjRyZWjbDl
+	Challenge    string         `json:"challenge"`     // e.g. 3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P
+	Type         string         `json:"type"`          // e.g. event_callback
+	TeamID       string         `json:"team_id"`       // e.g. T0001
+	EnterpriseID string         `json:"enterprise_id"` // e.g. E0001
+	APIAppID     string         `json:"api_app_id"`    // e.g. A123456
+	EventID      string         `json:"event_id"`      // e.g. Ev08MFMKH6
+	EventTime    int64          `json:"event_time"`    // e.g. 1234567890
+	Event        EventsAPIEvent `json:"event"`
+}
+
+// EventsAPIEvent encapsulates details of an Event wrapped by an
+// EventsAPIEnvelope. Only fields common to the message-like events this
+// gateway handles are included.
+//
+// nolint: lll
+type EventsAPIEvent struct {
+	Type        string `json:"type"`         // e.g. app_mention
+	Subtype     string `json:"subtype"`      // e.g. bot_message
+	User        string `json:"user"`         // e.g. U2147483697
+	BotID       string `json:"bot_id"`       // e.g. B123456
+	Channel     string `json:"channel"`      // e.g. C2147483705
+	ChannelType string `json:"channel_type"` // e.g. channel
+	Text        string `json:"text"`         // e.g. <@U0LAN0Z89> deploy prod
+	TS          string `json:"ts"`           // e.g. 1515449522.000016
+	ThreadTS    string `json:"thread_ts"`    // e.g. 1515449438.000011
+}
+
+const (
+	eventsAPITypeURLVerification = "url_verification"
+	eventsAPITypeEventCallback   = "event_callback"
+)
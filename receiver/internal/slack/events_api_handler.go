@@ -0,0 +1,45 @@
+package slack
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// eventsAPIHandler is an implementation of the http.Handler interface that can
+// handle requests from the Slack Events API by delegating to a
+// transport-agnostic EventsAPIService interface.
+type eventsAPIHandler struct {
+	service EventsAPIService
+}
+
+// NewEventsAPIHandler returns an implementation of the http.Handler interface
+// that can handle requests from the Slack Events API by delegating to a
+// transport-agnostic EventsAPIService interface.
+func NewEventsAPIHandler(service EventsAPIService) http.Handler {
+	return &eventsAPIHandler{
+		service: service,
+	}
+}
+
+func (e *eventsAPIHandler) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	logRetry(r)
+	w.Header().Set("Content-Type", "application/json")
+	envelope := EventsAPIEnvelope{}
+	if err := json.NewDecoder(r.Body).Decode(&envelope); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status": "bad request"}`)) // nolint: errcheck
+		return
+	}
+	response, err := e.service.Handle(r.Context(), envelope)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"status": "internal server error"}`)) // nolint: errcheck
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(response) // nolint: errcheck
+}
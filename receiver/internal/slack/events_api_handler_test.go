@@ -0,0 +1,98 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEventsAPIHandler(t *testing.T) {
+	handler, ok :=
+		NewEventsAPIHandler(&eventsAPIService{}).(*eventsAPIHandler)
+	require.True(t, ok)
+	require.NotNil(t, handler.service)
+}
+
+func TestEventsAPIHandlerServeHTTP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		handler    *eventsAPIHandler
+		assertions func(*http.Response)
+	}{
+		{
+			name:    "request body is not valid json",
+			body:    "just some garbage",
+			handler: &eventsAPIHandler{},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusBadRequest, r.StatusCode)
+			},
+		},
+		{
+			name: "error invoking service",
+			body: `{"type":"event_callback"}`,
+			handler: &eventsAPIHandler{
+				service: &mockEventsAPIService{
+					HandleFn: func(
+						context.Context,
+						EventsAPIEnvelope,
+					) ([]byte, error) {
+						return nil, errors.New("something went wrong")
+					},
+				},
+			},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusInternalServerError, r.StatusCode)
+			},
+		},
+		{
+			name: "success",
+			body: `{"type":"event_callback"}`,
+			handler: &eventsAPIHandler{
+				service: &mockEventsAPIService{
+					HandleFn: func(
+						_ context.Context,
+						envelope EventsAPIEnvelope,
+					) ([]byte, error) {
+						require.Equal(t, eventsAPITypeEventCallback, envelope.Type)
+						return nil, nil
+					},
+				},
+			},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusOK, r.StatusCode)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testRequest, err := http.NewRequest(
+				http.MethodPost,
+				"/events",
+				bytes.NewBufferString(testCase.body),
+			)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			testCase.handler.ServeHTTP(rr, testRequest)
+			res := rr.Result()
+			defer res.Body.Close()
+			testCase.assertions(res)
+		})
+	}
+}
+
+type mockEventsAPIService struct {
+	HandleFn func(context.Context, EventsAPIEnvelope) ([]byte, error)
+}
+
+func (m *mockEventsAPIService) Handle(
+	ctx context.Context,
+	envelope EventsAPIEnvelope,
+) ([]byte, error) {
+	return m.HandleFn(ctx, envelope)
+}
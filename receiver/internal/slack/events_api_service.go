@@ -0,0 +1,156 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/pkg/errors"
+)
+
+// leadingMentionRegex matches a user mention at the start of a message, e.g.
+// the mention of this gateway's bot user in an app_mention event.
+var leadingMentionRegex = regexp.MustCompile(`^\s*<@[^>]+>\s*`)
+
+// EventsAPIService is an interface for components that can handle requests
+// from the Slack Events API. Implementations of this interface are
+// transport-agnostic.
+type EventsAPIService interface {
+	// Handle handles a request from the Slack Events API.
+	Handle(context.Context, EventsAPIEnvelope) ([]byte, error)
+}
+
+// EventsAPIServiceConfig encapsulates configuration for the Events API
+// service.
+type EventsAPIServiceConfig struct {
+	// SlackApps is the set of Slack App configurations, which may be reloaded.
+	SlackApps *slack.Apps
+	// TokenStore optionally specifies where to find the bot tokens granted when
+	// Apps were installed into individual workspaces using OAuth. If specified,
+	// those tokens are used in place of the API tokens in the App configurations.
+	TokenStore slack.TokenStore
+	// UserGroupCacheTTL specifies how long user group membership, which may be
+	// needed to evaluate policies, and users' email addresses, which may be
+	// needed to map Slack users to Brigade users, are cached. If not specified,
+	// a default of five minutes is used.
+	UserGroupCacheTTL time.Duration
+}
+
+type eventsAPIService struct {
+	config       EventsAPIServiceConfig
+	eventsClient sdk.EventsClient
+	access       *accessChecker
+}
+
+// NewEventsAPIService returns an implementation of the EventsAPIService
+// interface for handling requests from the Slack Events API. Events are only
+// emitted if the same policies and identity mapping that apply to slash
+// commands permit it.
+func NewEventsAPIService(
+	projectsClient sdk.ProjectsClient,
+	eventsClient sdk.EventsClient,
+	apiClient slack.APIClient,
+	config EventsAPIServiceConfig,
+) EventsAPIService {
+	if config.UserGroupCacheTTL <= 0 {
+		config.UserGroupCacheTTL = 5 * time.Minute
+	}
+	return &eventsAPIService{
+		config:       config,
+		eventsClient: eventsClient,
+		access: newAccessChecker(
+			projectsClient,
+			apiClient,
+			config.UserGroupCacheTTL,
+		),
+	}
+}
+
+func (e *eventsAPIService) Handle(
+	ctx context.Context,
+	envelope EventsAPIEnvelope,
+) ([]byte, error) {
+	switch envelope.Type {
+	case eventsAPITypeURLVerification:
+		// Slack sends this once, when the request URL is first configured, to
+		// confirm that we're really the owner of the endpoint.
+		response, err := json.Marshal(
+			struct {
+				Challenge string `json:"challenge"`
+			}{
+				Challenge: envelope.Challenge,
+			},
+		)
+		return response, errors.Wrap(err, "error rendering challenge response")
+	case eventsAPITypeEventCallback:
+	default:
+		log.Printf("ignoring Events API request of type %q", envelope.Type)
+		return nil, nil
+	}
+
+	// Messages from bots, including our own status updates, are ignored.
+	// Otherwise, we could easily find ourselves in an infinite loop. Messages
+	// with a subtype (edits, deletions, channel joins, etc.) are ignored as well.
+	if envelope.Event.BotID != "" || envelope.Event.Subtype != "" {
+		return nil, nil
+	}
+
+	eventType := envelope.Event.Type
+	text := envelope.Event.Text
+	switch eventType {
+	case "app_mention":
+		// The bot user is always mentioned at the start of the message. Strip it
+		// out so the payload contains only what was said TO the bot.
+		text = leadingMentionRegex.ReplaceAllString(text, "")
+	case "message":
+		// Slack subscribes apps to message events separately for each kind of
+		// conversation, e.g. message.channels or message.im. We qualify the event
+		// type in the same way so that projects can tell them apart.
+		if suffix, ok := messageSubscriptions[envelope.Event.ChannelType]; ok {
+			eventType = fmt.Sprintf("%s.%s", eventType, suffix)
+		}
+	}
+
+	o := origin{
+		AppID:        envelope.APIAppID,
+		EnterpriseID: envelope.EnterpriseID,
+		TeamID:       envelope.TeamID,
+		ChannelID:    envelope.Event.Channel,
+		UserID:       envelope.Event.User,
+	}
+	event := newEvent(o, eventType, text)
+	app, _ := e.config.SlackApps.Get(envelope.APIAppID)
+	app, err := slack.AppForTeam(
+		ctx,
+		e.config.TokenStore,
+		app,
+		envelope.EnterpriseID,
+		envelope.TeamID,
+	)
+	if err != nil {
+		log.Println(err)
+	}
+	// There is no way to tell the user that they were denied, so denials are
+	// only logged.
+	if permitted, err := e.access.permits(ctx, app, o, &event); !permitted {
+		return nil, err
+	}
+	if _, err := e.eventsClient.Create(ctx, event, nil); err != nil {
+		return nil, errors.Wrap(err, "error emitting event(s) into Brigade")
+	}
+	return nil, nil
+}
+
+// messageSubscriptions maps the channel types found in message events to the
+// suffixes of the corresponding Events API subscriptions.
+var messageSubscriptions = map[string]string{
+	"channel": "channels",
+	"group":   "groups",
+	"im":      "im",
+	"mpim":    "mpim",
+}
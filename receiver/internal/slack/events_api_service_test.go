@@ -0,0 +1,254 @@
+package slack
+
+// nolint: lll
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
+	"github.com/brigadecore/brigade/sdk/v3"
+	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEventsAPIService(t *testing.T) {
+	s, ok := NewEventsAPIService(
+		// Totally unusable clients that are enough to fulfill the dependencies for
+		// this test...
+		&sdkTesting.MockProjectsClient{},
+		&sdkTesting.MockEventsClient{
+			LogsClient: &sdkTesting.MockLogsClient{},
+		},
+		&slackTesting.MockAPIClient{},
+		EventsAPIServiceConfig{},
+	).(*eventsAPIService)
+	require.True(t, ok)
+	require.NotNil(t, s.eventsClient)
+	require.NotNil(t, s.access)
+	require.Equal(t, 5*time.Minute, s.config.UserGroupCacheTTL)
+}
+
+func TestEventsAPIServiceHandle(t *testing.T) {
+	testEnvelope := EventsAPIEnvelope{
+		Type:     eventsAPITypeEventCallback,
+		APIAppID: "control-app",
+		TeamID:   "control",
+		Event: EventsAPIEvent{
+			Type:    "app_mention",
+			Channel: "cone-of-silence",
+			User:    "86",
+			Text:    "<@U0LAN0Z89> would you believe",
+		},
+	}
+	testCases := []struct {
+		name       string
+		envelope   func() EventsAPIEnvelope
+		service    *eventsAPIService
+		assertions func([]byte, error)
+	}{
+		{
+			name: "url verification",
+			envelope: func() EventsAPIEnvelope {
+				return EventsAPIEnvelope{
+					Type:      eventsAPITypeURLVerification,
+					Challenge: "sorry about that, chief",
+				}
+			},
+			service: &eventsAPIService{},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				obj := map[string]string{}
+				err = json.Unmarshal(response, &obj)
+				require.NoError(t, err)
+				require.Equal(t, "sorry about that, chief", obj["challenge"])
+			},
+		},
+		{
+			name: "unknown envelope type",
+			envelope: func() EventsAPIEnvelope {
+				return EventsAPIEnvelope{Type: "app_rate_limited"}
+			},
+			service: &eventsAPIService{},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name: "message from a bot",
+			envelope: func() EventsAPIEnvelope {
+				envelope := testEnvelope
+				envelope.Event.BotID = "B123456"
+				return envelope
+			},
+			service: &eventsAPIService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Fail(t, "no event should have been created")
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name: "error creating brigade event",
+			envelope: func() EventsAPIEnvelope {
+				return testEnvelope
+			},
+			service: &eventsAPIService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						return sdk.EventList{}, errors.New("something went wrong")
+					},
+				},
+			},
+			assertions: func(_ []byte, err error) {
+				require.Error(t, err)
+				require.Contains(
+					t,
+					err.Error(),
+					"error emitting event(s) into Brigade",
+				)
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name: "success with app mention",
+			envelope: func() EventsAPIEnvelope {
+				return testEnvelope
+			},
+			service: &eventsAPIService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Equal(t, "brigade.sh/slack", event.Source)
+						require.Equal(t, "app_mention", event.Type)
+						require.Equal(
+							t,
+							map[string]string{
+								"appID": testEnvelope.APIAppID,
+							},
+							event.Qualifiers,
+						)
+						require.Equal(
+							t,
+							map[string]string{
+								"teamID":    testEnvelope.TeamID,
+								"channelID": testEnvelope.Event.Channel,
+								"userID":    testEnvelope.Event.User,
+							},
+							event.Labels,
+						)
+						require.Equal(
+							t,
+							map[string]string{
+								"tracking": "true",
+							},
+							event.SourceState.State,
+						)
+						require.Equal(t, "would you believe", event.Payload)
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name: "success with channel message",
+			envelope: func() EventsAPIEnvelope {
+				envelope := testEnvelope
+				envelope.Event.Type = "message"
+				envelope.Event.ChannelType = "channel"
+				envelope.Event.Text = "missed it by that much"
+				return envelope
+			},
+			service: &eventsAPIService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Equal(t, "message.channels", event.Type)
+						require.Equal(t, "missed it by that much", event.Payload)
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name: "app mention denied by policy",
+			envelope: func() EventsAPIEnvelope {
+				return testEnvelope
+			},
+			service: &eventsAPIService{
+				config: EventsAPIServiceConfig{
+					SlackApps: slack.NewApps(map[string]slack.App{
+						"control-app": {
+							AppID: "control-app",
+							Policy: slack.Policy{
+								Allow: &slack.PolicyRule{
+									UserIDs: []string{"99"},
+								},
+							},
+						},
+					}),
+				},
+				access: newAccessChecker(nil, nil, time.Minute),
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Fail(t, "no event should have been created")
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := testCase.service.Handle(
+				context.Background(),
+				testCase.envelope(),
+			)
+			testCase.assertions(response, err)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/pkg/errors"
+)
+
+// identityResolver maps Slack users to Brigade users and verifies that those
+// Brigade users hold the project-level roles required to trigger events. Email
+// addresses, which may be needed to map Slack users to Brigade users, are
+// looked up using the Slack Web API and cached.
+type identityResolver struct {
+	projectsClient sdk.ProjectsClient
+	apiClient      slack.APIClient
+	emails         *cache
+}
+
+// newIdentityResolver returns an identityResolver that caches Slack users'
+// email addresses for the specified TTL.
+func newIdentityResolver(
+	projectsClient sdk.ProjectsClient,
+	apiClient slack.APIClient,
+	emailCacheTTL time.Duration,
+) *identityResolver {
+	return &identityResolver{
+		projectsClient: projectsClient,
+		apiClient:      apiClient,
+		emails:         newCache(emailCacheTTL),
+	}
+}
+
+// brigadeUserID returns the ID of the Brigade user that the specified Slack
+// user maps to, according to the provided App's identity mapping, and a bool
+// indicating whether any was found.
+func (i *identityResolver) brigadeUserID(
+	ctx context.Context,
+	app slack.App,
+	slackUserID string,
+) (string, bool, error) {
+	if app.Identities == nil {
+		return "", false, nil
+	}
+	var email string
+	if app.Identities.NeedsEmail(slackUserID) {
+		var err error
+		if email, err = i.email(ctx, app, slackUserID); err != nil {
+			return "", false, err
+		}
+	}
+	brigadeUserID, ok := app.Identities.BrigadeUserID(slackUserID, email)
+	return brigadeUserID, ok, nil
+}
+
+// email returns the email address of the specified Slack user. This requires
+// the App to have been granted the users:read.email scope.
+func (i *identityResolver) email(
+	ctx context.Context,
+	app slack.App,
+	slackUserID string,
+) (string, error) {
+	cacheKey := app.AppID + ":" + slackUserID
+	if email, ok := i.emails.get(cacheKey); ok {
+		return email.(string), nil
+	}
+	result := struct {
+		User struct {
+			Profile struct {
+				Email string `json:"email"`
+			} `json:"profile"`
+		} `json:"user"`
+	}{}
+	if err := slack.CallWithFallback(
+		ctx,
+		i.apiClient,
+		app.APITokens(),
+		"users.info",
+		url.Values{
+			"user": []string{slackUserID},
+		},
+		&result,
+	); err != nil {
+		return "", errors.Wrapf(err, "error getting info for user %q", slackUserID)
+	}
+	i.emails.set(cacheKey, result.User.Profile.Email)
+	return result.User.Profile.Email, nil
+}
+
+// unauthorizedProjects returns the IDs of all projects that subscribe to the
+// provided event, but for which the specified Brigade user does not hold the
+// role required by the provided App's identity mapping.
+func (i *identityResolver) unauthorizedProjects(
+	ctx context.Context,
+	app slack.App,
+	event sdk.Event,
+	brigadeUserID string,
+) ([]string, error) {
+	role := sdk.RoleProjectUser
+	if app.Identities != nil && app.Identities.RequiredRole != "" {
+		role = sdk.Role(app.Identities.RequiredRole)
+	}
+	projects, err := subscribedProjects(ctx, i.projectsClient, app.AppID)
+	if err != nil {
+		return nil, err
+	}
+	unauthorized := []string{}
+	for _, project := range projects {
+		if !projectSubscribesTo(project, event) {
+			continue
+		}
+		roleAssignments, err :=
+			i.projectsClient.Authz().RoleAssignments().List(
+				ctx,
+				&sdk.ProjectRoleAssignmentsSelector{
+					ProjectID: project.ID,
+					Principal: &sdk.PrincipalReference{
+						Type: sdk.PrincipalTypeUser,
+						ID:   brigadeUserID,
+					},
+					Role: role,
+				},
+				nil,
+			)
+		if err != nil {
+			return nil, errors.Wrapf(
+				err,
+				"error listing role assignments for user %q in project %q",
+				brigadeUserID,
+				project.ID,
+			)
+		}
+		if len(roleAssignments.Items) == 0 {
+			unauthorized = append(unauthorized, project.ID)
+		}
+	}
+	return unauthorized, nil
+}
@@ -0,0 +1,246 @@
+package slack
+
+// nolint: lll
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIdentityResolver(t *testing.T) {
+	i := newIdentityResolver(
+		&sdkTesting.MockProjectsClient{},
+		&slackTesting.MockAPIClient{},
+		time.Minute,
+	)
+	require.NotNil(t, i.projectsClient)
+	require.NotNil(t, i.apiClient)
+	require.NotNil(t, i.emails)
+	require.Equal(t, time.Minute, i.emails.ttl)
+}
+
+func TestIdentityResolverBrigadeUserID(t *testing.T) {
+	testCases := []struct {
+		name       string
+		identities *slack.IdentityMapping
+		apiClient  slack.APIClient
+		assertions func(resolver *identityResolver, id string, ok bool, err error)
+	}{
+		{
+			name: "no identity mapping",
+			assertions: func(_ *identityResolver, _ string, ok bool, err error) {
+				require.NoError(t, err)
+				require.False(t, ok)
+			},
+		},
+		{
+			name: "mapped by slack user ID",
+			identities: &slack.IdentityMapping{
+				MatchEmails: true,
+				Users: []slack.UserMapping{
+					{
+						SlackUserID:   "86",
+						BrigadeUserID: "max",
+					},
+				},
+			},
+			// No API calls should be made
+			apiClient: &slackTesting.MockAPIClient{},
+			assertions: func(_ *identityResolver, id string, ok bool, err error) {
+				require.NoError(t, err)
+				require.True(t, ok)
+				require.Equal(t, "max", id)
+			},
+		},
+		{
+			name:       "error getting email",
+			identities: &slack.IdentityMapping{MatchEmails: true},
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					context.Context,
+					string,
+					string,
+					interface{},
+					interface{},
+				) error {
+					return errors.New("something went wrong")
+				},
+			},
+			assertions: func(_ *identityResolver, _ string, _ bool, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error getting info for user")
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name:       "matched by email",
+			identities: &slack.IdentityMapping{MatchEmails: true},
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					token string,
+					method string,
+					args interface{},
+					result interface{},
+				) error {
+					require.Equal(t, "foo", token)
+					require.Equal(t, "users.info", method)
+					require.Equal(t, "86", args.(url.Values).Get("user"))
+					return json.Unmarshal(
+						[]byte(`{"user":{"profile":{"email":"max@example.com"}}}`),
+						result,
+					)
+				},
+			},
+			assertions: func(
+				resolver *identityResolver,
+				id string,
+				ok bool,
+				err error,
+			) {
+				require.NoError(t, err)
+				require.True(t, ok)
+				require.Equal(t, "max@example.com", id)
+				// The email address should have been cached
+				email, cached := resolver.emails.get("control-app:86")
+				require.True(t, cached)
+				require.Equal(t, "max@example.com", email)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			resolver := newIdentityResolver(nil, testCase.apiClient, time.Minute)
+			id, ok, err := resolver.brigadeUserID(
+				context.Background(),
+				slack.App{
+					AppID:      "control-app",
+					APIToken:   "foo",
+					Identities: testCase.identities,
+				},
+				"86",
+			)
+			testCase.assertions(resolver, id, ok, err)
+		})
+	}
+}
+
+func TestIdentityResolverUnauthorizedProjects(t *testing.T) {
+	listProjectsFn := func(
+		context.Context,
+		*sdk.ProjectsSelector,
+		*meta.ListOptions,
+	) (sdk.ProjectList, error) {
+		return sdk.ProjectList{
+			Items: []sdk.Project{
+				testProject("french", "control-app"),
+				testProject("german", "kaos-app"),
+				testProject("italian", "control-app"),
+			},
+		}, nil
+	}
+	testCases := []struct {
+		name                string
+		identities          *slack.IdentityMapping
+		listRoleAssignments func(
+			context.Context,
+			*sdk.ProjectRoleAssignmentsSelector,
+			*meta.ListOptions,
+		) (sdk.ProjectRoleAssignmentList, error)
+		assertions func([]string, error)
+	}{
+		{
+			name: "error listing role assignments",
+			listRoleAssignments: func(
+				context.Context,
+				*sdk.ProjectRoleAssignmentsSelector,
+				*meta.ListOptions,
+			) (sdk.ProjectRoleAssignmentList, error) {
+				return sdk.ProjectRoleAssignmentList{},
+					errors.New("something went wrong")
+			},
+			assertions: func(_ []string, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error listing role assignments")
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name: "default role",
+			listRoleAssignments: func(
+				_ context.Context,
+				selector *sdk.ProjectRoleAssignmentsSelector,
+				_ *meta.ListOptions,
+			) (sdk.ProjectRoleAssignmentList, error) {
+				require.Equal(t, sdk.RoleProjectUser, selector.Role)
+				require.Equal(t, sdk.PrincipalTypeUser, selector.Principal.Type)
+				require.Equal(t, "max", selector.Principal.ID)
+				list := sdk.ProjectRoleAssignmentList{}
+				if selector.ProjectID == "french" {
+					list.Items = []sdk.ProjectRoleAssignment{{}}
+				}
+				return list, nil
+			},
+			assertions: func(projectIDs []string, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []string{"italian"}, projectIDs)
+			},
+		},
+		{
+			name: "custom role",
+			identities: &slack.IdentityMapping{
+				RequiredRole: string(sdk.RoleProjectDeveloper),
+			},
+			listRoleAssignments: func(
+				_ context.Context,
+				selector *sdk.ProjectRoleAssignmentsSelector,
+				_ *meta.ListOptions,
+			) (sdk.ProjectRoleAssignmentList, error) {
+				require.Equal(t, sdk.RoleProjectDeveloper, selector.Role)
+				return sdk.ProjectRoleAssignmentList{
+					Items: []sdk.ProjectRoleAssignment{{}},
+				}, nil
+			},
+			assertions: func(projectIDs []string, err error) {
+				require.NoError(t, err)
+				require.Empty(t, projectIDs)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			resolver := newIdentityResolver(
+				&sdkTesting.MockProjectsClient{
+					ListFn: listProjectsFn,
+					AuthzClient: &sdkTesting.MockProjectAuthzClient{
+						RoleAssignmentsClient: &sdkTesting.MockProjectRoleAssignmentsClient{ // nolint: lll
+							ListFn: testCase.listRoleAssignments,
+						},
+					},
+				},
+				nil,
+				time.Minute,
+			)
+			projectIDs, err := resolver.unauthorizedProjects(
+				context.Background(),
+				slack.App{
+					AppID:      "control-app",
+					Identities: testCase.identities,
+				},
+				newEvent(origin{AppID: "control-app"}, "deploy", ""),
+				"max",
+			)
+			testCase.assertions(projectIDs, err)
+		})
+	}
+}
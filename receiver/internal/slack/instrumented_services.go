@@ -0,0 +1,69 @@
+package slack
+
+import (
+	"context"
+	"time"
+
+	"github.com/brigadecore/brigade/sdk/v3"
+)
+
+type instrumentedSlashCommandService struct {
+	SlashCommandService
+}
+
+// NewInstrumentedSlashCommandService returns an implementation of the
+// SlashCommandService interface that delegates to the provided
+// SlashCommandService and counts every slash command it handles by app,
+// command, and outcome. Decorated services record outcomes other than success
+// or failure, e.g. denial, using the context they're passed.
+func NewInstrumentedSlashCommandService(
+	service SlashCommandService,
+) SlashCommandService {
+	return &instrumentedSlashCommandService{
+		SlashCommandService: service,
+	}
+}
+
+func (i *instrumentedSlashCommandService) Handle(
+	ctx context.Context,
+	command SlashCommand,
+) ([]byte, error) {
+	ctx, recorder := withOutcomeRecorder(ctx)
+	response, err := i.SlashCommandService.Handle(ctx, command)
+	result := outcome(err)
+	if err == nil && recorder.outcome != "" {
+		result = recorder.outcome
+	}
+	slashCommandsCounter.WithLabelValues(
+		command.APIAppID,
+		command.Command,
+		result,
+	).Inc()
+	return response, err
+}
+
+type instrumentedEventsClient struct {
+	sdk.EventsClient
+}
+
+// NewInstrumentedEventsClient returns an implementation of the sdk.EventsClient
+// interface that delegates to the provided sdk.EventsClient and observes how
+// long each attempt to create an event takes.
+func NewInstrumentedEventsClient(client sdk.EventsClient) sdk.EventsClient {
+	return &instrumentedEventsClient{
+		EventsClient: client,
+	}
+}
+
+func (i *instrumentedEventsClient) Create(
+	ctx context.Context,
+	event sdk.Event,
+	opts *sdk.EventCreateOptions,
+) (sdk.EventList, error) {
+	start := time.Now()
+	events, err := i.EventsClient.Create(ctx, event, opts)
+	eventCreationDurationHistogram.WithLabelValues(outcome(err)).Observe(
+		time.Since(start).Seconds(),
+	)
+	return events, err
+}
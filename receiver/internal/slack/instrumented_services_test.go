@@ -0,0 +1,97 @@
+package slack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brigadecore/brigade/sdk/v3"
+	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedSlashCommandServiceHandle(t *testing.T) {
+	service := NewInstrumentedSlashCommandService(
+		&mockSlashCommandService{
+			HandleFn: func(
+				ctx context.Context,
+				command SlashCommand,
+			) ([]byte, error) {
+				switch command.Text {
+				case "bad":
+					return nil, errors.New("something went wrong")
+				case "forbidden":
+					recordOutcome(ctx, outcomeDenied)
+				}
+				return []byte("ack"), nil
+			},
+		},
+	)
+	successCounter := slashCommandsCounter.WithLabelValues(
+		"control-app",
+		"/deploy",
+		outcomeSuccess,
+	)
+	errorCounter := slashCommandsCounter.WithLabelValues(
+		"control-app",
+		"/deploy",
+		outcomeError,
+	)
+	deniedCounter := slashCommandsCounter.WithLabelValues(
+		"control-app",
+		"/deploy",
+		outcomeDenied,
+	)
+	startingSuccessCount := testutil.ToFloat64(successCounter)
+	startingErrorCount := testutil.ToFloat64(errorCounter)
+	startingDeniedCount := testutil.ToFloat64(deniedCounter)
+	command := SlashCommand{
+		APIAppID: "control-app",
+		Command:  "/deploy",
+	}
+	response, err := service.Handle(context.Background(), command)
+	require.NoError(t, err)
+	require.Equal(t, "ack", string(response))
+	command.Text = "bad"
+	_, err = service.Handle(context.Background(), command)
+	require.Error(t, err)
+	// Outcomes recorded by decorated services should be used
+	command.Text = "forbidden"
+	_, err = service.Handle(context.Background(), command)
+	require.NoError(t, err)
+	require.Equal(t, startingSuccessCount+1, testutil.ToFloat64(successCounter))
+	require.Equal(t, startingErrorCount+1, testutil.ToFloat64(errorCounter))
+	require.Equal(t, startingDeniedCount+1, testutil.ToFloat64(deniedCounter))
+}
+
+func TestInstrumentedEventsClientCreate(t *testing.T) {
+	client := NewInstrumentedEventsClient(
+		&sdkTesting.MockEventsClient{
+			CreateFn: func(
+				context.Context,
+				sdk.Event,
+				*sdk.EventCreateOptions,
+			) (sdk.EventList, error) {
+				return sdk.EventList{}, errors.New("something went wrong")
+			},
+		},
+	)
+	sampleCount := func() uint64 {
+		metric := &dto.Metric{}
+		require.NoError(
+			t,
+			eventCreationDurationHistogram.WithLabelValues(
+				outcomeError,
+			).(prometheus.Histogram).Write(metric),
+		)
+		return metric.GetHistogram().GetSampleCount()
+	}
+	startingCount := sampleCount()
+	_, err := client.Create(context.Background(), sdk.Event{}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "something went wrong")
+	require.Equal(t, startingCount+1, sampleCount())
+}
@@ -0,0 +1,48 @@
+package slack
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// interactionHandler is an implementation of the http.Handler interface that
+// can handle interactions from Slack by delegating to a transport-agnostic
+// InteractionService interface.
+type interactionHandler struct {
+	service InteractionService
+}
+
+// NewInteractionHandler returns an implementation of the http.Handler
+// interface that can handle interactions from Slack by delegating to a
+// transport-agnostic InteractionService interface.
+func NewInteractionHandler(service InteractionService) http.Handler {
+	return &interactionHandler{
+		service: service,
+	}
+}
+
+func (i *interactionHandler) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+	// Slack sends interactions as a form with a single, JSON-encoded field.
+	interaction := Interaction{}
+	if err := json.Unmarshal(
+		[]byte(r.FormValue("payload")),
+		&interaction,
+	); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status": "bad request"}`)) // nolint: errcheck
+		return
+	}
+	response, err := i.service.Handle(r.Context(), interaction)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"status": "internal server error"}`)) // nolint: errcheck
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(response) // nolint: errcheck
+}
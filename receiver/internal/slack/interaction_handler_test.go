@@ -0,0 +1,102 @@
+package slack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInteractionHandler(t *testing.T) {
+	handler, ok :=
+		NewInteractionHandler(&interactionService{}).(*interactionHandler)
+	require.True(t, ok)
+	require.NotNil(t, handler.service)
+}
+
+func TestInteractionHandlerServeHTTP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		payload    string
+		handler    *interactionHandler
+		assertions func(*http.Response)
+	}{
+		{
+			name:    "payload is not valid json",
+			payload: "just some garbage",
+			handler: &interactionHandler{},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusBadRequest, r.StatusCode)
+			},
+		},
+		{
+			name:    "error invoking service",
+			payload: `{"type":"block_actions"}`,
+			handler: &interactionHandler{
+				service: &mockInteractionService{
+					HandleFn: func(context.Context, Interaction) ([]byte, error) {
+						return nil, errors.New("something went wrong")
+					},
+				},
+			},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusInternalServerError, r.StatusCode)
+			},
+		},
+		{
+			name:    "success",
+			payload: `{"type":"block_actions"}`,
+			handler: &interactionHandler{
+				service: &mockInteractionService{
+					HandleFn: func(
+						_ context.Context,
+						interaction Interaction,
+					) ([]byte, error) {
+						require.Equal(t, interactionTypeBlockActions, interaction.Type)
+						return nil, nil
+					},
+				},
+			},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusOK, r.StatusCode)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testRequest, err := http.NewRequest(
+				http.MethodPost,
+				"/interactions",
+				strings.NewReader(
+					url.Values{"payload": []string{testCase.payload}}.Encode(),
+				),
+			)
+			require.NoError(t, err)
+			testRequest.Header.Set(
+				"Content-Type",
+				"application/x-www-form-urlencoded",
+			)
+			rr := httptest.NewRecorder()
+			testCase.handler.ServeHTTP(rr, testRequest)
+			res := rr.Result()
+			defer res.Body.Close()
+			testCase.assertions(res)
+		})
+	}
+}
+
+type mockInteractionService struct {
+	HandleFn func(context.Context, Interaction) ([]byte, error)
+}
+
+func (m *mockInteractionService) Handle(
+	ctx context.Context,
+	interaction Interaction,
+) ([]byte, error) {
+	return m.HandleFn(ctx, interaction)
+}
@@ -0,0 +1,404 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/pkg/errors"
+)
+
+// InteractionService is an interface for components that can handle
+// interactions from Slack. Implementations of this interface are
+// transport-agnostic.
+type InteractionService interface {
+	// Handle handles an interaction from Slack.
+	Handle(context.Context, Interaction) ([]byte, error)
+}
+
+// InteractionServiceConfig encapsulates configuration for the interaction
+// service.
+type InteractionServiceConfig struct {
+	// SlackApps is the set of Slack App configurations, which may be reloaded.
+	SlackApps *slack.Apps
+	// TokenStore optionally specifies where to find the bot tokens granted when
+	// Apps were installed into individual workspaces using OAuth. If specified,
+	// those tokens are used in place of the API tokens in the App configurations.
+	TokenStore slack.TokenStore
+	// UserGroupCacheTTL specifies how long user group membership, which may be
+	// needed to evaluate policies, and users' email addresses, which may be
+	// needed to map Slack users to Brigade users, are cached. If not specified,
+	// a default of five minutes is used.
+	UserGroupCacheTTL time.Duration
+}
+
+type interactionService struct {
+	config              InteractionServiceConfig
+	eventsClient        sdk.EventsClient
+	apiClient           slack.APIClient
+	slashCommandService SlashCommandService
+	access              *accessChecker
+}
+
+// NewInteractionService returns an implementation of the InteractionService
+// interface for handling interactions from Slack. Some interactions, like the
+// submission of a form that collected a slash command's parameters, are
+// delegated to the provided SlashCommandService. Events are only emitted for
+// other interactions if the same policies and identity mapping that apply to
+// slash commands permit it.
+func NewInteractionService(
+	projectsClient sdk.ProjectsClient,
+	eventsClient sdk.EventsClient,
+	apiClient slack.APIClient,
+	slashCommandService SlashCommandService,
+	config InteractionServiceConfig,
+) InteractionService {
+	if config.UserGroupCacheTTL <= 0 {
+		config.UserGroupCacheTTL = 5 * time.Minute
+	}
+	return &interactionService{
+		config:              config,
+		eventsClient:        eventsClient,
+		apiClient:           apiClient,
+		slashCommandService: slashCommandService,
+		access: newAccessChecker(
+			projectsClient,
+			apiClient,
+			config.UserGroupCacheTTL,
+		),
+	}
+}
+
+func (i *interactionService) Handle(
+	ctx context.Context,
+	interaction Interaction,
+) ([]byte, error) {
+	switch interaction.Type {
+	case interactionTypeBlockActions:
+		return nil, i.handleBlockActions(ctx, interaction)
+	case interactionTypeViewSubmission:
+		return nil, i.handleViewSubmission(ctx, interaction)
+	case interactionTypeShortcut, interactionTypeMessageAction:
+		return nil, i.handleShortcut(ctx, interaction)
+	default:
+		log.Printf("ignoring interaction of type %q", interaction.Type)
+		return nil, nil
+	}
+}
+
+// blockActionPayload represents the payload of an event emitted in response to
+// a block action.
+type blockActionPayload struct {
+	ActionID string `json:"actionID"`
+	BlockID  string `json:"blockID"`
+	// Value is the value of the button that was clicked or the (first) option
+	// that was selected.
+	Value string `json:"value,omitempty"`
+	// Values are ALL the options that were selected. This is useful for
+	// multi-selects.
+	Values      []string           `json:"values,omitempty"`
+	ResponseURL string             `json:"responseURL,omitempty"`
+	TriggerID   string             `json:"triggerID,omitempty"`
+	Message     *interactionSource `json:"message,omitempty"`
+}
+
+// shortcutPayload represents the payload of an event emitted in response to a
+// global or message shortcut.
+type shortcutPayload struct {
+	CallbackID  string             `json:"callbackID"`
+	ResponseURL string             `json:"responseURL,omitempty"`
+	TriggerID   string             `json:"triggerID,omitempty"`
+	Message     *interactionSource `json:"message,omitempty"`
+}
+
+// interactionSource represents the details of the message an interaction
+// originated from.
+type interactionSource struct {
+	User      string `json:"user,omitempty"`
+	Text      string `json:"text,omitempty"`
+	TS        string `json:"ts,omitempty"`
+	ThreadTS  string `json:"threadTS,omitempty"`
+	Permalink string `json:"permalink,omitempty"`
+}
+
+// handleBlockActions emits one event into Brigade for each action in the
+// provided interaction. The event type is the action's action_id. Clicks of
+// the buttons used to confirm or cancel slash commands are delegated to the
+// SlashCommandService instead.
+func (i *interactionService) handleBlockActions(
+	ctx context.Context,
+	interaction Interaction,
+) error {
+	o := interactionOrigin(interaction)
+	app := i.app(ctx, interaction)
+	for _, action := range interaction.Actions {
+		if action.ActionID == confirmActionID ||
+			action.ActionID == cancelActionID {
+			if err := i.slashCommandService.HandleConfirmation(
+				ctx,
+				Confirmation{
+					ID:          action.Value,
+					UserID:      interaction.User.ID,
+					Confirmed:   action.ActionID == confirmActionID,
+					ResponseURL: interaction.ResponseURL,
+				},
+			); err != nil {
+				return err
+			}
+			continue
+		}
+		payload := blockActionPayload{
+			ActionID:    action.ActionID,
+			BlockID:     action.BlockID,
+			Values:      action.Values(),
+			ResponseURL: interaction.ResponseURL,
+			TriggerID:   interaction.TriggerID,
+		}
+		if len(payload.Values) > 0 {
+			payload.Value = payload.Values[0]
+		}
+		if msg := interaction.Message; msg != nil {
+			payload.Message = &interactionSource{
+				User:     msg.User,
+				Text:     msg.Text,
+				TS:       msg.TS,
+				ThreadTS: msg.ThreadTS,
+			}
+		}
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			return errors.Wrapf(
+				err,
+				"error marshaling payload for action %q",
+				action.ActionID,
+			)
+		}
+		event := newEvent(o, action.ActionID, string(payloadBytes))
+		permitted, err := i.access.permits(ctx, app, o, &event)
+		if err != nil {
+			return err
+		}
+		if !permitted {
+			return i.deny(ctx, interaction)
+		}
+		if _, err = i.eventsClient.Create(ctx, event, nil); err != nil {
+			return errors.Wrap(err, "error emitting event(s) into Brigade")
+		}
+	}
+	return nil
+}
+
+// handleShortcut emits an event into Brigade in response to a global or
+// message shortcut. The event type is determined by the shortcut's callback ID.
+// For message shortcuts, details of the message, including a permalink, are
+// included in the payload.
+func (i *interactionService) handleShortcut(
+	ctx context.Context,
+	interaction Interaction,
+) error {
+	o := interactionOrigin(interaction)
+	app := i.app(ctx, interaction)
+	payload := shortcutPayload{
+		CallbackID:  interaction.CallbackID,
+		ResponseURL: interaction.ResponseURL,
+		TriggerID:   interaction.TriggerID,
+	}
+	if msg := interaction.Message; msg != nil {
+		payload.Message = &interactionSource{
+			User:     msg.User,
+			Text:     msg.Text,
+			TS:       msg.TS,
+			ThreadTS: msg.ThreadTS,
+		}
+		if interaction.Channel != nil {
+			// A missing permalink is no reason not to emit the event, so errors
+			// are only logged.
+			var err error
+			if payload.Message.Permalink, err = i.getPermalink(
+				ctx,
+				app,
+				interaction.Channel.ID,
+				msg.TS,
+			); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(
+			err,
+			"error marshaling payload for shortcut %q",
+			interaction.CallbackID,
+		)
+	}
+	event := newEvent(
+		o,
+		app.ShortcutEventType(interaction.CallbackID),
+		string(payloadBytes),
+	)
+	permitted, err := i.access.permits(ctx, app, o, &event)
+	if err != nil {
+		return err
+	}
+	if !permitted {
+		return i.deny(ctx, interaction)
+	}
+	_, err = i.eventsClient.Create(ctx, event, nil)
+	return errors.Wrap(err, "error emitting event(s) into Brigade")
+}
+
+// app returns configuration for the App that the provided interaction was sent
+// to. If the App was installed into the workspace the interaction originated
+// from, or into the Enterprise Grid organization it belongs to, using OAuth,
+// the configuration uses the bot token granted by that installation. Failure
+// to look up the installation is logged and results in the App's configured
+// API tokens being used.
+func (i *interactionService) app(
+	ctx context.Context,
+	interaction Interaction,
+) slack.App {
+	o := interactionOrigin(interaction)
+	teamID := o.TeamID
+	// Interactions received via an org-wide installation are handled using that
+	// installation's token.
+	if interaction.IsEnterpriseInstall {
+		teamID = ""
+	}
+	app, _ := i.config.SlackApps.Get(interaction.APIAppID)
+	app, err := slack.AppForTeam(
+		ctx,
+		i.config.TokenStore,
+		app,
+		o.EnterpriseID,
+		teamID,
+	)
+	if err != nil {
+		log.Println(err)
+	}
+	return app
+}
+
+// deny tells the user who originated the provided interaction that they are
+// not permitted to do what they attempted, if the interaction provides a means
+// of responding to them.
+func (i *interactionService) deny(
+	ctx context.Context,
+	interaction Interaction,
+) error {
+	if interaction.ResponseURL == "" {
+		return nil
+	}
+	return i.apiClient.Respond(
+		ctx,
+		interaction.ResponseURL,
+		ephemeralMessage(interactionDeniedMsg),
+	)
+}
+
+// getPermalink retrieves a permalink for the message with the specified
+// timestamp in the specified channel.
+func (i *interactionService) getPermalink(
+	ctx context.Context,
+	app slack.App,
+	channelID string,
+	messageTS string,
+) (string, error) {
+	result := struct {
+		Permalink string `json:"permalink"`
+	}{}
+	err := slack.CallWithFallback(
+		ctx,
+		i.apiClient,
+		app.APITokens(),
+		"chat.getPermalink",
+		url.Values{
+			"channel":    []string{channelID},
+			"message_ts": []string{messageTS},
+		},
+		&result,
+	)
+	return result.Permalink, errors.Wrapf(
+		err,
+		"error getting permalink for message %q in channel %q",
+		messageTS,
+		channelID,
+	)
+}
+
+// handleViewSubmission handles the submission of modal views opened by this
+// gateway.
+func (i *interactionService) handleViewSubmission(
+	ctx context.Context,
+	interaction Interaction,
+) error {
+	if interaction.View == nil ||
+		interaction.View.CallbackID != commandFormCallbackID {
+		log.Println("ignoring submission of unrecognized view")
+		return nil
+	}
+	command := SlashCommand{}
+	if err := json.Unmarshal(
+		[]byte(interaction.View.PrivateMetadata),
+		&command,
+	); err != nil {
+		return errors.Wrap(err, "error unmarshaling slash command from view")
+	}
+	// Only the user who invoked the slash command may submit its parameters. The
+	// SlashCommandService re-evaluates policies for that user before emitting
+	// anything.
+	if command.UserID != interaction.User.ID {
+		log.Printf(
+			"denied submission of parameters for command %q for app %q by user "+
+				"%q, who did not invoke it",
+			command.Command,
+			command.APIAppID,
+			interaction.User.ID,
+		)
+		return nil
+	}
+	values := map[string]interface{}{}
+	// Each parameter gets its own input block, with the parameter's name used for
+	// both the block ID and the action ID.
+	for name, actions := range interaction.View.State.Values {
+		action, ok := actions[name]
+		if !ok {
+			continue
+		}
+		actionValues := action.Values()
+		switch {
+		case action.Type == "checkboxes":
+			values[name] = len(actionValues) > 0
+		case len(actionValues) > 0:
+			values[name] = actionValues[0]
+		default:
+			values[name] = ""
+		}
+	}
+	return i.slashCommandService.HandleSubmission(ctx, command, values)
+}
+
+// interactionDeniedMsg is the text of the message sent to a user who is not
+// permitted to do what they attempted using an interaction.
+const interactionDeniedMsg = "Sorry, you are not permitted to do that here."
+
+// interactionOrigin returns details of where, within Slack, the provided
+// interaction originated.
+func interactionOrigin(interaction Interaction) origin {
+	o := origin{
+		AppID:  interaction.APIAppID,
+		TeamID: interaction.Team.ID,
+		UserID: interaction.User.ID,
+	}
+	if interaction.Enterprise != nil {
+		o.EnterpriseID = interaction.Enterprise.ID
+	}
+	if interaction.Channel != nil {
+		o.ChannelID = interaction.Channel.ID
+	}
+	return o
+}
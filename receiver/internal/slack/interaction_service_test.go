@@ -0,0 +1,695 @@
+package slack
+
+// nolint: lll
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
+	"github.com/brigadecore/brigade/sdk/v3"
+	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInteractionService(t *testing.T) {
+	s, ok := NewInteractionService(
+		// Totally unusable clients that are enough to fulfill the dependencies for
+		// this test...
+		&sdkTesting.MockProjectsClient{},
+		&sdkTesting.MockEventsClient{
+			LogsClient: &sdkTesting.MockLogsClient{},
+		},
+		&slackTesting.MockAPIClient{},
+		&mockSlashCommandService{},
+		InteractionServiceConfig{},
+	).(*interactionService)
+	require.True(t, ok)
+	require.NotNil(t, s.eventsClient)
+	require.NotNil(t, s.apiClient)
+	require.NotNil(t, s.slashCommandService)
+	require.NotNil(t, s.access)
+	require.Equal(t, 5*time.Minute, s.config.UserGroupCacheTTL)
+}
+
+func TestInteractionServiceHandle(t *testing.T) {
+	testInteraction := Interaction{
+		Type:        interactionTypeBlockActions,
+		APIAppID:    "control-app",
+		ResponseURL: "https://hooks.slack.com/actions/1234/5678",
+		Team:        InteractionTeam{ID: "control"},
+		User:        InteractionUser{ID: "86"},
+		Channel:     &InteractionChannel{ID: "cone-of-silence"},
+		Message: &InteractionMessage{
+			Text: "Deploy to production?",
+			TS:   "1515449522.000016",
+		},
+		Actions: []InteractionAction{
+			{
+				ActionID: "approve",
+				BlockID:  "deploy-actions",
+				Type:     "button",
+				Value:    "prod",
+			},
+		},
+	}
+	testCases := []struct {
+		name        string
+		interaction Interaction
+		service     *interactionService
+		assertions  func([]byte, error)
+	}{
+		{
+			name:        "unknown interaction type",
+			interaction: Interaction{Type: "bogus"},
+			service:     &interactionService{},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name:        "error creating brigade event",
+			interaction: testInteraction,
+			service: &interactionService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						return sdk.EventList{}, errors.New("something went wrong")
+					},
+				},
+			},
+			assertions: func(_ []byte, err error) {
+				require.Error(t, err)
+				require.Contains(
+					t,
+					err.Error(),
+					"error emitting event(s) into Brigade",
+				)
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name:        "success",
+			interaction: testInteraction,
+			service: &interactionService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Equal(t, "brigade.sh/slack", event.Source)
+						require.Equal(t, "approve", event.Type)
+						require.Equal(
+							t,
+							map[string]string{
+								"appID": testInteraction.APIAppID,
+							},
+							event.Qualifiers,
+						)
+						require.Equal(
+							t,
+							map[string]string{
+								"teamID":    testInteraction.Team.ID,
+								"channelID": testInteraction.Channel.ID,
+								"userID":    testInteraction.User.ID,
+							},
+							event.Labels,
+						)
+						payload := blockActionPayload{}
+						err := json.Unmarshal([]byte(event.Payload), &payload)
+						require.NoError(t, err)
+						require.Equal(t, "approve", payload.ActionID)
+						require.Equal(t, "deploy-actions", payload.BlockID)
+						require.Equal(t, "prod", payload.Value)
+						require.Equal(t, testInteraction.ResponseURL, payload.ResponseURL)
+						require.NotNil(t, payload.Message)
+						require.Equal(t, "Deploy to production?", payload.Message.Text)
+						require.Equal(t, "1515449522.000016", payload.Message.TS)
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name: "confirmation of slash command",
+			interaction: Interaction{
+				Type:        interactionTypeBlockActions,
+				APIAppID:    "control-app",
+				ResponseURL: "https://hooks.slack.com/actions/1234/5678",
+				User:        InteractionUser{ID: "86"},
+				Actions: []InteractionAction{
+					{
+						ActionID: confirmActionID,
+						Type:     "button",
+						Value:    "abc123",
+					},
+				},
+			},
+			service: &interactionService{
+				// No events should be created directly
+				eventsClient: &sdkTesting.MockEventsClient{},
+				slashCommandService: &mockSlashCommandService{
+					HandleConfirmationFn: func(
+						_ context.Context,
+						confirmation Confirmation,
+					) error {
+						require.Equal(
+							t,
+							Confirmation{
+								ID:          "abc123",
+								UserID:      "86",
+								Confirmed:   true,
+								ResponseURL: "https://hooks.slack.com/actions/1234/5678",
+							},
+							confirmation,
+						)
+						return nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name: "submission of unrecognized view",
+			interaction: Interaction{
+				Type: interactionTypeViewSubmission,
+				View: &InteractionView{CallbackID: "bogus"},
+			},
+			service: &interactionService{},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name: "submission of command form",
+			interaction: Interaction{
+				Type: interactionTypeViewSubmission,
+				User: InteractionUser{ID: "86"},
+				View: &InteractionView{
+					CallbackID: commandFormCallbackID,
+					PrivateMetadata: `{"command":"/deploy","apiAppID":"control-app",` +
+						`"userID":"86"}`,
+					State: InteractionViewState{
+						Values: map[string]map[string]InteractionAction{
+							"environment": {
+								"environment": {
+									Type: "static_select",
+									SelectedOption: &InteractionOption{
+										Value: "prod",
+									},
+								},
+							},
+							"version": {
+								"version": {
+									Type:  "plain_text_input",
+									Value: "1.2.3",
+								},
+							},
+							"dryRun": {
+								"dryRun": {
+									Type: "checkboxes",
+								},
+							},
+						},
+					},
+				},
+			},
+			service: &interactionService{
+				slashCommandService: &mockSlashCommandService{
+					HandleSubmissionFn: func(
+						_ context.Context,
+						command SlashCommand,
+						values map[string]interface{},
+					) error {
+						require.Equal(t, "/deploy", command.Command)
+						require.Equal(t, "control-app", command.APIAppID)
+						require.Equal(t, "86", command.UserID)
+						require.Equal(
+							t,
+							map[string]interface{}{
+								"environment": "prod",
+								"version":     "1.2.3",
+								"dryRun":      false,
+							},
+							values,
+						)
+						return nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name: "submission of command form by another user",
+			interaction: Interaction{
+				Type: interactionTypeViewSubmission,
+				User: InteractionUser{ID: "99"},
+				View: &InteractionView{
+					CallbackID: commandFormCallbackID,
+					PrivateMetadata: `{"command":"/deploy","apiAppID":"control-app",` +
+						`"userID":"86"}`,
+				},
+			},
+			service: &interactionService{
+				slashCommandService: &mockSlashCommandService{
+					HandleSubmissionFn: func(
+						context.Context,
+						SlashCommand,
+						map[string]interface{},
+					) error {
+						require.Fail(t, "submission should not have been handled")
+						return nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name:        "block action denied by policy",
+			interaction: testInteraction,
+			service: &interactionService{
+				config: InteractionServiceConfig{
+					SlackApps: slack.NewApps(map[string]slack.App{
+						"control-app": {
+							AppID: "control-app",
+							Policy: slack.Policy{
+								Deny: &slack.PolicyRule{
+									UserIDs: []string{"86"},
+								},
+							},
+						},
+					}),
+				},
+				access: newAccessChecker(nil, nil, time.Minute),
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Fail(t, "no event should have been created")
+						return sdk.EventList{}, nil
+					},
+				},
+				apiClient: &slackTesting.MockAPIClient{
+					RespondFn: func(
+						_ context.Context,
+						responseURL string,
+						message []byte,
+					) error {
+						require.Equal(t, testInteraction.ResponseURL, responseURL)
+						require.Contains(t, string(message), interactionDeniedMsg)
+						return nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := testCase.service.Handle(
+				context.Background(),
+				testCase.interaction,
+			)
+			testCase.assertions(response, err)
+		})
+	}
+}
+
+func TestInteractionServiceHandleShortcut(t *testing.T) {
+	testConfig := InteractionServiceConfig{
+		SlackApps: slack.NewApps(map[string]slack.App{
+			"control-app": {
+				AppID:    "control-app",
+				APIToken: "foo",
+				Shortcuts: []slack.Shortcut{
+					{
+						CallbackID: "run_with_brigade",
+						EventType:  "run",
+					},
+				},
+			},
+		}),
+	}
+	testMessageAction := Interaction{
+		Type:       interactionTypeMessageAction,
+		APIAppID:   "control-app",
+		CallbackID: "run_with_brigade",
+		Team:       InteractionTeam{ID: "control"},
+		User:       InteractionUser{ID: "86"},
+		Channel:    &InteractionChannel{ID: "cone-of-silence"},
+		Message: &InteractionMessage{
+			Text: "make it so",
+			TS:   "1515449522.000016",
+		},
+	}
+	testCases := []struct {
+		name        string
+		interaction Interaction
+		service     *interactionService
+		assertions  func(error)
+	}{
+		{
+			name: "global shortcut",
+			interaction: Interaction{
+				Type:       interactionTypeShortcut,
+				APIAppID:   "control-app",
+				CallbackID: "new_release",
+				Team:       InteractionTeam{ID: "control"},
+				User:       InteractionUser{ID: "86"},
+			},
+			service: &interactionService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				config: testConfig,
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						// No mapping exists for this callback ID
+						require.Equal(t, "new_release", event.Type)
+						require.Equal(
+							t,
+							map[string]string{
+								"appID": "control-app",
+							},
+							event.Qualifiers,
+						)
+						require.Equal(
+							t,
+							map[string]string{
+								"teamID": "control",
+								"userID": "86",
+							},
+							event.Labels,
+						)
+						// No channel, so no tracking
+						require.Nil(t, event.SourceState)
+						payload := shortcutPayload{}
+						err := json.Unmarshal([]byte(event.Payload), &payload)
+						require.NoError(t, err)
+						require.Equal(t, "new_release", payload.CallbackID)
+						require.Nil(t, payload.Message)
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:        "message shortcut; error getting permalink",
+			interaction: testMessageAction,
+			service: &interactionService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				config: testConfig,
+				apiClient: &slackTesting.MockAPIClient{
+					CallFn: func(
+						context.Context,
+						string,
+						string,
+						interface{},
+						interface{},
+					) error {
+						return errors.New("something went wrong")
+					},
+				},
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Equal(t, "run", event.Type)
+						payload := shortcutPayload{}
+						err := json.Unmarshal([]byte(event.Payload), &payload)
+						require.NoError(t, err)
+						require.NotNil(t, payload.Message)
+						require.Equal(t, "make it so", payload.Message.Text)
+						require.Empty(t, payload.Message.Permalink)
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:        "message shortcut; error creating brigade event",
+			interaction: testMessageAction,
+			service: &interactionService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				config: testConfig,
+				apiClient: &slackTesting.MockAPIClient{
+					CallFn: func(
+						context.Context,
+						string,
+						string,
+						interface{},
+						interface{},
+					) error {
+						return nil
+					},
+				},
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						return sdk.EventList{}, errors.New("something went wrong")
+					},
+				},
+			},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(
+					t,
+					err.Error(),
+					"error emitting event(s) into Brigade",
+				)
+			},
+		},
+		{
+			name:        "message shortcut; success",
+			interaction: testMessageAction,
+			service: &interactionService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				config: testConfig,
+				apiClient: &slackTesting.MockAPIClient{
+					CallFn: func(
+						_ context.Context,
+						token string,
+						method string,
+						args interface{},
+						result interface{},
+					) error {
+						require.Equal(t, "foo", token)
+						require.Equal(t, "chat.getPermalink", method)
+						return json.Unmarshal(
+							[]byte(`{"permalink":"https://example.slack.com/archives/1"}`),
+							result,
+						)
+					},
+				},
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Equal(t, "run", event.Type)
+						require.Equal(
+							t,
+							"cone-of-silence",
+							event.Labels["channelID"],
+						)
+						payload := shortcutPayload{}
+						err := json.Unmarshal([]byte(event.Payload), &payload)
+						require.NoError(t, err)
+						require.Equal(
+							t,
+							"https://example.slack.com/archives/1",
+							payload.Message.Permalink,
+						)
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:        "message shortcut; app installed into team",
+			interaction: testMessageAction,
+			service: &interactionService{
+				access: newAccessChecker(nil, nil, time.Minute),
+				config: InteractionServiceConfig{
+					SlackApps: testConfig.SlackApps,
+					TokenStore: &slackTesting.MockTokenStore{
+						GetFn: func(
+							context.Context,
+							string,
+							string,
+							string,
+						) (slack.Installation, bool, error) {
+							return slack.Installation{BotToken: "xoxb-control"}, true, nil
+						},
+					},
+				},
+				apiClient: &slackTesting.MockAPIClient{
+					CallFn: func(
+						_ context.Context,
+						token string,
+						_ string,
+						_ interface{},
+						_ interface{},
+					) error {
+						require.Equal(t, "xoxb-control", token)
+						return nil
+					},
+				},
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:        "message shortcut from unmapped user",
+			interaction: testMessageAction,
+			service: &interactionService{
+				config: InteractionServiceConfig{
+					SlackApps: slack.NewApps(map[string]slack.App{
+						"control-app": {
+							AppID:      "control-app",
+							APIToken:   "foo",
+							Identities: &slack.IdentityMapping{},
+						},
+					}),
+				},
+				access: newAccessChecker(nil, nil, time.Minute),
+				apiClient: &slackTesting.MockAPIClient{
+					CallFn: func(
+						context.Context,
+						string,
+						string,
+						interface{},
+						interface{},
+					) error {
+						return nil
+					},
+				},
+				eventsClient: &sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Fail(t, "no event should have been created")
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := testCase.service.Handle(
+				context.Background(),
+				testCase.interaction,
+			)
+			require.Empty(t, response)
+			testCase.assertions(err)
+		})
+	}
+}
+
+func TestInteractionActionValues(t *testing.T) {
+	testCases := []struct {
+		name     string
+		action   InteractionAction
+		expected []string
+	}{
+		{
+			name:     "button",
+			action:   InteractionAction{Value: "prod"},
+			expected: []string{"prod"},
+		},
+		{
+			name: "static select",
+			action: InteractionAction{
+				SelectedOption: &InteractionOption{Value: "staging"},
+			},
+			expected: []string{"staging"},
+		},
+		{
+			name: "multi-select",
+			action: InteractionAction{
+				SelectedOptions: []InteractionOption{
+					{Value: "dev"},
+					{Value: "staging"},
+				},
+			},
+			expected: []string{"dev", "staging"},
+		},
+		{
+			name:     "users select",
+			action:   InteractionAction{SelectedUser: "U2147483697"},
+			expected: []string{"U2147483697"},
+		},
+		{
+			name:     "nothing selected",
+			action:   InteractionAction{},
+			expected: []string{},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(t, testCase.expected, testCase.action.Values())
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package slack
+
+// Interaction encapsulates details of a Slack interaction, e.g. a user clicking
+// a button in a message. Only the fields this gateway makes use of are
+// included.
+//
+// nolint: lll
+type Interaction struct {
+	Type                string                 `json:"type"`         // e.g. block_actions
+	APIAppID            string                 `json:"api_app_id"`   // e.g. A123456
+	TriggerID           string                 `json:"trigger_id"`   // e.g. 13345224609.738474920.8088930838d88f008e0
+	ResponseURL         string                 `json:"response_url"` // e.g. https://hooks.slack.com/actions/1234/5678
+	CallbackID          string                 `json:"callback_id"`  // e.g. run_with_brigade
+	ActionID            string                 `json:"action_id"`    // block_suggestion only; e.g. brigade-projects
+	BlockID             string                 `json:"block_id"`     // block_suggestion only; e.g. deploy-inputs
+	Value               string                 `json:"value"`        // block_suggestion only; e.g. ital
+	Team                InteractionTeam        `json:"team"`
+	Enterprise          *InteractionEnterprise `json:"enterprise"`
+	User                InteractionUser        `json:"user"`
+	Channel             *InteractionChannel    `json:"channel"`
+	Message             *InteractionMessage    `json:"message"`
+	Actions             []InteractionAction    `json:"actions"`
+	View                *InteractionView       `json:"view"`
+	IsEnterpriseInstall bool                   `json:"is_enterprise_install"` // true if received via an org-wide installation
+}
+
+// InteractionTeam encapsulates details of the Slack workspace in which an
+// Interaction took place.
+//
+// nolint: lll
+type InteractionTeam struct {
+	ID     string `json:"id"`     // e.g. T0001
+	Domain string `json:"domain"` // e.g. example
+}
+
+// InteractionEnterprise encapsulates details of the Slack Enterprise Grid
+// organization in which an Interaction took place.
+//
+// nolint: lll
+type InteractionEnterprise struct {
+	ID   string `json:"id"`   // e.g. E0001
+	Name string `json:"name"` // e.g. Globular Construct Inc
+}
+
+// InteractionUser encapsulates details of the Slack user who initiated an
+// Interaction.
+//
+// nolint: lll
+type InteractionUser struct {
+	ID       string `json:"id"`       // e.g. U2147483697
+	Username string `json:"username"` // e.g. max
+	TeamID   string `json:"team_id"`  // e.g. T0001
+}
+
+// InteractionChannel encapsulates details of the Slack channel in which an
+// Interaction took place.
+//
+// nolint: lll
+type InteractionChannel struct {
+	ID   string `json:"id"`   // e.g. C2147483705
+	Name string `json:"name"` // e.g. test
+}
+
+// InteractionMessage encapsulates details of the Slack message an Interaction
+// originated from.
+//
+// nolint: lll
+type InteractionMessage struct {
+	User     string `json:"user"`      // e.g. U2147483697
+	BotID    string `json:"bot_id"`    // e.g. B123456
+	Text     string `json:"text"`      // e.g. Deploy to production?
+	TS       string `json:"ts"`        // e.g. 1515449522.000016
+	ThreadTS string `json:"thread_ts"` // e.g. 1515449438.000011
+}
+
+// InteractionView encapsulates details of a modal view that a user interacted
+// with, e.g. by submitting it.
+//
+// nolint: lll
+type InteractionView struct {
+	ID              string               `json:"id"`               // e.g. VNHU13V36
+	CallbackID      string               `json:"callback_id"`      // e.g. brigade-command-form
+	PrivateMetadata string               `json:"private_metadata"` // Opaque state set when the view was opened
+	State           InteractionViewState `json:"state"`
+}
+
+// InteractionViewState encapsulates the values of all the input elements in a
+// modal view. Values are indexed by block ID and then by action ID.
+type InteractionViewState struct {
+	Values map[string]map[string]InteractionAction `json:"values"`
+}
+
+// InteractionAction encapsulates details of a single action (e.g. a button
+// click or a menu selection) taken by a user on an interactive component.
+//
+// nolint: lll
+type InteractionAction struct {
+	ActionID             string              `json:"action_id"`             // e.g. approve
+	BlockID              string              `json:"block_id"`              // e.g. deploy-actions
+	Type                 string              `json:"type"`                  // e.g. button
+	Value                string              `json:"value"`                 // e.g. prod
+	SelectedOption       *InteractionOption  `json:"selected_option"`       // static and external selects
+	SelectedOptions      []InteractionOption `json:"selected_options"`      // multi-selects
+	SelectedUser         string              `json:"selected_user"`         // e.g. U2147483697
+	SelectedUsers        []string            `json:"selected_users"`        // e.g. [U2147483697]
+	SelectedChannel      string              `json:"selected_channel"`      // e.g. C2147483705
+	SelectedConversation string              `json:"selected_conversation"` // e.g. C2147483705
+	SelectedDate         string              `json:"selected_date"`         // e.g. 1990-04-28
+	ActionTS             string              `json:"action_ts"`             // e.g. 1548426417.840180
+}
+
+// InteractionOption encapsulates details of an option selected from a menu.
+//
+// nolint: lll
+type InteractionOption struct {
+	Value string `json:"value"` // e.g. prod
+}
+
+// Values returns all values selected or submitted by a user in the course of
+// taking an action, regardless of what kind of interactive component the action
+// was taken on.
+func (i InteractionAction) Values() []string {
+	values := []string{}
+	for _, value := range []string{
+		i.Value,
+		i.SelectedUser,
+		i.SelectedChannel,
+		i.SelectedConversation,
+		i.SelectedDate,
+	} {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	if i.SelectedOption != nil {
+		values = append(values, i.SelectedOption.Value)
+	}
+	for _, option := range i.SelectedOptions {
+		values = append(values, option.Value)
+	}
+	return append(values, i.SelectedUsers...)
+}
+
+const (
+	interactionTypeBlockActions    = "block_actions"
+	interactionTypeViewSubmission  = "view_submission"
+	interactionTypeShortcut        = "shortcut"
+	interactionTypeMessageAction   = "message_action"
+	interactionTypeBlockSuggestion = "block_suggestion"
+)
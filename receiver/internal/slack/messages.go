@@ -0,0 +1,40 @@
+package slack
+
+import "encoding/json"
+
+const (
+	responseTypeEphemeral = "ephemeral"
+	responseTypeInChannel = "in_channel"
+)
+
+// ephemeralMessage returns a JSON-encoded message containing the provided text
+// that, when used to respond to a slash command or interaction, is visible only
+// to the user who initiated it.
+func ephemeralMessage(text string) []byte {
+	msgBytes, _ := json.Marshal( // nolint: errcheck
+		struct {
+			ResponseType string `json:"response_type"`
+			Text         string `json:"text"`
+		}{
+			ResponseType: responseTypeEphemeral,
+			Text:         text,
+		},
+	)
+	return msgBytes
+}
+
+// replacementMessage returns a JSON-encoded message containing the provided
+// text that, when sent to the response URL of an interaction, replaces the
+// message that the interaction originated from.
+func replacementMessage(text string) []byte {
+	msgBytes, _ := json.Marshal( // nolint: errcheck
+		struct {
+			ReplaceOriginal bool   `json:"replace_original"`
+			Text            string `json:"text"`
+		}{
+			ReplaceOriginal: true,
+			Text:            text,
+		},
+	)
+	return msgBytes
+}
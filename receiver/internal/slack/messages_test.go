@@ -0,0 +1,34 @@
+package slack
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEphemeralMessage(t *testing.T) {
+	msg := map[string]string{}
+	require.NoError(t, json.Unmarshal(ephemeralMessage(`"quoted"`), &msg))
+	require.Equal(
+		t,
+		map[string]string{
+			"response_type": responseTypeEphemeral,
+			"text":          `"quoted"`,
+		},
+		msg,
+	)
+}
+
+func TestReplacementMessage(t *testing.T) {
+	msg := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(replacementMessage("Cancelled."), &msg))
+	require.Equal(
+		t,
+		map[string]interface{}{
+			"replace_original": true,
+			"text":             "Cancelled.",
+		},
+		msg,
+	)
+}
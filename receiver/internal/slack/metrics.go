@@ -0,0 +1,122 @@
+package slack
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "brigade_slack_gateway"
+
+// Outcomes of handling requests from Slack. These are used to label metrics.
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+	// outcomeAccepted indicates a slash command was acknowledged before any
+	// event was emitted, i.e. asynchronously.
+	outcomeAccepted = "accepted"
+	// outcomeDenied indicates a slash command was denied by a policy or because
+	// the user who invoked it couldn't be mapped to a suitable Brigade user.
+	outcomeDenied = "denied"
+	// outcomeRateLimited indicates a slash command exceeded a rate limit.
+	outcomeRateLimited = "rate_limited"
+	// outcomeInvalidArguments indicates a slash command's arguments were
+	// rejected and the user was shown its usage instead.
+	outcomeInvalidArguments = "invalid_arguments"
+	// outcomeDuplicate indicates a slash command was a retry of one that was
+	// already handled.
+	outcomeDuplicate = "duplicate"
+)
+
+var (
+	// rateLimitedRequestsCounter counts slash commands rejected for exceeding a
+	// rate limit.
+	rateLimitedRequestsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rate_limited_requests_total",
+			Help: "Total number of slash commands rejected for exceeding a " +
+				"rate limit",
+		},
+		[]string{"app_id", "command", "scope"},
+	)
+	// slashCommandsCounter counts slash commands received from Slack, by app,
+	// command, and outcome.
+	slashCommandsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "slash_commands_total",
+			Help: "Total number of slash commands received from Slack, by app, " +
+				"command, and outcome",
+		},
+		[]string{"app_id", "command", "outcome"},
+	)
+	// signatureVerificationFailuresCounter counts requests rejected by the
+	// signature verification filter, by reason.
+	signatureVerificationFailuresCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "signature_verification_failures_total",
+			Help: "Total number of requests rejected because their signatures " +
+				"could not be verified, by reason",
+		},
+		[]string{"reason"},
+	)
+	// eventCreationDurationHistogram observes how long it takes to emit events
+	// into Brigade's event bus, by outcome.
+	eventCreationDurationHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "brigade_event_create_duration_seconds",
+			Help: "Time taken to create events using the Brigade API, by " +
+				"outcome",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"outcome"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		rateLimitedRequestsCounter,
+		slashCommandsCounter,
+		signatureVerificationFailuresCounter,
+		eventCreationDurationHistogram,
+	)
+}
+
+// outcome returns a label value describing the outcome of an operation that
+// returned the provided error.
+func outcome(err error) string {
+	if err != nil {
+		return outcomeError
+	}
+	return outcomeSuccess
+}
+
+type outcomeContextKey struct{}
+
+// outcomeRecorder records why handling of a request that didn't fail outright
+// nonetheless didn't succeed, e.g. because it was denied.
+type outcomeRecorder struct {
+	outcome string
+}
+
+// withOutcomeRecorder returns a copy of the provided context carrying a new
+// outcomeRecorder, along with the outcomeRecorder itself.
+func withOutcomeRecorder(
+	ctx context.Context,
+) (context.Context, *outcomeRecorder) {
+	recorder := &outcomeRecorder{}
+	return context.WithValue(ctx, outcomeContextKey{}, recorder), recorder
+}
+
+// recordOutcome records the specified outcome using the outcomeRecorder
+// carried by the provided context, if there is one. Only the goroutine
+// handling a request may record its outcome.
+func recordOutcome(ctx context.Context, outcome string) {
+	if recorder, ok :=
+		ctx.Value(outcomeContextKey{}).(*outcomeRecorder); ok {
+		recorder.outcome = outcome
+	}
+}
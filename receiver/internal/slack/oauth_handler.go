@@ -0,0 +1,95 @@
+package slack
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// oauthInstallHandler is an implementation of the http.Handler interface that
+// can begin the installation of a Slack App into a workspace by delegating to a
+// transport-agnostic OAuthService interface.
+type oauthInstallHandler struct {
+	service OAuthService
+}
+
+// NewOAuthInstallHandler returns an implementation of the http.Handler
+// interface that can begin the installation of a Slack App, identified by the
+// appID query parameter, into a workspace by redirecting the user to Slack's
+// authorization page. It delegates to a transport-agnostic OAuthService
+// interface.
+func NewOAuthInstallHandler(service OAuthService) http.Handler {
+	return &oauthInstallHandler{
+		service: service,
+	}
+}
+
+func (o *oauthInstallHandler) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	installURL, err :=
+		o.service.InstallURL(r.Context(), r.URL.Query().Get("appID"))
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+	http.Redirect(w, r, installURL, http.StatusFound)
+}
+
+// oauthCallbackHandler is an implementation of the http.Handler interface that
+// can complete the installation of a Slack App into a workspace by delegating
+// to a transport-agnostic OAuthService interface.
+type oauthCallbackHandler struct {
+	service OAuthService
+}
+
+// NewOAuthCallbackHandler returns an implementation of the http.Handler
+// interface that can complete the installation of a Slack App into a workspace
+// when Slack redirects the user back to this gateway. It delegates to a
+// transport-agnostic OAuthService interface.
+func NewOAuthCallbackHandler(service OAuthService) http.Handler {
+	return &oauthCallbackHandler{
+		service: service,
+	}
+}
+
+func (o *oauthCallbackHandler) ServeHTTP(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	query := r.URL.Query()
+	if _, err := o.service.Complete(
+		r.Context(),
+		OAuthCallback{
+			Code:  query.Get("code"),
+			State: query.Get("state"),
+			Error: query.Get("error"),
+		},
+	); err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write( // nolint: errcheck
+		[]byte("Installation complete. You may close this window.\n"),
+	)
+}
+
+// writeOAuthError writes the provided error to the provided
+// http.ResponseWriter. *OAuthErrors are explained to the user. Anything else
+// is logged and reported only as an internal server error.
+func writeOAuthError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(oauthErr.Reason + "\n")) // nolint: errcheck
+		return
+	}
+	log.Println(err)
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("internal server error\n")) // nolint: errcheck
+}
@@ -0,0 +1,179 @@
+package slack
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOAuthInstallHandler(t *testing.T) {
+	handler, ok :=
+		NewOAuthInstallHandler(&oauthService{}).(*oauthInstallHandler)
+	require.True(t, ok)
+	require.NotNil(t, handler.service)
+}
+
+func TestOAuthInstallHandlerServeHTTP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		service    OAuthService
+		assertions func(*http.Response)
+	}{
+		{
+			name: "unknown app",
+			service: &mockOAuthService{
+				InstallURLFn: func(context.Context, string) (string, error) {
+					return "", &OAuthError{Reason: "unknown app"}
+				},
+			},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusBadRequest, r.StatusCode)
+			},
+		},
+		{
+			name: "success",
+			service: &mockOAuthService{
+				InstallURLFn: func(_ context.Context, appID string) (string, error) {
+					require.Equal(t, "control-app", appID)
+					return "https://slack.com/oauth/v2/authorize", nil
+				},
+			},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusFound, r.StatusCode)
+				require.Equal(
+					t,
+					"https://slack.com/oauth/v2/authorize",
+					r.Header.Get("Location"),
+				)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req, err := http.NewRequest(
+				http.MethodGet,
+				"/oauth/install?appID=control-app",
+				nil,
+			)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			NewOAuthInstallHandler(testCase.service).ServeHTTP(rr, req)
+			res := rr.Result()
+			defer res.Body.Close()
+			testCase.assertions(res)
+		})
+	}
+}
+
+func TestNewOAuthCallbackHandler(t *testing.T) {
+	handler, ok :=
+		NewOAuthCallbackHandler(&oauthService{}).(*oauthCallbackHandler)
+	require.True(t, ok)
+	require.NotNil(t, handler.service)
+}
+
+func TestOAuthCallbackHandlerServeHTTP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		service    OAuthService
+		assertions func(*http.Response)
+	}{
+		{
+			name: "invalid state",
+			service: &mockOAuthService{
+				CompleteFn: func(
+					context.Context,
+					OAuthCallback,
+				) (slack.Installation, error) {
+					return slack.Installation{},
+						&OAuthError{Reason: "invalid or expired state parameter"}
+				},
+			},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusBadRequest, r.StatusCode)
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Contains(t, string(body), "invalid or expired state")
+			},
+		},
+		{
+			name: "internal error",
+			service: &mockOAuthService{
+				CompleteFn: func(
+					context.Context,
+					OAuthCallback,
+				) (slack.Installation, error) {
+					return slack.Installation{}, errors.New("something went wrong")
+				},
+			},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusInternalServerError, r.StatusCode)
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.NotContains(t, string(body), "something went wrong")
+			},
+		},
+		{
+			name: "success",
+			service: &mockOAuthService{
+				CompleteFn: func(
+					_ context.Context,
+					callback OAuthCallback,
+				) (slack.Installation, error) {
+					require.Equal(
+						t,
+						OAuthCallback{
+							Code:  "secret-code",
+							State: "foo",
+						},
+						callback,
+					)
+					return slack.Installation{}, nil
+				},
+			},
+			assertions: func(r *http.Response) {
+				require.Equal(t, http.StatusOK, r.StatusCode)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req, err := http.NewRequest(
+				http.MethodGet,
+				"/oauth/callback?code=secret-code&state=foo",
+				nil,
+			)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			NewOAuthCallbackHandler(testCase.service).ServeHTTP(rr, req)
+			res := rr.Result()
+			defer res.Body.Close()
+			testCase.assertions(res)
+		})
+	}
+}
+
+type mockOAuthService struct {
+	InstallURLFn func(context.Context, string) (string, error)
+	CompleteFn   func(context.Context, OAuthCallback) (slack.Installation, error)
+}
+
+func (m *mockOAuthService) InstallURL(
+	ctx context.Context,
+	appID string,
+) (string, error) {
+	return m.InstallURLFn(ctx, appID)
+}
+
+func (m *mockOAuthService) Complete(
+	ctx context.Context,
+	callback OAuthCallback,
+) (slack.Installation, error) {
+	return m.CompleteFn(ctx, callback)
+}
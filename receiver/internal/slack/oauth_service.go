@@ -0,0 +1,272 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/pkg/errors"
+)
+
+const (
+	// oauthAuthorizeURL is the URL of the Slack page on which users approve the
+	// installation of an App into their workspace.
+	oauthAuthorizeURL = "https://slack.com/oauth/v2/authorize"
+	// oauthStateTTL is how long a user has to approve the installation of an App
+	// before the state parameter that ties the approval to this gateway expires.
+	oauthStateTTL = 10 * time.Minute
+)
+
+// OAuthError represents a problem with an OAuth request that was caused by the
+// user or by Slack rather than by this gateway, such as an unknown App, an
+// invalid or expired state parameter, or the user declining to approve the
+// installation.
+type OAuthError struct {
+	// Reason is a human-readable explanation of the problem.
+	Reason string
+}
+
+func (o *OAuthError) Error() string {
+	return o.Reason
+}
+
+// OAuthCallback encapsulates the parameters Slack sends to this gateway's OAuth
+// redirect URL after a user approves or declines the installation of an App.
+type OAuthCallback struct {
+	// Code is a temporary authorization code that can be exchanged for a bot
+	// token.
+	Code string
+	// State is the state parameter that was included in the request to Slack's
+	// authorization page.
+	State string
+	// Error is set, instead of Code, if the user declined the installation.
+	Error string
+}
+
+// OAuthService is an interface for components that can install Slack Apps into
+// workspaces using Slack's OAuth v2 flow. Implementations of this interface are
+// transport-agnostic.
+type OAuthService interface {
+	// InstallURL returns the URL of the Slack page on which a user may approve
+	// the installation of the specified App into their workspace.
+	InstallURL(ctx context.Context, appID string) (string, error)
+	// Complete exchanges the authorization code in the provided callback for a
+	// bot token and stores the resulting installation.
+	Complete(context.Context, OAuthCallback) (slack.Installation, error)
+}
+
+// OAuthServiceConfig encapsulates configuration for the OAuth service.
+type OAuthServiceConfig struct {
+	// SlackApps is the set of Slack App configurations, which may be reloaded.
+	SlackApps *slack.Apps
+	// TokenStore is where the bot tokens granted by installations are stored.
+	TokenStore slack.TokenStore
+}
+
+type oauthService struct {
+	config    OAuthServiceConfig
+	apiClient slack.APIClient
+	// nowFn returns the current time. It is overridable for testing purposes.
+	nowFn func() time.Time
+}
+
+// NewOAuthService returns an implementation of the OAuthService interface for
+// installing Slack Apps into workspaces.
+func NewOAuthService(
+	apiClient slack.APIClient,
+	config OAuthServiceConfig,
+) OAuthService {
+	return &oauthService{
+		config:    config,
+		apiClient: apiClient,
+		nowFn:     time.Now,
+	}
+}
+
+func (o *oauthService) InstallURL(
+	_ context.Context,
+	appID string,
+) (string, error) {
+	app, err := o.app(appID)
+	if err != nil {
+		return "", err
+	}
+	state, err := o.newState(app)
+	if err != nil {
+		return "", err
+	}
+	return oauthAuthorizeURL + "?" + url.Values{
+		"client_id":    []string{app.OAuth.ClientID},
+		"scope":        []string{strings.Join(app.OAuth.Scopes, ",")},
+		"redirect_uri": []string{app.OAuth.RedirectURL},
+		"state":        []string{state},
+	}.Encode(), nil
+}
+
+func (o *oauthService) Complete(
+	ctx context.Context,
+	callback OAuthCallback,
+) (slack.Installation, error) {
+	app, err := o.verifyState(callback.State)
+	if err != nil {
+		return slack.Installation{}, err
+	}
+	if callback.Error != "" {
+		return slack.Installation{}, &OAuthError{
+			Reason: fmt.Sprintf(
+				"installation was not approved: %s",
+				callback.Error,
+			),
+		}
+	}
+	if callback.Code == "" {
+		return slack.Installation{},
+			&OAuthError{Reason: "no authorization code was provided"}
+	}
+	result := struct {
+		AppID       string `json:"app_id"`
+		AccessToken string `json:"access_token"`
+		BotUserID   string `json:"bot_user_id"`
+		Scope       string `json:"scope"`
+		Team        *struct {
+			ID string `json:"id"`
+		} `json:"team"`
+		Enterprise *struct {
+			ID string `json:"id"`
+		} `json:"enterprise"`
+		IsEnterpriseInstall bool `json:"is_enterprise_install"`
+	}{}
+	// oauth.v2.access authenticates using the App's client ID and secret rather
+	// than a bearer token.
+	if err = o.apiClient.Call(
+		ctx,
+		"",
+		"oauth.v2.access",
+		url.Values{
+			"client_id":     []string{app.OAuth.ClientID},
+			"client_secret": []string{app.OAuth.ClientSecret},
+			"code":          []string{callback.Code},
+			"redirect_uri":  []string{app.OAuth.RedirectURL},
+		},
+		&result,
+	); err != nil {
+		return slack.Installation{}, errors.Wrapf(
+			err,
+			"error exchanging authorization code for app %q",
+			app.AppID,
+		)
+	}
+	// The client ID and secret determine which App the token is granted to. If
+	// they're misconfigured, the token must not be stored under this App.
+	if result.AppID != app.AppID {
+		return slack.Installation{}, errors.Errorf(
+			"authorization code for app %q was exchanged for a token for app %q",
+			app.AppID,
+			result.AppID,
+		)
+	}
+	installation := slack.Installation{
+		AppID:     app.AppID,
+		BotToken:  result.AccessToken,
+		BotUserID: result.BotUserID,
+		Scope:     result.Scope,
+	}
+	// An org-wide installation's token serves every workspace in the
+	// organization, so it isn't stored under any one of them.
+	if result.Team != nil && !result.IsEnterpriseInstall {
+		installation.TeamID = result.Team.ID
+	}
+	if result.Enterprise != nil {
+		installation.EnterpriseID = result.Enterprise.ID
+	}
+	if err = o.config.TokenStore.Put(ctx, installation); err != nil {
+		return slack.Installation{}, errors.Wrapf(
+			err,
+			"error storing installation of app %q for team %q",
+			app.AppID,
+			installation.TeamID,
+		)
+	}
+	log.Printf(
+		"installed app %q into team %q of enterprise %q",
+		installation.AppID,
+		installation.TeamID,
+		installation.EnterpriseID,
+	)
+	return installation, nil
+}
+
+// app returns configuration for the specified App, which must be configured
+// for installation using OAuth.
+func (o *oauthService) app(appID string) (slack.App, error) {
+	app, ok := o.config.SlackApps.Get(appID)
+	if !ok || app.OAuth == nil {
+		return app, &OAuthError{
+			Reason: fmt.Sprintf("app %q cannot be installed using OAuth", appID),
+		}
+	}
+	return app, nil
+}
+
+// newState returns a state parameter for a request to Slack's authorization
+// page. The state identifies the App being installed and is signed using the
+// App's client secret so that callbacks can be verified to have resulted from
+// a request that originated with this gateway. It expires after a fixed TTL.
+func (o *oauthService) newState(app slack.App) (string, error) {
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return "", errors.Wrap(err, "error generating OAuth state")
+	}
+	unsigned := strings.Join(
+		[]string{
+			app.AppID,
+			strconv.FormatInt(o.nowFn().Add(oauthStateTTL).Unix(), 10),
+			hex.EncodeToString(nonceBytes),
+		},
+		".",
+	)
+	return unsigned + "." + oauthStateSignature(app, unsigned), nil
+}
+
+// verifyState verifies the provided state parameter was issued by this gateway
+// and has not expired, and returns configuration for the App it identifies.
+func (o *oauthService) verifyState(state string) (slack.App, error) {
+	invalidErr := &OAuthError{Reason: "invalid or expired state parameter"}
+	parts := strings.Split(state, ".")
+	if len(parts) != 4 {
+		return slack.App{}, invalidErr
+	}
+	app, err := o.app(parts[0])
+	if err != nil {
+		return app, err
+	}
+	unsigned := strings.Join(parts[:3], ".")
+	if !hmac.Equal(
+		[]byte(parts[3]),
+		[]byte(oauthStateSignature(app, unsigned)),
+	) {
+		return app, invalidErr
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || o.nowFn().After(time.Unix(expiry, 0)) {
+		return app, invalidErr
+	}
+	return app, nil
+}
+
+// oauthStateSignature returns a hex-encoded HMAC of the provided, unsigned
+// state parameter, keyed by the provided App's client secret.
+func oauthStateSignature(app slack.App, unsigned string) string {
+	mac := hmac.New(sha256.New, []byte(app.OAuth.ClientSecret))
+	mac.Write([]byte(unsigned)) // nolint: errcheck
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,376 @@
+package slack
+
+// nolint: lll
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var testOAuthApps = map[string]slack.App{
+	"control-app": {
+		AppID: "control-app",
+		OAuth: &slack.OAuthConfig{
+			ClientID:     "86",
+			ClientSecret: "shoe-phone",
+			Scopes:       []string{"commands", "chat:write"},
+			RedirectURL:  "https://gateway.example.com/oauth/callback",
+		},
+	},
+	"kaos-app": {
+		AppID: "kaos-app",
+	},
+}
+
+func TestNewOAuthService(t *testing.T) {
+	svc, ok := NewOAuthService(
+		&slackTesting.MockAPIClient{},
+		OAuthServiceConfig{SlackApps: slack.NewApps(testOAuthApps)},
+	).(*oauthService)
+	require.True(t, ok)
+	require.NotNil(t, svc.apiClient)
+	require.Equal(t, testOAuthApps, svc.config.SlackApps.All())
+	require.NotNil(t, svc.nowFn)
+}
+
+func TestOAuthServiceInstallURL(t *testing.T) {
+	testCases := []struct {
+		name       string
+		appID      string
+		assertions func(string, error)
+	}{
+		{
+			name:  "unknown app",
+			appID: "unknown-app",
+			assertions: func(_ string, err error) {
+				require.Error(t, err)
+				require.IsType(t, &OAuthError{}, err)
+			},
+		},
+		{
+			name:  "app not configured for OAuth",
+			appID: "kaos-app",
+			assertions: func(_ string, err error) {
+				require.Error(t, err)
+				require.IsType(t, &OAuthError{}, err)
+			},
+		},
+		{
+			name:  "success",
+			appID: "control-app",
+			assertions: func(installURL string, err error) {
+				require.NoError(t, err)
+				require.True(t, strings.HasPrefix(installURL, oauthAuthorizeURL+"?"))
+				u, err := url.Parse(installURL)
+				require.NoError(t, err)
+				query := u.Query()
+				require.Equal(t, "86", query.Get("client_id"))
+				require.Equal(t, "commands,chat:write", query.Get("scope"))
+				require.Equal(
+					t,
+					"https://gateway.example.com/oauth/callback",
+					query.Get("redirect_uri"),
+				)
+				require.True(
+					t,
+					strings.HasPrefix(query.Get("state"), "control-app."),
+				)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := NewOAuthService(
+				&slackTesting.MockAPIClient{},
+				OAuthServiceConfig{SlackApps: slack.NewApps(testOAuthApps)},
+			)
+			testCase.assertions(
+				svc.InstallURL(context.Background(), testCase.appID),
+			)
+		})
+	}
+}
+
+func TestOAuthServiceComplete(t *testing.T) {
+	now := time.Now()
+	issuer := &oauthService{
+		config: OAuthServiceConfig{SlackApps: slack.NewApps(testOAuthApps)},
+		nowFn: func() time.Time {
+			return now
+		},
+	}
+	validState, err := issuer.newState(testOAuthApps["control-app"])
+	require.NoError(t, err)
+	issuer.nowFn = func() time.Time {
+		return now.Add(-time.Hour)
+	}
+	expiredState, err := issuer.newState(testOAuthApps["control-app"])
+	require.NoError(t, err)
+	testCases := []struct {
+		name       string
+		callback   OAuthCallback
+		apiClient  slack.APIClient
+		tokenStore slack.TokenStore
+		assertions func(slack.Installation, error)
+	}{
+		{
+			name: "malformed state",
+			callback: OAuthCallback{
+				Code:  "secret-code",
+				State: "nonsense",
+			},
+			assertions: func(_ slack.Installation, err error) {
+				require.Error(t, err)
+				require.IsType(t, &OAuthError{}, err)
+				require.Contains(t, err.Error(), "invalid or expired state")
+			},
+		},
+		{
+			name: "tampered state",
+			callback: OAuthCallback{
+				Code:  "secret-code",
+				State: validState + "0",
+			},
+			assertions: func(_ slack.Installation, err error) {
+				require.Error(t, err)
+				require.IsType(t, &OAuthError{}, err)
+				require.Contains(t, err.Error(), "invalid or expired state")
+			},
+		},
+		{
+			name: "expired state",
+			callback: OAuthCallback{
+				Code:  "secret-code",
+				State: expiredState,
+			},
+			assertions: func(_ slack.Installation, err error) {
+				require.Error(t, err)
+				require.IsType(t, &OAuthError{}, err)
+				require.Contains(t, err.Error(), "invalid or expired state")
+			},
+		},
+		{
+			name: "installation not approved",
+			callback: OAuthCallback{
+				State: validState,
+				Error: "access_denied",
+			},
+			assertions: func(_ slack.Installation, err error) {
+				require.Error(t, err)
+				require.IsType(t, &OAuthError{}, err)
+				require.Contains(t, err.Error(), "access_denied")
+			},
+		},
+		{
+			name: "error exchanging code",
+			callback: OAuthCallback{
+				Code:  "secret-code",
+				State: validState,
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					context.Context,
+					string,
+					string,
+					interface{},
+					interface{},
+				) error {
+					return &slack.APIError{
+						Method: "oauth.v2.access",
+						Code:   "invalid_code",
+					}
+				},
+			},
+			assertions: func(_ slack.Installation, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "invalid_code")
+			},
+		},
+		{
+			name: "token granted to another app",
+			callback: OAuthCallback{
+				Code:  "secret-code",
+				State: validState,
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					_ string,
+					_ string,
+					_ interface{},
+					result interface{},
+				) error {
+					return json.Unmarshal(
+						[]byte(`{
+							"app_id": "kaos-app",
+							"access_token": "xoxb-kaos",
+							"team": {"id": "T1"}
+						}`),
+						result,
+					)
+				},
+			},
+			tokenStore: &slackTesting.MockTokenStore{
+				PutFn: func(context.Context, slack.Installation) error {
+					require.Fail(t, "token for another app should not be stored")
+					return nil
+				},
+			},
+			assertions: func(_ slack.Installation, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), `token for app "kaos-app"`)
+			},
+		},
+		{
+			name: "error storing installation",
+			callback: OAuthCallback{
+				Code:  "secret-code",
+				State: validState,
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					_ string,
+					_ string,
+					_ interface{},
+					result interface{},
+				) error {
+					return json.Unmarshal(
+						[]byte(`{
+							"app_id": "control-app",
+							"access_token": "xoxb-control",
+							"team": {"id": "T1"}
+						}`),
+						result,
+					)
+				},
+			},
+			tokenStore: &slackTesting.MockTokenStore{
+				PutFn: func(context.Context, slack.Installation) error {
+					return errors.New("something went wrong")
+				},
+			},
+			assertions: func(_ slack.Installation, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "something went wrong")
+				require.Contains(t, err.Error(), "error storing installation")
+			},
+		},
+		{
+			name: "org-wide installation",
+			callback: OAuthCallback{
+				Code:  "secret-code",
+				State: validState,
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					_ string,
+					_ string,
+					_ interface{},
+					result interface{},
+				) error {
+					return json.Unmarshal(
+						[]byte(`{
+							"app_id": "control-app",
+							"access_token": "xoxb-net",
+							"team": null,
+							"enterprise": {"id": "E1"},
+							"is_enterprise_install": true
+						}`),
+						result,
+					)
+				},
+			},
+			tokenStore: &slackTesting.MockTokenStore{
+				PutFn: func(context.Context, slack.Installation) error {
+					return nil
+				},
+			},
+			assertions: func(installation slack.Installation, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "E1", installation.EnterpriseID)
+				require.Empty(t, installation.TeamID)
+				require.Equal(t, "xoxb-net", installation.BotToken)
+			},
+		},
+		{
+			name: "success",
+			callback: OAuthCallback{
+				Code:  "secret-code",
+				State: validState,
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					token string,
+					method string,
+					args interface{},
+					result interface{},
+				) error {
+					require.Empty(t, token)
+					require.Equal(t, "oauth.v2.access", method)
+					form := args.(url.Values)
+					require.Equal(t, "86", form.Get("client_id"))
+					require.Equal(t, "shoe-phone", form.Get("client_secret"))
+					require.Equal(t, "secret-code", form.Get("code"))
+					return json.Unmarshal(
+						[]byte(`{
+							"app_id": "control-app",
+							"access_token": "xoxb-control",
+							"bot_user_id": "U99",
+							"scope": "commands,chat:write",
+							"team": {"id": "T1"},
+							"enterprise": {"id": "E1"}
+						}`),
+						result,
+					)
+				},
+			},
+			tokenStore: &slackTesting.MockTokenStore{
+				PutFn: func(_ context.Context, i slack.Installation) error {
+					require.Equal(t, "xoxb-control", i.BotToken)
+					return nil
+				},
+			},
+			assertions: func(installation slack.Installation, err error) {
+				require.NoError(t, err)
+				require.Equal(
+					t,
+					slack.Installation{
+						AppID:        "control-app",
+						EnterpriseID: "E1",
+						TeamID:       "T1",
+						BotToken:     "xoxb-control",
+						BotUserID:    "U99",
+						Scope:        "commands,chat:write",
+					},
+					installation,
+				)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := &oauthService{
+				config: OAuthServiceConfig{
+					SlackApps:  slack.NewApps(testOAuthApps),
+					TokenStore: testCase.tokenStore,
+				},
+				apiClient: testCase.apiClient,
+				nowFn:     time.Now,
+			}
+			testCase.assertions(
+				svc.Complete(context.Background(), testCase.callback),
+			)
+		})
+	}
+}
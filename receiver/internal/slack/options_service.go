@@ -0,0 +1,202 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	"github.com/pkg/errors"
+)
+
+const (
+	// projectsOptionsActionIDPrefix prefixes the action IDs of external select
+	// menus whose options should be the Brigade projects subscribed to events
+	// from this gateway.
+	projectsOptionsActionIDPrefix = "brigade-projects"
+	// eventsOptionsActionIDPrefix prefixes the action IDs of external select
+	// menus whose options should be recent events from this gateway that
+	// originated in the current channel.
+	eventsOptionsActionIDPrefix = "brigade-events"
+	// maxOptions is the maximum number of options Slack permits in a select
+	// menu.
+	maxOptions = 100
+	// maxOptionTextLength is the maximum length of an option's text permitted
+	// by Slack.
+	maxOptionTextLength = 75
+)
+
+type optionsService struct {
+	projectsClient sdk.ProjectsClient
+	eventsClient   sdk.EventsClient
+}
+
+// NewOptionsService returns an implementation of the InteractionService
+// interface for handling block_suggestion interactions. i.e. Requests from
+// Slack to load options for external select menus. Options are loaded from
+// Brigade.
+func NewOptionsService(
+	projectsClient sdk.ProjectsClient,
+	eventsClient sdk.EventsClient,
+) InteractionService {
+	return &optionsService{
+		projectsClient: projectsClient,
+		eventsClient:   eventsClient,
+	}
+}
+
+// option represents a single option in a select menu.
+type option struct {
+	Text        text   `json:"text"`
+	Description *text  `json:"description,omitempty"`
+	Value       string `json:"value"`
+}
+
+// text represents a Block Kit text object.
+type text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (o *optionsService) Handle(
+	ctx context.Context,
+	interaction Interaction,
+) ([]byte, error) {
+	if interaction.Type != interactionTypeBlockSuggestion {
+		log.Printf("ignoring interaction of type %q", interaction.Type)
+		return nil, nil
+	}
+	var options []option
+	var err error
+	switch {
+	case strings.HasPrefix(interaction.ActionID, projectsOptionsActionIDPrefix):
+		options, err = o.projectOptions(ctx, interaction)
+	case strings.HasPrefix(interaction.ActionID, eventsOptionsActionIDPrefix):
+		options, err = o.eventOptions(ctx, interaction)
+	default:
+		log.Printf(
+			"no options available for action %q",
+			interaction.ActionID,
+		)
+		options = []option{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(options) > maxOptions {
+		options = options[:maxOptions]
+	}
+	response, err := json.Marshal(
+		struct {
+			Options []option `json:"options"`
+		}{
+			Options: options,
+		},
+	)
+	return response, errors.Wrap(err, "error rendering options")
+}
+
+// projectOptions returns options representing Brigade projects subscribed to
+// events from this gateway on behalf of the Slack App the interaction
+// originated from. Only projects whose IDs begin with the text the user has
+// typed so far are included.
+func (o *optionsService) projectOptions(
+	ctx context.Context,
+	interaction Interaction,
+) ([]option, error) {
+	projects, err :=
+		subscribedProjects(ctx, o.projectsClient, interaction.APIAppID)
+	if err != nil {
+		return nil, err
+	}
+	options := []option{}
+	for _, project := range projects {
+		if !hasPrefixFold(project.ID, interaction.Value) {
+			continue
+		}
+		opt := option{
+			Text:  plainText(project.ID),
+			Value: project.ID,
+		}
+		if project.Description != "" {
+			description := plainText(project.Description)
+			opt.Description = &description
+		}
+		options = append(options, opt)
+	}
+	return options, nil
+}
+
+// eventOptions returns options representing recent events emitted by this
+// gateway on behalf of the Slack App the interaction originated from and that
+// originated in the same channel as the interaction. Only events whose IDs,
+// types, or project IDs begin with the text the user has typed so far are
+// included. If the interaction did not originate in a channel, as is the case
+// for interactions with modals, no options are returned, since listing events
+// from every channel would disclose events from channels the user may not
+// belong to.
+func (o *optionsService) eventOptions(
+	ctx context.Context,
+	interaction Interaction,
+) ([]option, error) {
+	if interaction.Channel == nil || interaction.Channel.ID == "" {
+		return []option{}, nil
+	}
+	selector := &sdk.EventsSelector{
+		Source: eventSource,
+		Qualifiers: map[string]string{
+			"appID": interaction.APIAppID,
+		},
+		Labels: map[string]string{
+			"channelID": interaction.Channel.ID,
+		},
+	}
+	events, err := o.eventsClient.List(
+		ctx,
+		selector,
+		&meta.ListOptions{Limit: maxOptions},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing events")
+	}
+	options := []option{}
+	for _, event := range events.Items {
+		if !hasPrefixFold(event.ID, interaction.Value) &&
+			!hasPrefixFold(event.Type, interaction.Value) &&
+			!hasPrefixFold(event.ProjectID, interaction.Value) {
+			continue
+		}
+		options = append(
+			options,
+			option{
+				Text: plainText(
+					fmt.Sprintf("%s: %s (%s)", event.ProjectID, event.Type, event.ID),
+				),
+				Value: event.ID,
+			},
+		)
+	}
+	return options, nil
+}
+
+// plainText returns a plain text object, truncated, if necessary, to the
+// maximum length Slack permits for option text. Slack measures length in
+// characters, so truncation never splits a multi-byte character.
+func plainText(str string) text {
+	if runes := []rune(str); len(runes) > maxOptionTextLength {
+		str = fmt.Sprintf("%s...", string(runes[:maxOptionTextLength-3]))
+	}
+	return text{
+		Type: "plain_text",
+		Text: str,
+	}
+}
+
+// hasPrefixFold returns a bool indicating whether the string s begins with the
+// provided prefix, ignoring case.
+func hasPrefixFold(s, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+}
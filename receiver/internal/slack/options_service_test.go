@@ -0,0 +1,260 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOptionsService(t *testing.T) {
+	s, ok := NewOptionsService(
+		// Totally unusable clients that are enough to fulfill the dependencies
+		// for this test...
+		&sdkTesting.MockProjectsClient{},
+		&sdkTesting.MockEventsClient{
+			LogsClient: &sdkTesting.MockLogsClient{},
+		},
+	).(*optionsService)
+	require.True(t, ok)
+	require.NotNil(t, s.projectsClient)
+	require.NotNil(t, s.eventsClient)
+}
+
+func TestOptionsServiceHandle(t *testing.T) {
+	testProjectsClient := &sdkTesting.MockProjectsClient{
+		ListFn: func(
+			context.Context,
+			*sdk.ProjectsSelector,
+			*meta.ListOptions,
+		) (sdk.ProjectList, error) {
+			return sdk.ProjectList{
+				Items: []sdk.Project{
+					testProject("french", "control-app"),
+					testProject("italian", "control-app"),
+					testProject("irish", "kaos-app"),
+				},
+			}, nil
+		},
+	}
+	testCases := []struct {
+		name        string
+		interaction Interaction
+		service     *optionsService
+		assertions  func(options []option, err error)
+	}{
+		{
+			name:        "unknown interaction type",
+			interaction: Interaction{Type: interactionTypeBlockActions},
+			service:     &optionsService{},
+			assertions: func(options []option, err error) {
+				require.NoError(t, err)
+				require.Nil(t, options)
+			},
+		},
+		{
+			name: "unknown action ID",
+			interaction: Interaction{
+				Type:     interactionTypeBlockSuggestion,
+				ActionID: "bogus",
+			},
+			service: &optionsService{},
+			assertions: func(options []option, err error) {
+				require.NoError(t, err)
+				require.Empty(t, options)
+			},
+		},
+		{
+			name: "error listing projects",
+			interaction: Interaction{
+				Type:     interactionTypeBlockSuggestion,
+				APIAppID: "control-app",
+				ActionID: projectsOptionsActionIDPrefix,
+			},
+			service: &optionsService{
+				projectsClient: &sdkTesting.MockProjectsClient{
+					ListFn: func(
+						context.Context,
+						*sdk.ProjectsSelector,
+						*meta.ListOptions,
+					) (sdk.ProjectList, error) {
+						return sdk.ProjectList{}, errors.New("something went wrong")
+					},
+				},
+			},
+			assertions: func(_ []option, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name: "project options",
+			interaction: Interaction{
+				Type:     interactionTypeBlockSuggestion,
+				APIAppID: "control-app",
+				ActionID: projectsOptionsActionIDPrefix + "-1",
+				Value:    "IT",
+			},
+			service: &optionsService{
+				projectsClient: testProjectsClient,
+			},
+			assertions: func(options []option, err error) {
+				require.NoError(t, err)
+				require.Len(t, options, 1)
+				require.Equal(t, "italian", options[0].Value)
+				require.Equal(t, "italian", options[0].Text.Text)
+				require.NotNil(t, options[0].Description)
+				require.Equal(
+					t,
+					"The italian project",
+					options[0].Description.Text,
+				)
+			},
+		},
+		{
+			name: "event options without a channel",
+			interaction: Interaction{
+				Type:     interactionTypeBlockSuggestion,
+				APIAppID: "control-app",
+				ActionID: eventsOptionsActionIDPrefix,
+			},
+			service: &optionsService{
+				eventsClient: &sdkTesting.MockEventsClient{
+					ListFn: func(
+						context.Context,
+						*sdk.EventsSelector,
+						*meta.ListOptions,
+					) (sdk.EventList, error) {
+						require.Fail(t, "events should not have been listed")
+						return sdk.EventList{}, nil
+					},
+				},
+			},
+			assertions: func(options []option, err error) {
+				require.NoError(t, err)
+				require.Empty(t, options)
+			},
+		},
+		{
+			name: "error listing events",
+			interaction: Interaction{
+				Type:     interactionTypeBlockSuggestion,
+				APIAppID: "control-app",
+				ActionID: eventsOptionsActionIDPrefix,
+				Channel:  &InteractionChannel{ID: "cone-of-silence"},
+			},
+			service: &optionsService{
+				eventsClient: &sdkTesting.MockEventsClient{
+					ListFn: func(
+						context.Context,
+						*sdk.EventsSelector,
+						*meta.ListOptions,
+					) (sdk.EventList, error) {
+						return sdk.EventList{}, errors.New("something went wrong")
+					},
+				},
+			},
+			assertions: func(_ []option, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error listing events")
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name: "event options",
+			interaction: Interaction{
+				Type:     interactionTypeBlockSuggestion,
+				APIAppID: "control-app",
+				ActionID: eventsOptionsActionIDPrefix,
+				Channel:  &InteractionChannel{ID: "cone-of-silence"},
+				Value:    "dep",
+			},
+			service: &optionsService{
+				eventsClient: &sdkTesting.MockEventsClient{
+					ListFn: func(
+						_ context.Context,
+						selector *sdk.EventsSelector,
+						_ *meta.ListOptions,
+					) (sdk.EventList, error) {
+						require.Equal(t, eventSource, selector.Source)
+						require.Equal(
+							t,
+							map[string]string{"appID": "control-app"},
+							selector.Qualifiers,
+						)
+						require.Equal(
+							t,
+							map[string]string{"channelID": "cone-of-silence"},
+							selector.Labels,
+						)
+						return sdk.EventList{
+							Items: []sdk.Event{
+								{
+									ObjectMeta: meta.ObjectMeta{ID: "123"},
+									ProjectID:  "italian",
+									Type:       "deploy",
+								},
+								{
+									ObjectMeta: meta.ObjectMeta{ID: "456"},
+									ProjectID:  "italian",
+									Type:       "rollback",
+								},
+							},
+						}, nil
+					},
+				},
+			},
+			assertions: func(options []option, err error) {
+				require.NoError(t, err)
+				require.Len(t, options, 1)
+				require.Equal(t, "123", options[0].Value)
+				require.Equal(t, "italian: deploy (123)", options[0].Text.Text)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := testCase.service.Handle(
+				context.Background(),
+				testCase.interaction,
+			)
+			if err != nil || response == nil {
+				testCase.assertions(nil, err)
+				return
+			}
+			obj := struct {
+				Options []option `json:"options"`
+			}{}
+			require.NoError(t, json.Unmarshal(response, &obj))
+			testCase.assertions(obj.Options, nil)
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	require.Equal(t, "foo", plainText("foo").Text)
+	longText := plainText(
+		"This is a very long string that is definitely going to need to be " +
+			"truncated because it is so very long",
+	).Text
+	require.Len(t, longText, maxOptionTextLength)
+	require.True(t, strings.HasSuffix(longText, "..."))
+	// Multi-byte characters should be counted as one character each and should
+	// never be split
+	multiByteText := plainText(strings.Repeat("é", maxOptionTextLength+1)).Text
+	require.True(t, utf8.ValidString(multiByteText))
+	require.Equal(t, maxOptionTextLength, utf8.RuneCountInString(multiByteText))
+	require.True(t, strings.HasSuffix(multiByteText, "..."))
+	require.Equal(
+		t,
+		strings.Repeat("é", maxOptionTextLength),
+		plainText(strings.Repeat("é", maxOptionTextLength)).Text,
+	)
+}
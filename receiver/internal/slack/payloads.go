@@ -0,0 +1,205 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/pkg/errors"
+)
+
+// payloadVersion is the version of the structured JSON payload. It should be
+// incremented whenever the payload changes in a way that isn't backwards
+// compatible.
+const payloadVersion = "v1"
+
+// commandPayload represents the details of a slash command that are available
+// to workers, either as a structured JSON payload or via an operator-supplied
+// template.
+type commandPayload struct {
+	// Version is the version of the payload's structure.
+	Version string `json:"version"`
+	// EventType is the type of the event being emitted.
+	EventType string `json:"eventType"`
+	// Command is the slash command itself.
+	Command SlashCommand `json:"command"`
+	// Text is the slash command's text, with any subcommand removed.
+	Text string `json:"text"`
+	// Args are the whitespace-delimited words of Text.
+	Args []string `json:"args"`
+	// Values are values collected for the slash command's parameters or parsed
+	// from its arguments, if any.
+	Values map[string]interface{} `json:"values,omitempty"`
+	// User holds details of the user who invoked the slash command, if events
+	// are being enriched with them.
+	User *slackUser `json:"user,omitempty"`
+	// Channel holds details of the channel the slash command was invoked in, if
+	// events are being enriched with them.
+	Channel *slackChannel `json:"channel,omitempty"`
+}
+
+// payloadRenderer renders the payloads and additional labels of events emitted
+// in response to slash commands. Templates are parsed up front so that invalid
+// ones are detected when the gateway starts.
+type payloadRenderer struct {
+	templates map[string]*template.Template
+}
+
+// newPayloadRenderer returns a payloadRenderer for all of the payload and
+// label templates found in the provided Slack App configurations.
+func newPayloadRenderer(
+	apps map[string]slack.App,
+) (*payloadRenderer, error) {
+	p := &payloadRenderer{
+		templates: map[string]*template.Template{},
+	}
+	for _, app := range apps {
+		configs := []slack.Payload{app.Payload}
+		for _, cmd := range app.Commands {
+			configs = append(configs, cmd.Payload)
+			for _, arg := range cmd.Arguments {
+				if slack.IsReservedLabel(arg.Label) {
+					return nil, errors.Errorf(
+						"argument %q of command %q for app %q uses reserved label %q",
+						arg.Name,
+						cmd.Command,
+						app.AppID,
+						arg.Label,
+					)
+				}
+			}
+		}
+		for _, config := range configs {
+			texts := []string{config.Template, config.ShortTitle, config.LongTitle}
+			for key, text := range config.Labels {
+				if slack.IsReservedLabel(key) {
+					return nil, errors.Errorf(
+						"payload configuration for app %q uses reserved label %q",
+						app.AppID,
+						key,
+					)
+				}
+				texts = append(texts, text)
+			}
+			for _, text := range texts {
+				if text == "" || p.templates[text] != nil {
+					continue
+				}
+				tmpl, err := template.New(
+					"template",
+				).Funcs(sprig.TxtFuncMap()).Parse(text)
+				if err != nil {
+					return nil, errors.Wrapf(
+						err,
+						"error parsing payload template for app %q",
+						app.AppID,
+					)
+				}
+				p.templates[text] = tmpl
+			}
+		}
+	}
+	return p, nil
+}
+
+// renderedPayload encapsulates the payload, additional labels, and titles of
+// an event emitted in response to a slash command.
+type renderedPayload struct {
+	Payload    string
+	Labels     map[string]string
+	ShortTitle string
+	LongTitle  string
+}
+
+// newCommandPayload returns the details of the provided slash command, which
+// resulted in an event of the specified type with the specified text and
+// parameter or argument values.
+func newCommandPayload(
+	command SlashCommand,
+	eventType string,
+	text string,
+	values map[string]interface{},
+) commandPayload {
+	return commandPayload{
+		Version:   payloadVersion,
+		EventType: eventType,
+		Command:   command,
+		Text:      text,
+		Args:      strings.Fields(text),
+		Values:    values,
+	}
+}
+
+// render returns the payload, additional labels, and titles for an event
+// emitted in response to a slash command having the provided details,
+// according to the provided configuration.
+func (p *payloadRenderer) render(
+	config slack.Payload,
+	data commandPayload,
+) (renderedPayload, error) {
+	rendered := renderedPayload{
+		Labels: make(map[string]string, len(config.Labels)),
+	}
+	for key, text := range config.Labels {
+		value, err := p.execute(text, data)
+		if err != nil {
+			return rendered, errors.Wrapf(err, "error rendering label %q", key)
+		}
+		rendered.Labels[key] = value
+	}
+	var err error
+	if config.ShortTitle != "" {
+		rendered.ShortTitle, err = p.execute(config.ShortTitle, data)
+		if err != nil {
+			return rendered, errors.Wrap(err, "error rendering short title")
+		}
+	}
+	if config.LongTitle != "" {
+		rendered.LongTitle, err = p.execute(config.LongTitle, data)
+		if err != nil {
+			return rendered, errors.Wrap(err, "error rendering long title")
+		}
+	}
+	switch {
+	case config.Template != "":
+		rendered.Payload, err = p.execute(config.Template, data)
+		err = errors.Wrap(err, "error rendering payload")
+	case config.Format == slack.PayloadFormatJSON:
+		var payloadBytes []byte
+		payloadBytes, err = json.Marshal(data)
+		rendered.Payload = string(payloadBytes)
+		err = errors.Wrap(err, "error marshaling payload")
+	case data.Values != nil:
+		var payloadBytes []byte
+		payloadBytes, err = json.Marshal(data.Values)
+		rendered.Payload = string(payloadBytes)
+		err = errors.Wrap(err, "error marshaling values")
+	default:
+		rendered.Payload = data.Text
+	}
+	return rendered, err
+}
+
+// execute executes the parsed template for the provided template text against
+// the provided data. Template text that wasn't parsed up front (which can only
+// happen if the configuration has changed since) is parsed on the fly.
+func (p *payloadRenderer) execute(
+	text string,
+	data commandPayload,
+) (string, error) {
+	tmpl, ok := p.templates[text]
+	if !ok {
+		var err error
+		if tmpl, err = template.New(
+			"template",
+		).Funcs(sprig.TxtFuncMap()).Parse(text); err != nil {
+			return "", err
+		}
+	}
+	buffer := &bytes.Buffer{}
+	err := tmpl.Execute(buffer, data)
+	return buffer.String(), err
+}
@@ -0,0 +1,216 @@
+package slack
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPayloadRenderer(t *testing.T) {
+	testCases := []struct {
+		name       string
+		apps       map[string]slack.App
+		assertions func(*payloadRenderer, error)
+	}{
+		{
+			name: "invalid label template",
+			apps: map[string]slack.App{
+				"control-app": {
+					AppID: "control-app",
+					Commands: []slack.Command{
+						{
+							Command: "/deploy",
+							Payload: slack.Payload{
+								Labels: map[string]string{
+									"environment": "{{ .Args",
+								},
+							},
+						},
+					},
+				},
+			},
+			assertions: func(_ *payloadRenderer, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error parsing payload template")
+				require.Contains(t, err.Error(), "control-app")
+			},
+		},
+		{
+			name: "success",
+			apps: map[string]slack.App{
+				"control-app": {
+					AppID: "control-app",
+					Payload: slack.Payload{
+						Template: "{{ .Text }}",
+					},
+					Commands: []slack.Command{
+						{
+							Command: "/deploy",
+							Payload: slack.Payload{
+								Template: "{{ .Text }}",
+								Labels: map[string]string{
+									"environment": "{{ index .Args 0 }}",
+								},
+							},
+						},
+					},
+				},
+			},
+			assertions: func(renderer *payloadRenderer, err error) {
+				require.NoError(t, err)
+				// Identical templates should only be parsed once
+				require.Len(t, renderer.templates, 2)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.assertions(newPayloadRenderer(testCase.apps))
+		})
+	}
+}
+
+func TestPayloadRendererRender(t *testing.T) {
+	testCommand := SlashCommand{
+		Command:     "/deploy",
+		APIAppID:    "control-app",
+		ChannelName: "cone-of-silence",
+		Text:        "prod now",
+	}
+	testCases := []struct {
+		name       string
+		config     slack.Payload
+		values     map[string]interface{}
+		assertions func(renderedPayload, error)
+	}{
+		{
+			name: "text",
+			assertions: func(rendered renderedPayload, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "prod now", rendered.Payload)
+				require.Empty(t, rendered.Labels)
+			},
+		},
+		{
+			name:   "form values",
+			values: map[string]interface{}{"environment": "prod"},
+			assertions: func(rendered renderedPayload, err error) {
+				require.NoError(t, err)
+				require.JSONEq(t, `{"environment":"prod"}`, rendered.Payload)
+			},
+		},
+		{
+			name:   "json",
+			config: slack.Payload{Format: slack.PayloadFormatJSON},
+			values: map[string]interface{}{"environment": "prod"},
+			assertions: func(rendered renderedPayload, err error) {
+				require.NoError(t, err)
+				data := map[string]interface{}{}
+				require.NoError(t, json.Unmarshal([]byte(rendered.Payload), &data))
+				require.Equal(t, payloadVersion, data["version"])
+				require.Equal(t, "deploy", data["eventType"])
+				require.Equal(t, "prod now", data["text"])
+				require.Equal(t, []interface{}{"prod", "now"}, data["args"])
+				require.Equal(
+					t,
+					map[string]interface{}{"environment": "prod"},
+					data["values"],
+				)
+				command, ok := data["command"].(map[string]interface{})
+				require.True(t, ok)
+				require.Equal(t, "cone-of-silence", command["channelName"])
+				require.Equal(t, "control-app", command["apiAppID"])
+			},
+		},
+		{
+			name: "template",
+			config: slack.Payload{
+				Format:   slack.PayloadFormatJSON,
+				Template: "{{ .Command.ChannelName }}: {{ upper .Text }}",
+				Labels: map[string]string{
+					"environment": "{{ index .Args 0 }}",
+				},
+			},
+			assertions: func(rendered renderedPayload, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "cone-of-silence: PROD NOW", rendered.Payload)
+				require.Equal(
+					t,
+					map[string]string{"environment": "prod"},
+					rendered.Labels,
+				)
+			},
+		},
+		{
+			name: "titles",
+			config: slack.Payload{
+				ShortTitle: "deploy to {{ .Values.environment }}",
+				LongTitle:  "{{ .Command.Command }} {{ .Text }}",
+			},
+			values: map[string]interface{}{"environment": "prod"},
+			assertions: func(rendered renderedPayload, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "deploy to prod", rendered.ShortTitle)
+				require.Equal(t, "/deploy prod now", rendered.LongTitle)
+			},
+		},
+		{
+			name: "error rendering title",
+			config: slack.Payload{
+				ShortTitle: "{{ index .Args 2 }}",
+			},
+			assertions: func(_ renderedPayload, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error rendering short title")
+			},
+		},
+		{
+			name: "error rendering label",
+			config: slack.Payload{
+				Labels: map[string]string{
+					"version": "{{ index .Args 2 }}",
+				},
+			},
+			assertions: func(_ renderedPayload, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), `error rendering label "version"`)
+			},
+		},
+		{
+			name: "error rendering payload",
+			config: slack.Payload{
+				Template: "{{ index .Args 2 }}",
+			},
+			assertions: func(_ renderedPayload, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error rendering payload")
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			renderer, err := newPayloadRenderer(
+				map[string]slack.App{
+					"control-app": {
+						AppID:   "control-app",
+						Payload: testCase.config,
+					},
+				},
+			)
+			require.NoError(t, err)
+			testCase.assertions(
+				renderer.render(
+					testCase.config,
+					newCommandPayload(
+						testCommand,
+						"deploy",
+						testCommand.Text,
+						testCase.values,
+					),
+				),
+			)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package slack
+
+import (
+	"context"
+
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	"github.com/pkg/errors"
+)
+
+// subscribedProjects returns all Brigade projects having at least one event
+// subscription to events emitted by this gateway on behalf of the specified
+// Slack App.
+func subscribedProjects(
+	ctx context.Context,
+	projectsClient sdk.ProjectsClient,
+	appID string,
+) ([]sdk.Project, error) {
+	projects := []sdk.Project{}
+	listOpts := &meta.ListOptions{Limit: 100}
+	for {
+		projectList, err := projectsClient.List(ctx, nil, listOpts)
+		if err != nil {
+			return nil, errors.Wrap(err, "error listing projects")
+		}
+		for _, project := range projectList.Items {
+			if len(appSubscriptions(project, appID)) > 0 {
+				projects = append(projects, project)
+			}
+		}
+		if projectList.RemainingItemCount == 0 {
+			return projects, nil
+		}
+		listOpts.Continue = projectList.Continue
+	}
+}
+
+// appSubscriptions returns the provided project's subscriptions to events
+// emitted by this gateway on behalf of the specified Slack App.
+func appSubscriptions(
+	project sdk.Project,
+	appID string,
+) []sdk.EventSubscription {
+	subs := []sdk.EventSubscription{}
+	for _, sub := range project.Spec.EventSubscriptions {
+		// Events emitted by this gateway are qualified ONLY by appID and
+		// subscriptions must match an event's qualifiers exactly.
+		if sub.Source == eventSource &&
+			len(sub.Qualifiers) == 1 &&
+			sub.Qualifiers["appID"] == appID {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// projectSubscribesTo returns a bool indicating whether the provided project
+// has at least one subscription that the provided event, which is assumed to
+// have been emitted by this gateway, matches. Subscriptions match events whose
+// type is listed (or which list the wildcard type) and whose labels include all
+// of the subscription's own labels.
+func projectSubscribesTo(project sdk.Project, event sdk.Event) bool {
+	for _, sub := range appSubscriptions(project, event.Qualifiers["appID"]) {
+		typeMatches := false
+		for _, eventType := range sub.Types {
+			if eventType == event.Type || eventType == "*" {
+				typeMatches = true
+				break
+			}
+		}
+		if !typeMatches {
+			continue
+		}
+		labelsMatch := true
+		for key, value := range sub.Labels {
+			if event.Labels[key] != value {
+				labelsMatch = false
+				break
+			}
+		}
+		if labelsMatch {
+			return true
+		}
+	}
+	return false
+}
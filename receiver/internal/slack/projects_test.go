@@ -0,0 +1,200 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/brigadecore/brigade/sdk/v3"
+	"github.com/brigadecore/brigade/sdk/v3/meta"
+	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribedProjects(t *testing.T) {
+	testCases := []struct {
+		name           string
+		projectsClient sdk.ProjectsClient
+		assertions     func([]sdk.Project, error)
+	}{
+		{
+			name: "error listing projects",
+			projectsClient: &sdkTesting.MockProjectsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.ProjectsSelector,
+					*meta.ListOptions,
+				) (sdk.ProjectList, error) {
+					return sdk.ProjectList{}, errors.New("something went wrong")
+				},
+			},
+			assertions: func(_ []sdk.Project, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error listing projects")
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name: "success",
+			projectsClient: &sdkTesting.MockProjectsClient{
+				ListFn: func(
+					_ context.Context,
+					_ *sdk.ProjectsSelector,
+					opts *meta.ListOptions,
+				) (sdk.ProjectList, error) {
+					if opts.Continue == "" {
+						return sdk.ProjectList{
+							ListMeta: meta.ListMeta{
+								Continue:           "italian",
+								RemainingItemCount: 1,
+							},
+							Items: []sdk.Project{
+								testProject("french", "control-app"),
+								testProject("german", "kaos-app"),
+							},
+						}, nil
+					}
+					return sdk.ProjectList{
+						Items: []sdk.Project{
+							testProject("italian", "control-app"),
+						},
+					}, nil
+				},
+			},
+			assertions: func(projects []sdk.Project, err error) {
+				require.NoError(t, err)
+				require.Len(t, projects, 2)
+				require.Equal(t, "french", projects[0].ID)
+				require.Equal(t, "italian", projects[1].ID)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			projects, err := subscribedProjects(
+				context.Background(),
+				testCase.projectsClient,
+				"control-app",
+			)
+			testCase.assertions(projects, err)
+		})
+	}
+}
+
+func TestAppSubscriptions(t *testing.T) {
+	project := sdk.Project{
+		Spec: sdk.ProjectSpec{
+			EventSubscriptions: []sdk.EventSubscription{
+				{
+					Source:     eventSource,
+					Qualifiers: map[string]string{"appID": "control-app"},
+					Types:      []string{"deploy"},
+				},
+				{
+					Source:     eventSource,
+					Qualifiers: map[string]string{"appID": "kaos-app"},
+					Types:      []string{"*"},
+				},
+				{
+					Source: "brigade.sh/github",
+					Types:  []string{"*"},
+				},
+			},
+		},
+	}
+	subs := appSubscriptions(project, "control-app")
+	require.Len(t, subs, 1)
+	require.Equal(t, []string{"deploy"}, subs[0].Types)
+}
+
+func TestProjectSubscribesTo(t *testing.T) {
+	project := sdk.Project{
+		Spec: sdk.ProjectSpec{
+			EventSubscriptions: []sdk.EventSubscription{
+				{
+					Source:     eventSource,
+					Qualifiers: map[string]string{"appID": "control-app"},
+					Types:      []string{"deploy"},
+					Labels:     map[string]string{"channelID": "C86"},
+				},
+				{
+					Source:     eventSource,
+					Qualifiers: map[string]string{"appID": "kaos-app"},
+					Types:      []string{"*"},
+				},
+			},
+		},
+	}
+	testCases := []struct {
+		name     string
+		event    sdk.Event
+		expected bool
+	}{
+		{
+			name: "type and labels match",
+			event: newEvent(
+				origin{AppID: "control-app", ChannelID: "C86"},
+				"deploy",
+				"",
+			),
+			expected: true,
+		},
+		{
+			name: "type does not match",
+			event: newEvent(
+				origin{AppID: "control-app", ChannelID: "C86"},
+				"rollback",
+				"",
+			),
+		},
+		{
+			name: "labels do not match",
+			event: newEvent(
+				origin{AppID: "control-app", ChannelID: "C99"},
+				"deploy",
+				"",
+			),
+		},
+		{
+			name:     "wildcard type",
+			event:    newEvent(origin{AppID: "kaos-app"}, "anything", ""),
+			expected: true,
+		},
+		{
+			name:  "different app",
+			event: newEvent(origin{AppID: "other-app"}, "deploy", ""),
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(
+				t,
+				testCase.expected,
+				projectSubscribesTo(project, testCase.event),
+			)
+		})
+	}
+}
+
+// testProject returns a project with the specified ID that subscribes to all
+// events emitted by this gateway on behalf of the specified Slack App.
+func testProject(id string, appID string) sdk.Project {
+	return sdk.Project{
+		ObjectMeta: meta.ObjectMeta{
+			ID: id,
+		},
+		Description: fmt.Sprintf("The %s project", id),
+		Spec: sdk.ProjectSpec{
+			EventSubscriptions: []sdk.EventSubscription{
+				{
+					Source: eventSource,
+					Qualifiers: map[string]string{
+						"appID": appID,
+					},
+					Types: []string{"*"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,150 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitedSlashCommandServiceConfig encapsulates configuration for the rate
+// limited slash command service.
+type RateLimitedSlashCommandServiceConfig struct {
+	// SlackApps is the set of Slack App configurations, which may be reloaded.
+	SlackApps *slack.Apps
+}
+
+type rateLimitedSlashCommandService struct {
+	SlashCommandService
+	config      RateLimitedSlashCommandServiceConfig
+	rateLimiter *rateLimiter
+}
+
+// NewRateLimitedSlashCommandService returns an implementation of the
+// SlashCommandService interface that enforces the rate limits configured for
+// each Slack App and slash command before delegating to the provided
+// SlashCommandService. Slash commands that exceed a rate limit are rejected
+// with a message that only the user who invoked them can see. Submissions of
+// forms are not rate limited because the slash commands that opened those
+// forms already were.
+func NewRateLimitedSlashCommandService(
+	service SlashCommandService,
+	config RateLimitedSlashCommandServiceConfig,
+) SlashCommandService {
+	return &rateLimitedSlashCommandService{
+		SlashCommandService: service,
+		config:              config,
+		rateLimiter:         newRateLimiter(),
+	}
+}
+
+func (r *rateLimitedSlashCommandService) Handle(
+	ctx context.Context,
+	command SlashCommand,
+) ([]byte, error) {
+	app, _ := r.config.SlackApps.Get(command.APIAppID)
+	cmdConfig, ok := app.Command(command.Command)
+	if !ok {
+		cmdConfig = slack.Command{Command: command.Command}
+	}
+	// Buckets are scoped to the App and, for command-level limits, to the
+	// command as configured, so that aliases share buckets.
+	requests := bucketRequests(
+		app.AppID,
+		"app",
+		app.RateLimits,
+		command,
+	)
+	requests = append(
+		requests,
+		bucketRequests(
+			app.AppID+":"+cmdConfig.Command,
+			"command",
+			cmdConfig.RateLimits,
+			command,
+		)...,
+	)
+	if allowed, scope, delay := r.rateLimiter.allow(requests...); !allowed {
+		rateLimitedRequestsCounter.WithLabelValues(
+			command.APIAppID,
+			cmdConfig.Command,
+			scope,
+		).Inc()
+		log.Printf(
+			"rate limited (%s) command %q for app %q from user %q in channel %q",
+			scope,
+			command.Command,
+			command.APIAppID,
+			command.UserID,
+			command.ChannelID,
+		)
+		recordOutcome(ctx, outcomeRateLimited)
+		return ephemeralMessage(slowDownMessage(command, delay)), nil
+	}
+	return r.SlashCommandService.Handle(ctx, command)
+}
+
+// bucketRequests returns requests for each of the token buckets that the
+// provided rate limits call for, with bucket keys derived from the provided
+// prefix and details of the provided slash command. Scopes are derived from
+// the provided prefix.
+func bucketRequests(
+	keyPrefix string,
+	scopePrefix string,
+	limits slack.RateLimits,
+	command SlashCommand,
+) []bucketRequest {
+	requests := []bucketRequest{}
+	if limits.Total != nil {
+		requests = append(
+			requests,
+			bucketRequest{
+				scope: scopePrefix,
+				key:   keyPrefix,
+				limit: *limits.Total,
+			},
+		)
+	}
+	if limits.PerUser != nil {
+		requests = append(
+			requests,
+			bucketRequest{
+				scope: scopePrefix + "_user",
+				key:   keyPrefix + ":user:" + command.UserID,
+				limit: *limits.PerUser,
+			},
+		)
+	}
+	if limits.PerChannel != nil {
+		requests = append(
+			requests,
+			bucketRequest{
+				scope: scopePrefix + "_channel",
+				key:   keyPrefix + ":channel:" + command.ChannelID,
+				limit: *limits.PerChannel,
+			},
+		)
+	}
+	return requests
+}
+
+// slowDownMessage returns the text of the message sent to a user whose slash
+// command was rejected for exceeding a rate limit. The provided delay, if
+// known, is used to advise the user when to try again.
+func slowDownMessage(command SlashCommand, delay time.Duration) string {
+	if delay <= 0 || delay == rate.InfDuration {
+		return fmt.Sprintf(
+			"Slow down! %s has been used too often. Please try again later.",
+			command.Command,
+		)
+	}
+	return fmt.Sprintf(
+		"Slow down! %s has been used too often. Please try again in %ds.",
+		command.Command,
+		int(math.Ceil(delay.Seconds())),
+	)
+}
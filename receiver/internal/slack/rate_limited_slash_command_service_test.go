@@ -0,0 +1,108 @@
+package slack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRateLimitedSlashCommandService(t *testing.T) {
+	s := NewRateLimitedSlashCommandService(
+		&mockSlashCommandService{},
+		RateLimitedSlashCommandServiceConfig{},
+	)
+	svc, ok := s.(*rateLimitedSlashCommandService)
+	require.True(t, ok)
+	require.NotNil(t, svc.SlashCommandService)
+	require.NotNil(t, svc.rateLimiter)
+}
+
+func TestRateLimitedSlashCommandServiceHandle(t *testing.T) {
+	handled := 0
+	service := NewRateLimitedSlashCommandService(
+		&mockSlashCommandService{
+			HandleFn: func(context.Context, SlashCommand) ([]byte, error) {
+				handled++
+				return []byte("ack"), nil
+			},
+		},
+		RateLimitedSlashCommandServiceConfig{
+			SlackApps: slack.NewApps(map[string]slack.App{
+				"control-app": {
+					AppID: "control-app",
+					RateLimits: slack.RateLimits{
+						PerChannel: &slack.RateLimit{
+							RequestsPerMinute: 1,
+							Burst:             3,
+						},
+					},
+					Commands: []slack.Command{
+						{
+							Command: "/deploy",
+							Aliases: []string{"/ship"},
+							RateLimits: slack.RateLimits{
+								PerUser: &slack.RateLimit{RequestsPerMinute: 1},
+							},
+						},
+					},
+				},
+			}),
+		},
+	)
+	counter := rateLimitedRequestsCounter.WithLabelValues(
+		"control-app",
+		"/deploy",
+		"command_user",
+	)
+	startingCount := testutil.ToFloat64(counter)
+	command := SlashCommand{
+		Command:   "/deploy",
+		APIAppID:  "control-app",
+		ChannelID: "cone-of-silence",
+		UserID:    "86",
+	}
+	response, err := service.Handle(context.Background(), command)
+	require.NoError(t, err)
+	require.Equal(t, "ack", string(response))
+	// Aliases should share the same buckets
+	command.Command = "/ship"
+	response, err = service.Handle(context.Background(), command)
+	require.NoError(t, err)
+	require.Contains(t, string(response), responseTypeEphemeral)
+	require.Contains(t, string(response), "Slow down!")
+	require.Contains(t, string(response), "/ship has been used too often")
+	require.Equal(t, startingCount+1, testutil.ToFloat64(counter))
+	// A different user in the same channel should be allowed...
+	command.UserID = "99"
+	_, err = service.Handle(context.Background(), command)
+	require.NoError(t, err)
+	// ...but not for long
+	command.UserID = "13"
+	_, err = service.Handle(context.Background(), command)
+	require.NoError(t, err)
+	command.UserID = "42"
+	ctx, recorder := withOutcomeRecorder(context.Background())
+	response, err = service.Handle(ctx, command)
+	require.NoError(t, err)
+	require.Contains(t, string(response), "Slow down!")
+	require.Equal(t, outcomeRateLimited, recorder.outcome)
+	require.Equal(t, 3, handled)
+}
+
+func TestSlowDownMessage(t *testing.T) {
+	command := SlashCommand{Command: "/deploy"}
+	require.Equal(
+		t,
+		"Slow down! /deploy has been used too often. Please try again later.",
+		slowDownMessage(command, 0),
+	)
+	require.Equal(
+		t,
+		"Slow down! /deploy has been used too often. Please try again in 2s.",
+		slowDownMessage(command, 1500*time.Millisecond),
+	)
+}
@@ -0,0 +1,109 @@
+package slack
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiter is a simple, concurrency-safe, in-memory collection of token
+// buckets, created on demand.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	nowFn   func() time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+	// idleTTL is how long it takes the bucket to refill completely. A bucket
+	// that has been idle this long is indistinguishable from a new one, so it
+	// can safely be discarded.
+	idleTTL time.Duration
+}
+
+// newBucket returns a new, full bucket with the specified limit and burst.
+func newBucket(limit rate.Limit, burst int) *bucket {
+	b := &bucket{
+		limiter: rate.NewLimiter(limit, burst),
+	}
+	b.idleTTL = idleTTL(limit, burst)
+	return b
+}
+
+// idleTTL returns how long it takes a bucket with the specified limit and burst
+// to refill completely.
+func idleTTL(limit rate.Limit, burst int) time.Duration {
+	if limit <= 0 {
+		// A bucket that never refills must never be discarded.
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(float64(burst) / float64(limit) * float64(time.Second))
+}
+
+// bucketRequest identifies a token bucket that a request must draw from along
+// with that bucket's configuration.
+type bucketRequest struct {
+	// scope describes what the bucket limits. e.g. user
+	scope string
+	// key uniquely identifies the bucket.
+	key   string
+	limit slack.RateLimit
+}
+
+// newRateLimiter returns a new rateLimiter.
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: map[string]*bucket{},
+		nowFn:   time.Now,
+	}
+}
+
+// allow attempts to draw a token from each of the specified buckets. If any of
+// them is empty, no tokens are drawn from any of them and the scope of the
+// (first) empty bucket is returned along with how long it will be until it has
+// a token available. Idle buckets are pruned as a side effect. Existing buckets
+// whose configuration differs from that requested, e.g. because configuration
+// was reloaded, are adjusted to the requested configuration without being
+// refilled.
+func (r *rateLimiter) allow(
+	requests ...bucketRequest,
+) (bool, string, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.nowFn()
+	for key, b := range r.buckets {
+		if now.Sub(b.lastUsed) > b.idleTTL {
+			delete(r.buckets, key)
+		}
+	}
+	reservations := make([]*rate.Reservation, 0, len(requests))
+	for _, req := range requests {
+		limit := rate.Limit(req.limit.RequestsPerMinute / 60)
+		burst := req.limit.BucketSize()
+		b, ok := r.buckets[req.key]
+		if !ok {
+			b = newBucket(limit, burst)
+			r.buckets[req.key] = b
+		} else if b.limiter.Limit() != limit || b.limiter.Burst() != burst {
+			b.limiter.SetLimitAt(now, limit)
+			b.limiter.SetBurstAt(now, burst)
+			b.idleTTL = idleTTL(limit, burst)
+		}
+		b.lastUsed = now
+		reservation := b.limiter.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+			reservation.CancelAt(now)
+			for _, reservation := range reservations {
+				reservation.CancelAt(now)
+			}
+			return false, req.scope, delay
+		}
+		reservations = append(reservations, reservation)
+	}
+	return true, "", 0
+}
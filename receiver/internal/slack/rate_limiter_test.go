@@ -0,0 +1,102 @@
+package slack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter()
+	limiter.nowFn = func() time.Time {
+		return now
+	}
+	userBucket := bucketRequest{
+		scope: "user",
+		key:   "user:86",
+		// One request per second with a burst of two
+		limit: slack.RateLimit{
+			RequestsPerMinute: 60,
+			Burst:             2,
+		},
+	}
+	channelBucket := bucketRequest{
+		scope: "channel",
+		key:   "channel:cone-of-silence",
+		limit: slack.RateLimit{
+			RequestsPerMinute: 60,
+			Burst:             3,
+		},
+	}
+	for i := 0; i < 2; i++ {
+		allowed, _, _ := limiter.allow(userBucket, channelBucket)
+		require.True(t, allowed)
+	}
+	// The user bucket should be empty
+	allowed, scope, delay := limiter.allow(channelBucket, userBucket)
+	require.False(t, allowed)
+	require.Equal(t, "user", scope)
+	require.Equal(t, time.Second, delay)
+	// No token should have been drawn from the channel bucket by the rejected
+	// request
+	allowed, _, _ = limiter.allow(channelBucket)
+	require.True(t, allowed)
+	allowed, scope, _ = limiter.allow(channelBucket)
+	require.False(t, allowed)
+	require.Equal(t, "channel", scope)
+	// After a second, the user bucket should have a token again
+	now = now.Add(time.Second)
+	allowed, _, _ = limiter.allow(userBucket)
+	require.True(t, allowed)
+	// Once the buckets have had time to refill completely, they should be pruned
+	now = now.Add(time.Minute)
+	allowed, _, _ = limiter.allow()
+	require.True(t, allowed)
+	require.Empty(t, limiter.buckets)
+}
+
+func TestRateLimiterAllowWithChangedLimits(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter()
+	limiter.nowFn = func() time.Time {
+		return now
+	}
+	userBucket := bucketRequest{
+		scope: "user",
+		key:   "user:86",
+		limit: slack.RateLimit{
+			RequestsPerMinute: 60,
+			Burst:             3,
+		},
+	}
+	allowed, _, _ := limiter.allow(userBucket)
+	require.True(t, allowed)
+	// Tighten the limit, as a configuration reload might
+	userBucket.limit = slack.RateLimit{
+		RequestsPerMinute: 6,
+		Burst:             1,
+	}
+	// The bucket had tokens to spare, but no more than the new burst allows
+	allowed, _, _ = limiter.allow(userBucket)
+	require.True(t, allowed)
+	// The bucket should now be empty and refill at the new, slower rate
+	allowed, _, delay := limiter.allow(userBucket)
+	require.False(t, allowed)
+	require.Equal(t, 10*time.Second, delay)
+	// Loosen the limit again
+	userBucket.limit = slack.RateLimit{
+		RequestsPerMinute: 60,
+		Burst:             3,
+	}
+	// The bucket should refill at the new, faster rate, but shouldn't have been
+	// refilled by the change itself
+	allowed, _, delay = limiter.allow(userBucket)
+	require.False(t, allowed)
+	require.Equal(t, time.Second, delay)
+	now = now.Add(time.Second)
+	allowed, _, _ = limiter.allow(userBucket)
+	require.True(t, allowed)
+}
@@ -4,9 +4,16 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	libHTTP "github.com/brigadecore/brigade-foundations/http"
 	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
@@ -15,15 +22,42 @@ import (
 // SignatureVerificationFilterConfig encapsulates configuration for the
 // signature verification based auth filter.
 type SignatureVerificationFilterConfig struct {
-	// SlackApps is a map of Slack App configurations indexed by App ID.
-	SlackApps map[string]slack.App
+	// SlackApps is the set of Slack App configurations, which may be reloaded.
+	SlackApps *slack.Apps
+	// TimestampTolerance is the maximum difference between a request's
+	// timestamp and the current time for the request to be considered. Requests
+	// falling outside this window are rejected as possible replays. If not
+	// specified, a default of five minutes is used.
+	TimestampTolerance time.Duration
+	// MaxRequestBodyBytes is the maximum size of a request body. Requests with
+	// larger bodies are rejected. If not specified, a default of 1 MiB is used.
+	MaxRequestBodyBytes int64
 }
 
+const (
+	defaultTimestampTolerance  = 5 * time.Minute
+	defaultMaxRequestBodyBytes = 1 << 20
+)
+
+// Reasons a request may fail signature verification. These are used to label
+// metrics.
+const (
+	signatureFailureNoBody           = "no_body"
+	signatureFailureBodyTooLarge     = "body_too_large"
+	signatureFailureInvalidTimestamp = "invalid_timestamp"
+	signatureFailureInvalidSignature = "invalid_signature"
+	signatureFailureReplay           = "replay"
+)
+
 // signatureVerificationFilter is a component that implements the http.Filter
 // interface and can conditionally allow or disallow a request based on the
 // ability to verify the signature of the inbound request.
 type signatureVerificationFilter struct {
 	config SignatureVerificationFilterConfig
+	// seenSignatures tracks the signatures of recently verified requests so
+	// that replays of those requests can be rejected.
+	seenSignatures *signatureCache
+	nowFn          func() time.Time
 }
 
 // NewSignatureVerificationFilter returns a component that implements the
@@ -32,8 +66,16 @@ type signatureVerificationFilter struct {
 func NewSignatureVerificationFilter(
 	config SignatureVerificationFilterConfig,
 ) libHTTP.Filter {
+	if config.TimestampTolerance <= 0 {
+		config.TimestampTolerance = defaultTimestampTolerance
+	}
+	if config.MaxRequestBodyBytes <= 0 {
+		config.MaxRequestBodyBytes = defaultMaxRequestBodyBytes
+	}
 	return &signatureVerificationFilter{
-		config: config,
+		config:         config,
+		seenSignatures: newSignatureCache(),
+		nowFn:          time.Now,
 	}
 }
 
@@ -44,42 +86,97 @@ func (s *signatureVerificationFilter) Decorate(
 		// If there is no request body, fail right away or else we'll be staring
 		// down the barrel of a nil pointer dereference.
 		if r.Body == nil {
-			w.WriteHeader(http.StatusForbidden)
+			rejectUnverified(w, http.StatusForbidden, signatureFailureNoBody)
 			return
 		}
 
 		// If we encounter an error reading the request body, we're just going to
 		// roll with it. The empty request body will naturally make the signature
-		// verification algorithm fail.
-		bodyBytes, _ := ioutil.ReadAll(r.Body) // nolint: errcheck
-		r.Body.Close()                         // nolint: errcheck
+		// verification algorithm fail. Reading one byte beyond the maximum allowed
+		// body size tells us whether the body is too large.
+		bodyBytes, _ := ioutil.ReadAll( // nolint: errcheck
+			io.LimitReader(r.Body, s.config.MaxRequestBodyBytes+1),
+		)
+		r.Body.Close() // nolint: errcheck
+		if int64(len(bodyBytes)) > s.config.MaxRequestBodyBytes {
+			rejectUnverified(
+				w,
+				http.StatusRequestEntityTooLarge,
+				signatureFailureBodyTooLarge,
+			)
+			return
+		}
 		// Replace the request body because the original read was destructive!
 		r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 
-		appID := r.FormValue("api_app_id")
+		timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+		signature := r.Header.Get("X-Slack-Signature")
 
-		// Now compute the signature...
-		hasher := hmac.New(
-			sha256.New,
-			[]byte(s.config.SlackApps[appID].AppSigningSecret),
-		)
-		// Again, we're just going to roll with whatever errors may have occurred
-		// here and let the algorithm fail to verify the signature.
-		hasher.Write( // nolint: errcheck
-			[]byte(
-				fmt.Sprintf(
-					"v0:%s:%s",
-					r.Header.Get("X-Slack-Request-Timestamp"),
-					string(bodyBytes),
-				),
-			),
-		)
-		computedSignature := fmt.Sprintf("v0=%x", hasher.Sum(nil))
+		// If the request's timestamp is missing, malformed, or outside the
+		// tolerance window, return a 403. Without this check, a captured request
+		// could be replayed indefinitely.
+		requestTime, ok := parseTimestamp(timestamp)
+		if !ok || absDuration(s.nowFn().Sub(requestTime)) >
+			s.config.TimestampTolerance {
+			rejectUnverified(
+				w,
+				http.StatusForbidden,
+				signatureFailureInvalidTimestamp,
+			)
+			return
+		}
+
+		appID := appIDFromRequest(r, bodyBytes)
+		// Parsing form values may have consumed the request body again.
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+
+		var verified bool
+		if appID != "" {
+			app, _ := s.config.SlackApps.Get(appID)
+			verified = verifyAppSignature(
+				app,
+				timestamp,
+				bodyBytes,
+				signature,
+			)
+		} else {
+			// Some requests, like the Events API's URL verification challenge, do
+			// not indicate what app they're for. In such cases, the request is
+			// considered verified if ANY app's signing secret checks out.
+			for _, app := range s.config.SlackApps.All() {
+				if verified = verifyAppSignature(
+					app,
+					timestamp,
+					bodyBytes,
+					signature,
+				); verified {
+					break
+				}
+			}
+		}
 
 		// If the computed signature does not match the signature provided with
 		// the request, return a 403.
-		if computedSignature != r.Header.Get("X-Slack-Signature") {
-			w.WriteHeader(http.StatusForbidden)
+		if !verified {
+			rejectUnverified(
+				w,
+				http.StatusForbidden,
+				signatureFailureInvalidSignature,
+			)
+			return
+		}
+
+		// If we have already seen this exact signature within the tolerance
+		// window, this is a replay. Return a 403. Signatures only need to be
+		// remembered for as long as their timestamps remain within the window,
+		// since requests with older timestamps are rejected anyway.
+		if !s.seenSignatures.add(
+			signature,
+			requestTime.Add(s.config.TimestampTolerance),
+			s.nowFn(),
+		) {
+			log.Printf("rejecting replayed request with signature %q", signature)
+			rejectUnverified(w, http.StatusForbidden, signatureFailureReplay)
 			return
 		}
 
@@ -87,3 +184,128 @@ func (s *signatureVerificationFilter) Decorate(
 		handle(w, r)
 	}
 }
+
+// rejectUnverified responds to a request that failed signature verification
+// for the specified reason using the specified status code.
+func rejectUnverified(w http.ResponseWriter, statusCode int, reason string) {
+	signatureVerificationFailuresCounter.WithLabelValues(reason).Inc()
+	w.WriteHeader(statusCode)
+}
+
+// appIDFromRequest extracts the ID of the Slack App that sent the request from
+// the request body. Slash commands carry the ID as a form value, interactions
+// carry it in a JSON-encoded form value named "payload", and the Events API
+// carries it in a JSON body. If no ID can be found, the empty string is
+// returned.
+func appIDFromRequest(r *http.Request, bodyBytes []byte) string {
+	appIDHolder := struct {
+		APIAppID string `json:"api_app_id"`
+	}{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		json.Unmarshal(bodyBytes, &appIDHolder) // nolint: errcheck
+		return appIDHolder.APIAppID
+	}
+	if appID := r.FormValue("api_app_id"); appID != "" {
+		return appID
+	}
+	if payload := r.FormValue("payload"); payload != "" {
+		json.Unmarshal([]byte(payload), &appIDHolder) // nolint: errcheck
+	}
+	return appIDHolder.APIAppID
+}
+
+// verifyAppSignature returns a bool indicating whether the provided signature
+// can be verified using ANY of the provided app's active signing secrets.
+func verifyAppSignature(
+	app slack.App,
+	timestamp string,
+	bodyBytes []byte,
+	signature string,
+) bool {
+	for _, signingSecret := range app.SigningSecrets() {
+		if verifySignature(signingSecret, timestamp, bodyBytes, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignature computes the signature of the provided timestamp and request
+// body using the provided signing secret and returns a bool indicating whether
+// it matches the provided signature.
+func verifySignature(
+	signingSecret string,
+	timestamp string,
+	bodyBytes []byte,
+	signature string,
+) bool {
+	if signingSecret == "" {
+		return false
+	}
+	hasher := hmac.New(sha256.New, []byte(signingSecret))
+	// We're just going to roll with whatever errors may have occurred here and
+	// let the algorithm fail to verify the signature.
+	hasher.Write( // nolint: errcheck
+		[]byte(fmt.Sprintf("v0:%s:%s", timestamp, string(bodyBytes))),
+	)
+	return hmac.Equal(
+		[]byte(fmt.Sprintf("v0=%x", hasher.Sum(nil))),
+		[]byte(signature),
+	)
+}
+
+// parseTimestamp parses the provided value of a request's
+// X-Slack-Request-Timestamp header, which should be a Unix timestamp. It
+// returns the corresponding time and a bool indicating whether parsing
+// succeeded.
+func parseTimestamp(timestamp string) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// absDuration returns the absolute value of the provided duration.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// signatureCache is a short-lived, concurrency-safe record of signatures that
+// have already been seen.
+type signatureCache struct {
+	mu          sync.Mutex
+	expirations map[string]time.Time
+}
+
+// newSignatureCache returns an empty signatureCache.
+func newSignatureCache() *signatureCache {
+	return &signatureCache{
+		expirations: map[string]time.Time{},
+	}
+}
+
+// add records the provided signature until the provided expiration time and
+// returns true if the signature was not already recorded. If it was, false is
+// returned. Expired signatures are pruned as a side effect.
+func (s *signatureCache) add(
+	signature string,
+	expiration time.Time,
+	now time.Time,
+) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sig, exp := range s.expirations {
+		if now.After(exp) {
+			delete(s.expirations, sig)
+		}
+	}
+	if _, seen := s.expirations[signature]; seen {
+		return false
+	}
+	s.expirations[signature] = expiration
+	return true
+}
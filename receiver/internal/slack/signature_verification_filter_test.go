@@ -7,9 +7,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -17,31 +22,44 @@ func TestNewSignatureVerificationFilter(t *testing.T) {
 	const testAppID = "42"
 	testSecret := []byte("foobar")
 	testConfig := SignatureVerificationFilterConfig{
-		SlackApps: map[string]slack.App{
+		SlackApps: slack.NewApps(map[string]slack.App{
 			testAppID: {
 				AppID:            testAppID,
 				AppSigningSecret: string(testSecret),
 			},
-		},
+		}),
 	}
 	filter, ok :=
 		NewSignatureVerificationFilter(testConfig).(*signatureVerificationFilter)
 	require.True(t, ok)
-	require.Equal(t, testConfig, filter.config)
+	require.Equal(t, testConfig.SlackApps, filter.config.SlackApps)
+	// Defaults should have been applied
+	require.Equal(t, defaultTimestampTolerance, filter.config.TimestampTolerance)
+	require.Equal(
+		t,
+		int64(defaultMaxRequestBodyBytes),
+		filter.config.MaxRequestBodyBytes,
+	)
+	require.NotNil(t, filter.seenSignatures)
+	require.NotNil(t, filter.nowFn)
 }
 
 func TestSignatureVerificationFilter(t *testing.T) {
 	const testAppID = "42"
 	testAppSigningSecret := []byte("foobar")
-	testFilter := &signatureVerificationFilter{
-		config: SignatureVerificationFilterConfig{
-			SlackApps: map[string]slack.App{
-				testAppID: {
-					AppID:            testAppID,
-					AppSigningSecret: string(testAppSigningSecret),
-				},
+	testNow := time.Unix(1531420618, 0)
+	testTimestamp := strconv.FormatInt(testNow.Unix(), 10)
+	testNewAppSigningSecret := []byte("bazqux")
+	testConfig := SignatureVerificationFilterConfig{
+		SlackApps: slack.NewApps(map[string]slack.App{
+			testAppID: {
+				AppID:             testAppID,
+				AppSigningSecret:  string(testAppSigningSecret),
+				AppSigningSecrets: []string{string(testNewAppSigningSecret)},
 			},
-		},
+		}),
+		TimestampTolerance:  5 * time.Minute,
+		MaxRequestBodyBytes: 64,
 	}
 	testCases := []struct {
 		name       string
@@ -71,9 +89,8 @@ func TestSignatureVerificationFilter(t *testing.T) {
 				req, err :=
 					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
 				require.NoError(t, err)
-				// This doesn't have to be a real timestamp as long as things match
 				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-				timeStamp := "noon"
+				timeStamp := testTimestamp
 				req.Header.Add("X-Slack-Request-Timestamp", timeStamp)
 				// Compute the signature
 				hasher := hmac.New(sha256.New, testAppSigningSecret)
@@ -99,9 +116,185 @@ func TestSignatureVerificationFilter(t *testing.T) {
 				require.True(t, handlerCalled)
 			},
 		},
+		{
+			name: "signature of json body can be verified",
+			setup: func() *http.Request {
+				bodyBytes := []byte(`{"api_app_id":"42"}`)
+				req, err :=
+					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+				require.NoError(t, err)
+				req.Header.Add("Content-Type", "application/json")
+				signRequest(t, req, testAppSigningSecret, testTimestamp, bodyBytes)
+				return req
+			},
+			assertions: func(handlerCalled bool, r *http.Response) {
+				require.Equal(t, http.StatusOK, r.StatusCode)
+				require.True(t, handlerCalled)
+			},
+		},
+		{
+			name: "signature using additional signing secret can be verified",
+			setup: func() *http.Request {
+				bodyBytes := []byte(`{"api_app_id":"42"}`)
+				req, err :=
+					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+				require.NoError(t, err)
+				req.Header.Add("Content-Type", "application/json")
+				signRequest(
+					t,
+					req,
+					testNewAppSigningSecret,
+					testTimestamp,
+					bodyBytes,
+				)
+				return req
+			},
+			assertions: func(handlerCalled bool, r *http.Response) {
+				require.Equal(t, http.StatusOK, r.StatusCode)
+				require.True(t, handlerCalled)
+			},
+		},
+		{
+			name: "signature of interaction payload can be verified",
+			setup: func() *http.Request {
+				bodyBytes := []byte(
+					"payload=" + url.QueryEscape(`{"api_app_id":"42"}`),
+				)
+				req, err :=
+					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+				require.NoError(t, err)
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				signRequest(t, req, testAppSigningSecret, testTimestamp, bodyBytes)
+				return req
+			},
+			assertions: func(handlerCalled bool, r *http.Response) {
+				require.Equal(t, http.StatusOK, r.StatusCode)
+				require.True(t, handlerCalled)
+			},
+		},
+		{
+			name: "signature of request with no app ID can be verified",
+			setup: func() *http.Request {
+				bodyBytes := []byte(`{"type":"url_verification"}`)
+				req, err :=
+					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+				require.NoError(t, err)
+				req.Header.Add("Content-Type", "application/json")
+				signRequest(t, req, testAppSigningSecret, testTimestamp, bodyBytes)
+				return req
+			},
+			assertions: func(handlerCalled bool, r *http.Response) {
+				require.Equal(t, http.StatusOK, r.StatusCode)
+				require.True(t, handlerCalled)
+			},
+		},
+		{
+			name: "signature from unknown app cannot be verified",
+			setup: func() *http.Request {
+				bodyBytes := []byte(`{"api_app_id":"86"}`)
+				req, err :=
+					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+				require.NoError(t, err)
+				req.Header.Add("Content-Type", "application/json")
+				signRequest(t, req, testAppSigningSecret, testTimestamp, bodyBytes)
+				return req
+			},
+			assertions: func(handlerCalled bool, r *http.Response) {
+				require.Equal(t, http.StatusForbidden, r.StatusCode)
+				require.False(t, handlerCalled)
+			},
+		},
+		{
+			name: "timestamp is malformed",
+			setup: func() *http.Request {
+				bodyBytes := []byte(`{"api_app_id":"42"}`)
+				req, err :=
+					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+				require.NoError(t, err)
+				req.Header.Add("Content-Type", "application/json")
+				signRequest(t, req, testAppSigningSecret, "noon", bodyBytes)
+				return req
+			},
+			assertions: func(handlerCalled bool, r *http.Response) {
+				require.Equal(t, http.StatusForbidden, r.StatusCode)
+				require.False(t, handlerCalled)
+			},
+		},
+		{
+			name: "timestamp is too old",
+			setup: func() *http.Request {
+				bodyBytes := []byte(`{"api_app_id":"42"}`)
+				req, err :=
+					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+				require.NoError(t, err)
+				req.Header.Add("Content-Type", "application/json")
+				signRequest(
+					t,
+					req,
+					testAppSigningSecret,
+					strconv.FormatInt(testNow.Add(-6*time.Minute).Unix(), 10),
+					bodyBytes,
+				)
+				return req
+			},
+			assertions: func(handlerCalled bool, r *http.Response) {
+				require.Equal(t, http.StatusForbidden, r.StatusCode)
+				require.False(t, handlerCalled)
+			},
+		},
+		{
+			name: "timestamp is too far in the future",
+			setup: func() *http.Request {
+				bodyBytes := []byte(`{"api_app_id":"42"}`)
+				req, err :=
+					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+				require.NoError(t, err)
+				req.Header.Add("Content-Type", "application/json")
+				signRequest(
+					t,
+					req,
+					testAppSigningSecret,
+					strconv.FormatInt(testNow.Add(6*time.Minute).Unix(), 10),
+					bodyBytes,
+				)
+				return req
+			},
+			assertions: func(handlerCalled bool, r *http.Response) {
+				require.Equal(t, http.StatusForbidden, r.StatusCode)
+				require.False(t, handlerCalled)
+			},
+		},
+		{
+			name: "request body is too large",
+			setup: func() *http.Request {
+				bodyBytes := []byte(
+					fmt.Sprintf(
+						`{"api_app_id":"42","text":"%s"}`,
+						strings.Repeat("a", 64),
+					),
+				)
+				req, err :=
+					http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+				require.NoError(t, err)
+				req.Header.Add("Content-Type", "application/json")
+				signRequest(t, req, testAppSigningSecret, testTimestamp, bodyBytes)
+				return req
+			},
+			assertions: func(handlerCalled bool, r *http.Response) {
+				require.Equal(t, http.StatusRequestEntityTooLarge, r.StatusCode)
+				require.False(t, handlerCalled)
+			},
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
+			testFilter := &signatureVerificationFilter{
+				config:         testConfig,
+				seenSignatures: newSignatureCache(),
+				nowFn: func() time.Time {
+					return testNow
+				},
+			}
 			rr := httptest.NewRecorder()
 			req := testCase.setup()
 			handlerCalled := false
@@ -116,3 +309,89 @@ func TestSignatureVerificationFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestSignatureVerificationFilterRejectsReplays(t *testing.T) {
+	const testAppID = "42"
+	testAppSigningSecret := []byte("foobar")
+	testNow := time.Unix(1531420618, 0)
+	testFilter := &signatureVerificationFilter{
+		config: SignatureVerificationFilterConfig{
+			SlackApps: slack.NewApps(map[string]slack.App{
+				testAppID: {
+					AppID:            testAppID,
+					AppSigningSecret: string(testAppSigningSecret),
+				},
+			}),
+			TimestampTolerance:  5 * time.Minute,
+			MaxRequestBodyBytes: defaultMaxRequestBodyBytes,
+		},
+		seenSignatures: newSignatureCache(),
+		nowFn: func() time.Time {
+			return testNow
+		},
+	}
+	bodyBytes := []byte(`{"api_app_id":"42"}`)
+	handlerCalls := 0
+	handle := testFilter.Decorate(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		handlerCalls++
+		w.WriteHeader(http.StatusOK)
+	})
+	send := func() int {
+		req, err :=
+			http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(bodyBytes))
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/json")
+		signRequest(
+			t,
+			req,
+			testAppSigningSecret,
+			strconv.FormatInt(testNow.Unix(), 10),
+			bodyBytes,
+		)
+		rr := httptest.NewRecorder()
+		handle(rr, req)
+		res := rr.Result()
+		defer res.Body.Close()
+		return res.StatusCode
+	}
+	counter := signatureVerificationFailuresCounter.WithLabelValues(
+		signatureFailureReplay,
+	)
+	startingCount := testutil.ToFloat64(counter)
+	require.Equal(t, http.StatusOK, send())
+	// The same request, sent again, should be rejected
+	require.Equal(t, http.StatusForbidden, send())
+	require.Equal(t, 1, handlerCalls)
+	require.Equal(t, startingCount+1, testutil.ToFloat64(counter))
+}
+
+func TestSignatureCache(t *testing.T) {
+	cache := newSignatureCache()
+	now := time.Now()
+	require.True(t, cache.add("foo", now.Add(time.Minute), now))
+	require.False(t, cache.add("foo", now.Add(time.Minute), now))
+	require.True(t, cache.add("bar", now.Add(time.Minute), now))
+	// After foo and bar expire, they should be pruned and foo can be added again
+	later := now.Add(2 * time.Minute)
+	require.True(t, cache.add("foo", later.Add(time.Minute), later))
+	require.Len(t, cache.expirations, 1)
+}
+
+// signRequest computes a signature for the provided timestamp and request body
+// using the provided signing secret and adds it to the provided request.
+func signRequest(
+	t *testing.T,
+	req *http.Request,
+	signingSecret []byte,
+	timestamp string,
+	bodyBytes []byte,
+) {
+	req.Header.Add("X-Slack-Request-Timestamp", timestamp)
+	hasher := hmac.New(sha256.New, signingSecret)
+	_, err := hasher.Write(
+		[]byte(fmt.Sprintf("v0:%s:%s", timestamp, string(bodyBytes))),
+	)
+	require.NoError(t, err)
+	req.Header.Add("X-Slack-Signature", fmt.Sprintf("v0=%x", hasher.Sum(nil)))
+}
@@ -25,21 +25,9 @@ func (s *slashCommandHandler) ServeHTTP(
 	r *http.Request,
 ) {
 	defer r.Body.Close()
+	logRetry(r)
 	w.Header().Set("Content-Type", "application/json")
-	command := SlashCommand{
-		TeamID:         r.FormValue("team_id"),
-		TeamDomain:     r.FormValue("team_domain"),
-		EnterpriseID:   r.FormValue("enterprise_id"),
-		EnterpriseName: r.FormValue("enterprise_name"),
-		ChannelID:      r.FormValue("channel_id"),
-		ChannelName:    r.FormValue("channel_name"),
-		UserID:         r.FormValue("user_id"),
-		Command:        r.FormValue("command"),
-		Text:           r.FormValue("text"),
-		ResponseURL:    r.FormValue("response_url"),
-		TriggerID:      r.FormValue("trigger_id"),
-		APIAppID:       r.FormValue("api_app_id"),
-	}
+	command := newSlashCommand(r.FormValue)
 	response, err := s.service.Handle(r.Context(), command)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -49,3 +37,25 @@ func (s *slashCommandHandler) ServeHTTP(
 	w.WriteHeader(http.StatusOK)
 	w.Write(response) // nolint: errcheck
 }
+
+// newSlashCommand returns a SlashCommand populated from the fields of a slash
+// command request, as returned by the provided function. Slack uses the same
+// field names whether a slash command is sent as a form over HTTP or as the
+// payload of a Socket Mode envelope.
+func newSlashCommand(field func(string) string) SlashCommand {
+	return SlashCommand{
+		TeamID:              field("team_id"),
+		TeamDomain:          field("team_domain"),
+		EnterpriseID:        field("enterprise_id"),
+		EnterpriseName:      field("enterprise_name"),
+		ChannelID:           field("channel_id"),
+		ChannelName:         field("channel_name"),
+		UserID:              field("user_id"),
+		Command:             field("command"),
+		Text:                field("text"),
+		ResponseURL:         field("response_url"),
+		TriggerID:           field("trigger_id"),
+		APIAppID:            field("api_app_id"),
+		IsEnterpriseInstall: field("is_enterprise_install") == "true",
+	}
+}
@@ -69,7 +69,13 @@ func TestNewSlashCommandHandlerServeHTTP(t *testing.T) {
 }
 
 type mockSlashCommandService struct {
-	HandleFn func(context.Context, SlashCommand) ([]byte, error)
+	HandleFn           func(context.Context, SlashCommand) ([]byte, error)
+	HandleSubmissionFn func(
+		context.Context,
+		SlashCommand,
+		map[string]interface{},
+	) error
+	HandleConfirmationFn func(context.Context, Confirmation) error
 }
 
 func (m *mockSlashCommandService) Handle(
@@ -78,3 +84,18 @@ func (m *mockSlashCommandService) Handle(
 ) ([]byte, error) {
 	return m.HandleFn(ctx, command)
 }
+
+func (m *mockSlashCommandService) HandleSubmission(
+	ctx context.Context,
+	command SlashCommand,
+	values map[string]interface{},
+) error {
+	return m.HandleSubmissionFn(ctx, command, values)
+}
+
+func (m *mockSlashCommandService) HandleConfirmation(
+	ctx context.Context,
+	confirmation Confirmation,
+) error {
+	return m.HandleConfirmationFn(ctx, confirmation)
+}
@@ -3,9 +3,15 @@ package slack
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig"
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
 	"github.com/brigadecore/brigade/sdk/v3"
 	"github.com/pkg/errors"
 )
@@ -16,26 +22,127 @@ import (
 type SlashCommandService interface {
 	// Handle handles a slash command from Slack.
 	Handle(context.Context, SlashCommand) ([]byte, error)
+	// HandleSubmission handles the submission of a modal form that was opened to
+	// collect parameters for a slash command. Because the original slash command
+	// has already been responded to, acknowledgement is sent to the slash
+	// command's response URL.
+	HandleSubmission(context.Context, SlashCommand, map[string]interface{}) error
+	// HandleConfirmation handles a user's response to a request to confirm what
+	// will be emitted into Brigade in response to a slash command. The outcome
+	// is reported to the response URL of the interaction that carried the
+	// response.
+	HandleConfirmation(context.Context, Confirmation) error
+}
+
+// SlashCommandServiceConfig encapsulates configuration for the slash command
+// service.
+type SlashCommandServiceConfig struct {
+	// SlackApps is the set of Slack App configurations, which may be reloaded.
+	SlackApps *slack.Apps
+	// AsyncAck indicates whether slash commands should be acknowledged
+	// immediately, with events emitted into Brigade in the background and the
+	// outcome subsequently reported to the slash command's response URL. This
+	// prevents Slack from timing out a slash command when the Brigade API is
+	// slow to respond.
+	AsyncAck bool
+	// UserGroupCacheTTL specifies how long user group membership, which may be
+	// needed to evaluate policies, and users' email addresses, which may be
+	// needed to map Slack users to Brigade users, are cached. If not specified,
+	// a default of five minutes is used.
+	UserGroupCacheTTL time.Duration
+	// EnrichmentCacheTTL specifies how long details of users and channels, which
+	// events may be enriched with, are cached. If not specified, a default of
+	// five minutes is used.
+	EnrichmentCacheTTL time.Duration
+	// ConfirmationTimeout specifies how long events emitted in response to slash
+	// commands that require confirmation are held pending confirmation. If not
+	// specified, a default of five minutes is used.
+	ConfirmationTimeout time.Duration
+	// TokenStore optionally specifies where to find the bot tokens granted when
+	// Apps were installed into individual workspaces using OAuth. If specified,
+	// those tokens are used in place of the API tokens in the App configurations.
+	TokenStore slack.TokenStore
 }
 
 type slashCommandService struct {
-	eventsClient   sdk.EventsClient
-	ackMsgTemplate *template.Template
+	config              SlashCommandServiceConfig
+	projectsClient      sdk.ProjectsClient
+	eventsClient        sdk.EventsClient
+	apiClient           slack.APIClient
+	ackMsgTemplate      *template.Template
+	commandFormTemplate *template.Template
+	authorizer          *authorizer
+	identities          *identityResolver
+	enricher            *enricher
+	confirmations       *confirmations
+	builtins            *builtinSubcommands
+	payloads            *payloadRenderer
+	// goFn runs the provided function in the background. It is overridable for
+	// testing purposes.
+	goFn func(func())
 }
 
 // NewSlashCommandService returns an implementation of the Service interface for
 // handling slash commands from Slack.
 func NewSlashCommandService(
+	projectsClient sdk.ProjectsClient,
 	eventsClient sdk.EventsClient,
+	apiClient slack.APIClient,
+	config SlashCommandServiceConfig,
 ) (SlashCommandService, error) {
 	ackMsgTemplate, err :=
 		template.New("template").Funcs(sprig.TxtFuncMap()).Parse(ackMsgTemplate)
 	if err != nil {
 		return nil, errors.Wrap(err, "error parsing response template")
 	}
+	commandFormTemplate, err := template.New(
+		"template",
+	).Funcs(sprig.TxtFuncMap()).Parse(commandFormTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing command form template")
+	}
+	builtins, err := newBuiltinSubcommands(projectsClient, eventsClient)
+	if err != nil {
+		return nil, err
+	}
+	payloads, err := newPayloadRenderer(config.SlackApps.All())
+	if err != nil {
+		return nil, err
+	}
+	if config.UserGroupCacheTTL <= 0 {
+		config.UserGroupCacheTTL = 5 * time.Minute
+	}
+	if config.EnrichmentCacheTTL <= 0 {
+		config.EnrichmentCacheTTL = 5 * time.Minute
+	}
+	if config.ConfirmationTimeout <= 0 {
+		config.ConfirmationTimeout = 5 * time.Minute
+	}
+	confirmations, err :=
+		newConfirmations(projectsClient, config.ConfirmationTimeout)
+	if err != nil {
+		return nil, err
+	}
 	return &slashCommandService{
-		eventsClient:   eventsClient,
-		ackMsgTemplate: ackMsgTemplate,
+		config:              config,
+		projectsClient:      projectsClient,
+		eventsClient:        eventsClient,
+		apiClient:           apiClient,
+		ackMsgTemplate:      ackMsgTemplate,
+		commandFormTemplate: commandFormTemplate,
+		authorizer:          newAuthorizer(apiClient, config.UserGroupCacheTTL),
+		identities: newIdentityResolver(
+			projectsClient,
+			apiClient,
+			config.UserGroupCacheTTL,
+		),
+		enricher:      newEnricher(apiClient, config.EnrichmentCacheTTL),
+		confirmations: confirmations,
+		builtins:      builtins,
+		payloads:      payloads,
+		goFn: func(fn func()) {
+			go fn()
+		},
 	}, nil
 }
 
@@ -43,50 +150,564 @@ func (s *slashCommandService) Handle(
 	ctx context.Context,
 	command SlashCommand,
 ) ([]byte, error) {
-	event := sdk.Event{
-		Source: "brigade.sh/slack",
-		Type:   command.Command[1:], // Strip the leading slash from the command
-		// A workspace can have multiple apps installed that all use the same slash
-		// command, so events are qualified with WHICH app produced them.
-		Qualifiers: map[string]string{
-			"appID": command.APIAppID,
-		},
-		Labels: map[string]string{
-			"teamID":    command.TeamID,
-			"channelID": command.ChannelID,
-			"userID":    command.UserID,
-		},
-		SourceState: &sdk.SourceState{
-			State: map[string]string{
-				"tracking": "true",
-			},
-		},
-		Payload: command.Text,
+	app := s.app(ctx, command)
+	cmdConfig, ok := app.Command(command.Command)
+	if !ok {
+		// Unconfigured commands emit events whose type is the command itself.
+		cmdConfig = slack.Command{Command: command.Command}
+	}
+	if !s.authorize(ctx, app, cmdConfig, command) {
+		recordOutcome(ctx, outcomeDenied)
+		return ephemeralMessage(deniedMessage(command)), nil
+	}
+	// Built-in subcommands only read from Brigade, so they're always handled
+	// synchronously and take precedence over any configured subcommands.
+	if cmdConfig.BuiltinSubcommands {
+		if response, handled, err :=
+			s.builtins.handle(ctx, command); handled {
+			return response, err
+		}
+	}
+	// If the command has parameters and the user didn't supply any text, open a
+	// modal form to collect values for those parameters instead of emitting an
+	// event right away.
+	if command.Text == "" && command.TriggerID != "" &&
+		len(cmdConfig.Parameters) > 0 {
+		return nil, s.openCommandForm(ctx, app, command, cmdConfig)
+	}
+	eventType, text := cmdConfig.Route(command.Text)
+	// If the command has typed arguments, invalid text is rejected before
+	// anything reaches Brigade.
+	var values map[string]interface{}
+	var labels map[string]string
+	if len(cmdConfig.Arguments) > 0 {
+		var err error
+		if values, labels, err = cmdConfig.ParseArguments(text); err != nil {
+			recordOutcome(ctx, outcomeInvalidArguments)
+			return ephemeralMessage(
+				usageMessage(command, cmdConfig, err),
+			), nil
+		}
+	}
+	if s.config.AsyncAck && command.ResponseURL != "" {
+		s.goFn(func() {
+			s.emitAndRespond(command, eventType, text, values, labels)
+		})
+		recordOutcome(ctx, outcomeAccepted)
+		return ephemeralMessage(asyncAckMsg), nil
+	}
+	return s.emit(ctx, command, eventType, text, values, labels)
+}
+
+func (s *slashCommandService) HandleSubmission(
+	ctx context.Context,
+	command SlashCommand,
+	values map[string]interface{},
+) error {
+	app := s.app(ctx, command)
+	cmdConfig, ok := app.Command(command.Command)
+	if !ok {
+		cmdConfig = slack.Command{Command: command.Command}
+	}
+	// Policies are re-evaluated because they may have changed since the form
+	// was opened.
+	if !s.authorize(ctx, app, cmdConfig, command) {
+		return s.apiClient.Respond(
+			ctx,
+			command.ResponseURL,
+			ephemeralMessage(deniedMessage(command)),
+		)
+	}
+	eventType, _ := cmdConfig.Route("")
+	if s.config.AsyncAck {
+		s.goFn(func() {
+			s.emitAndRespond(command, eventType, "", values, nil)
+		})
+		return nil
+	}
+	ack, err := s.emit(ctx, command, eventType, "", values, nil)
+	if err != nil {
+		return err
+	}
+	return s.apiClient.Respond(ctx, command.ResponseURL, ack)
+}
+
+func (s *slashCommandService) HandleConfirmation(
+	ctx context.Context,
+	confirmation Confirmation,
+) error {
+	pending, ok := s.confirmations.get(confirmation.ID)
+	if !ok {
+		return s.apiClient.Respond(
+			ctx,
+			confirmation.ResponseURL,
+			replacementMessage(confirmationExpiredMsg),
+		)
+	}
+	// Only the user who invoked the slash command may confirm or cancel it.
+	if pending.command.UserID != confirmation.UserID {
+		log.Printf(
+			"denied confirmation of command %q for app %q by user %q, who did "+
+				"not invoke it",
+			pending.command.Command,
+			pending.command.APIAppID,
+			confirmation.UserID,
+		)
+		return s.apiClient.Respond(
+			ctx,
+			confirmation.ResponseURL,
+			ephemeralMessage(
+				fmt.Sprintf(
+					"Sorry, only the user who invoked %s may confirm it.",
+					pending.command.Command,
+				),
+			),
+		)
+	}
+	if pending, ok = s.confirmations.take(confirmation.ID); !ok {
+		return s.apiClient.Respond(
+			ctx,
+			confirmation.ResponseURL,
+			replacementMessage(confirmationExpiredMsg),
+		)
+	}
+	if !confirmation.Confirmed {
+		return s.apiClient.Respond(
+			ctx,
+			confirmation.ResponseURL,
+			replacementMessage("Cancelled. No events were created."),
+		)
+	}
+	app := s.app(ctx, pending.command)
+	ack, err := s.create(ctx, app, pending.command, pending.event)
+	if err != nil {
+		return err
+	}
+	if err = s.apiClient.Respond(
+		ctx,
+		confirmation.ResponseURL,
+		replacementMessage("Confirmed."),
+	); err != nil {
+		return err
+	}
+	return s.apiClient.Respond(ctx, confirmation.ResponseURL, ack)
+}
+
+// app returns configuration for the App that sent the provided slash command.
+// If the App was installed into the workspace the slash command was invoked
+// from, or into the Enterprise Grid organization it belongs to, using OAuth,
+// the configuration uses the bot token granted by that installation. Failure
+// to look up the installation is logged and results in the App's configured
+// API tokens being used.
+func (s *slashCommandService) app(
+	ctx context.Context,
+	command SlashCommand,
+) slack.App {
+	teamID := command.TeamID
+	// Slash commands received via an org-wide installation are answered using
+	// that installation's token, even if the App was also installed into the
+	// workspace itself.
+	if command.IsEnterpriseInstall {
+		teamID = ""
+	}
+	app, _ := s.config.SlackApps.Get(command.APIAppID)
+	app, err := slack.AppForTeam(
+		ctx,
+		s.config.TokenStore,
+		app,
+		command.EnterpriseID,
+		teamID,
+	)
+	if err != nil {
+		log.Println(err)
+	}
+	return app
+}
+
+// authorize returns a bool indicating whether the provided App's and slash
+// command's policies, as well as any policy for the Enterprise Grid
+// organization the slash command was invoked from, permit the provided slash
+// command. Denials are logged.
+func (s *slashCommandService) authorize(
+	ctx context.Context,
+	app slack.App,
+	cmdConfig slack.Command,
+	command SlashCommand,
+) bool {
+	enterprise, _ := app.Enterprise(command.EnterpriseID)
+	if s.authorizer.authorize(
+		ctx,
+		app,
+		commandOrigin(command),
+		app.Policy,
+		enterprise.Policy,
+		cmdConfig.Policy,
+	) {
+		return true
+	}
+	log.Printf(
+		"denied command %q for app %q from user %q in channel %q of team %q",
+		command.Command,
+		command.APIAppID,
+		command.UserID,
+		command.ChannelID,
+		command.TeamID,
+	)
+	return false
+}
+
+// deniedMessage returns the text of the message sent to a user who is not
+// permitted to invoke the provided slash command.
+func deniedMessage(command SlashCommand) string {
+	return fmt.Sprintf(
+		"Sorry, you are not permitted to use %s here.",
+		command.Command,
+	)
+}
+
+// usageMessage returns the text of the message sent to a user who invoked the
+// provided slash command with text that could not be parsed according to the
+// slash command's arguments.
+func usageMessage(
+	command SlashCommand,
+	cmdConfig slack.Command,
+	err error,
+) string {
+	return fmt.Sprintf(
+		"Sorry, %s.\n%s",
+		err,
+		cmdConfig.Usage(command.Command),
+	)
+}
+
+// checkIdentity maps the Slack user who invoked the provided slash command to a
+// Brigade user and verifies that Brigade user holds the required role for every
+// project subscribed to the provided event. If so, the Brigade user's ID is
+// added to the event's labels. If not, the denial is logged and the text of a
+// message explaining it is returned.
+func (s *slashCommandService) checkIdentity(
+	ctx context.Context,
+	app slack.App,
+	command SlashCommand,
+	event *sdk.Event,
+) (string, error) {
+	brigadeUserID, ok, err :=
+		s.identities.brigadeUserID(ctx, app, command.UserID)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		log.Printf(
+			"denied command %q for app %q from unmapped user %q",
+			command.Command,
+			command.APIAppID,
+			command.UserID,
+		)
+		return fmt.Sprintf(
+			"Sorry, your Slack account is not associated with a Brigade user, so "+
+				"you are not permitted to use %s.",
+			command.Command,
+		), nil
+	}
+	// The label is added before checking roles because projects' subscriptions
+	// may match on it.
+	event.Labels["brigadeUserID"] = brigadeUserID
+	projectIDs, err :=
+		s.identities.unauthorizedProjects(ctx, app, *event, brigadeUserID)
+	if err != nil {
+		return "", err
+	}
+	if len(projectIDs) > 0 {
+		log.Printf(
+			"denied command %q for app %q from user %q (brigade user %q) "+
+				"lacking required role for project(s) %s",
+			command.Command,
+			command.APIAppID,
+			command.UserID,
+			brigadeUserID,
+			strings.Join(projectIDs, ", "),
+		)
+		return fmt.Sprintf(
+			"Sorry, Brigade user %s lacks the role required to use %s for "+
+				"project(s): %s",
+			brigadeUserID,
+			command.Command,
+			strings.Join(projectIDs, ", "),
+		), nil
 	}
-	// This information is only present for Slack Enterprise Grid customers. We're
-	// only including the label for those cases rather than always including it
-	// and having its value often be the empty string.
-	if command.EnterpriseID != "" {
-		event.Labels["enterprise_id"] = command.EnterpriseID
+	return "", nil
+}
+
+// commandOrigin returns details of where, within Slack, the provided slash
+// command originated.
+func commandOrigin(command SlashCommand) origin {
+	return origin{
+		AppID:        command.APIAppID,
+		EnterpriseID: command.EnterpriseID,
+		TeamID:       command.TeamID,
+		ChannelID:    command.ChannelID,
+		UserID:       command.UserID,
+	}
+}
+
+// emitAndRespond emits an event of the specified type into Brigade for the
+// provided slash command, using the provided text, parameter or argument
+// values, and labels, and reports the outcome to the slash command's response
+// URL. It is intended to be run in the background, after the slash command has
+// already been acknowledged, so errors are logged instead of returned.
+func (s *slashCommandService) emitAndRespond(
+	command SlashCommand,
+	eventType string,
+	text string,
+	values map[string]interface{},
+	labels map[string]string,
+) {
+	ctx := context.Background()
+	ack, err := s.emit(ctx, command, eventType, text, values, labels)
+	if err != nil {
+		log.Printf(
+			"error handling command %q asynchronously: %s",
+			command.Command,
+			err,
+		)
+		ack = ephemeralMessage(asyncErrorMsg)
+	}
+	if err = s.apiClient.Respond(ctx, command.ResponseURL, ack); err != nil {
+		log.Printf(
+			"error responding to command %q asynchronously: %s",
+			command.Command,
+			err,
+		)
+	}
+}
+
+// emit emits an event of the specified type into Brigade for the provided
+// slash command, with a payload, additional labels, and titles formed from the
+// provided text and parameter or argument values, and returns a rendered
+// acknowledgement. Labels derived from arguments are added as well. If the App
+// enriches events, details of the user and channel are added to the labels and
+// made available to payload templates. If the App maps Slack users to Brigade
+// users and the user who invoked the slash command is unmapped or lacks the
+// required role for any subscribed project, no event is emitted and the
+// returned acknowledgement explains why. If the slash command requires
+// confirmation, the event is held pending confirmation and the returned
+// message asks for it instead.
+func (s *slashCommandService) emit(
+	ctx context.Context,
+	command SlashCommand,
+	eventType string,
+	text string,
+	values map[string]interface{},
+	labels map[string]string,
+) ([]byte, error) {
+	app := s.app(ctx, command)
+	data := newCommandPayload(command, eventType, text, values)
+	if app.EnrichEvents {
+		data.User, data.Channel =
+			s.enricher.enrich(ctx, app, command.UserID, command.ChannelID)
+	}
+	rendered, err := s.payloads.render(app.CommandPayload(command.Command), data)
+	if err != nil {
+		return nil, err
+	}
+	event := newEvent(commandOrigin(command), eventType, rendered.Payload)
+	event.ShortTitle = rendered.ShortTitle
+	event.LongTitle = rendered.LongTitle
+	if data.User != nil && data.User.Name != "" {
+		event.Labels["userName"] = data.User.Name
+	}
+	if data.Channel != nil && data.Channel.Name != "" {
+		event.Labels["channelName"] = data.Channel.Name
 	}
+	// Labels derived from the slash command itself take precedence over
+	// operator-supplied ones and those, in turn, take precedence over ones
+	// supplied by the user via arguments.
+	for _, extraLabels := range []map[string]string{rendered.Labels, labels} {
+		for key, value := range extraLabels {
+			if _, ok := event.Labels[key]; !ok {
+				event.Labels[key] = value
+			}
+		}
+	}
+	if app.Identities != nil {
+		msg, err := s.checkIdentity(ctx, app, command, &event)
+		if err != nil {
+			return nil, err
+		}
+		if msg != "" {
+			recordOutcome(ctx, outcomeDenied)
+			return ephemeralMessage(msg), nil
+		}
+	}
+	visibility := app.CommandVisibility(command.Command)
+	// The monitor reports status to the channel by default. If the status report
+	// should be delivered some other way, it will find out how from the event's
+	// source state.
+	if event.SourceState != nil && visibility.Status != "" &&
+		visibility.Status != slack.VisibilityInChannel {
+		event.SourceState.State["statusVisibility"] = visibility.Status
+	}
+	if cmdConfig, ok := app.Command(command.Command); ok &&
+		cmdConfig.RequiresConfirmation(command.Text) {
+		return s.confirmations.request(ctx, command, event)
+	}
+	return s.create(ctx, app, command, event)
+}
+
+// create emits the provided event, which resulted from the provided slash
+// command, into Brigade and returns a rendered acknowledgement.
+func (s *slashCommandService) create(
+	ctx context.Context,
+	app slack.App,
+	command SlashCommand,
+	event sdk.Event,
+) ([]byte, error) {
 	events, err := s.eventsClient.Create(context.Background(), event, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "error emitting event(s) into Brigade")
 	}
 	message := struct {
-		Channel string
-		Events  []sdk.Event
+		ResponseType string
+		Channel      string
+		Events       []sdk.Event
+		NoEventsText string
 	}{
-		Channel: command.ChannelID,
-		Events:  events.Items,
+		ResponseType: responseTypeInChannel,
+		Channel:      command.ChannelID,
+		Events:       events.Items,
+	}
+	if len(events.Items) == 0 {
+		message.NoEventsText = s.noEventsText(ctx, app, command, event.Type)
+	}
+	if app.CommandVisibility(command.Command).Ack ==
+		slack.VisibilityEphemeral {
+		message.ResponseType = responseTypeEphemeral
 	}
 	buffer := &bytes.Buffer{}
 	err = s.ackMsgTemplate.Execute(buffer, message)
 	return buffer.Bytes(), errors.Wrap(err, "error rendering response")
 }
 
+// noEventsText returns the text used to inform the user that no projects are
+// subscribed to the event that the provided slash command resulted in. To help
+// the user figure out what they might have meant instead, similar event types
+// are suggested and, if available, the built-in help subcommand is mentioned.
+func (s *slashCommandService) noEventsText(
+	ctx context.Context,
+	app slack.App,
+	command SlashCommand,
+	eventType string,
+) string {
+	text := "No projects subscribed to this event."
+	suggestions := s.suggestEventTypes(ctx, app, eventType)
+	if len(suggestions) > 0 {
+		text = fmt.Sprintf(
+			"%s Did you mean %s?",
+			text,
+			strings.Join(suggestions, " or "),
+		)
+	}
+	if cmdConfig, ok := app.Command(command.Command); ok &&
+		cmdConfig.BuiltinSubcommands {
+		text = fmt.Sprintf(
+			"%s Try %s help to see what's available.",
+			text,
+			cmdConfig.Command,
+		)
+	}
+	return text
+}
+
+// suggestEventTypes returns event types that projects subscribe to, on behalf
+// of the provided App, that are similar to the specified event type. Since
+// suggestions are merely a courtesy, failure to list projects is logged and
+// results in no suggestions.
+func (s *slashCommandService) suggestEventTypes(
+	ctx context.Context,
+	app slack.App,
+	eventType string,
+) []string {
+	projects, err := subscribedProjects(ctx, s.projectsClient, app.AppID)
+	if err != nil {
+		log.Printf("error suggesting event types: %s", err)
+		return nil
+	}
+	eventTypes := []string{}
+	for _, project := range projects {
+		for _, sub := range appSubscriptions(project, app.AppID) {
+			eventTypes = append(eventTypes, sub.Types...)
+		}
+	}
+	return closestEventTypes(eventType, eventTypes)
+}
+
+// openCommandForm opens a modal form for collecting values for each of the
+// provided slash command's parameters. The slash command itself is stored in
+// the form's private metadata so it can be retrieved when the form is
+// submitted.
+func (s *slashCommandService) openCommandForm(
+	ctx context.Context,
+	app slack.App,
+	command SlashCommand,
+	cmdConfig slack.Command,
+) error {
+	metadata, err := json.Marshal(command)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling slash command")
+	}
+	buffer := &bytes.Buffer{}
+	if err = s.commandFormTemplate.Execute(
+		buffer,
+		struct {
+			CallbackID      string
+			Command         string
+			PrivateMetadata string
+			Parameters      []slack.Parameter
+		}{
+			CallbackID:      commandFormCallbackID,
+			Command:         command.Command,
+			PrivateMetadata: string(metadata),
+			Parameters:      cmdConfig.Parameters,
+		},
+	); err != nil {
+		return errors.Wrap(err, "error rendering command form")
+	}
+	return errors.Wrapf(
+		slack.CallWithFallback(
+			ctx,
+			s.apiClient,
+			app.APITokens(),
+			"views.open",
+			struct {
+				TriggerID string          `json:"trigger_id"`
+				View      json.RawMessage `json:"view"`
+			}{
+				TriggerID: command.TriggerID,
+				View:      buffer.Bytes(),
+			},
+			nil,
+		),
+		"error opening form for command %q",
+		command.Command,
+	)
+}
+
+const (
+	// asyncAckMsg is the text of the immediate acknowledgement sent when a slash
+	// command is handled asynchronously.
+	asyncAckMsg = "Working on it..."
+	// confirmationExpiredMsg is the text of the message that replaces a request
+	// for confirmation that is responded to after it has expired.
+	confirmationExpiredMsg = "This request has expired. No events were " +
+		"created."
+	// asyncErrorMsg is the text of the message sent to a slash command's
+	// response URL when emitting events asynchronously fails.
+	asyncErrorMsg = "Sorry, something went wrong and no events could be " +
+		"created. Please try again."
+)
+
 var ackMsgTemplate = `{
-  "response_type": "in_channel",
+  "response_type": {{ quote .ResponseType }},
   "channel": {{ quote .Channel }},
   "blocks": [
     {
@@ -104,8 +725,8 @@ var ackMsgTemplate = `{
     {
       "type": "section",
       "text": {
-        "type": "plain_text",
-        "text": "No projects subscribed to this event."
+        "type": "mrkdwn",
+        "text": {{ quote .NoEventsText }}
       }
     }
     {{- else }}
@@ -141,3 +762,99 @@ var ackMsgTemplate = `{
     {{- end }}
   ]
 }`
+
+// commandFormCallbackID identifies modal forms opened to collect parameters for
+// a slash command.
+const commandFormCallbackID = "brigade-command-form"
+
+var commandFormTemplate = `{
+  "type": "modal",
+  "callback_id": {{ quote .CallbackID }},
+  "private_metadata": {{ quote .PrivateMetadata }},
+  "title": {
+    "type": "plain_text",
+    "text": {{ quote (trunc 24 .Command) }}
+  },
+  "submit": {
+    "type": "plain_text",
+    "text": "Submit"
+  },
+  "close": {
+    "type": "plain_text",
+    "text": "Cancel"
+  },
+  "blocks": [
+    {{- $params := .Parameters }}
+    {{- range $index, $param := $params }}
+    {{- $label := default .Name .Label }}
+    {
+      "type": "input",
+      "block_id": {{ quote .Name }},
+      "optional": {{ or .Optional (eq .Type "checkbox") }},
+      "label": {
+        "type": "plain_text",
+        "text": {{ quote $label }}
+      },
+      {{- if eq .Type "select" }}
+      "element": {
+        "type": "static_select",
+        "action_id": {{ quote .Name }},
+        {{- if .Default }}
+        "initial_option": {
+          "text": {
+            "type": "plain_text",
+            "text": {{ quote .Default }}
+          },
+          "value": {{ quote .Default }}
+        },
+        {{- end }}
+        "options": [
+          {{- range $i, $option := .Options }}
+          {
+            "text": {
+              "type": "plain_text",
+              "text": {{ quote $option }}
+            },
+            "value": {{ quote $option }}
+          }{{ if not (eq (add $i 1) (len $param.Options)) }},{{ end }}
+          {{- end }}
+        ]
+      }
+      {{- else if eq .Type "checkbox" }}
+      "element": {
+        "type": "checkboxes",
+        "action_id": {{ quote .Name }},
+        {{- if eq .Default "true" }}
+        "initial_options": [
+          {
+            "text": {
+              "type": "plain_text",
+              "text": {{ quote $label }}
+            },
+            "value": "true"
+          }
+        ],
+        {{- end }}
+        "options": [
+          {
+            "text": {
+              "type": "plain_text",
+              "text": {{ quote $label }}
+            },
+            "value": "true"
+          }
+        ]
+      }
+      {{- else }}
+      "element": {
+        "type": "plain_text_input",
+        {{- if .Default }}
+        "initial_value": {{ quote .Default }},
+        {{- end }}
+        "action_id": {{ quote .Name }}
+      }
+      {{- end }}
+    }{{ if not (eq (add $index 1) (len $params)) }},{{ end }}
+    {{- end }}
+  ]
+}`
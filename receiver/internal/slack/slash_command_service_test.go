@@ -1,12 +1,16 @@
 package slack
 
+// nolint: lll
 import (
 	"context"
 	"encoding/json"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig"
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
 	"github.com/brigadecore/brigade/sdk/v3"
 	"github.com/brigadecore/brigade/sdk/v3/meta"
 	sdkTesting "github.com/brigadecore/brigade/sdk/v3/testing"
@@ -16,17 +20,28 @@ import (
 
 func TestNewSlashCommandService(t *testing.T) {
 	s, err := NewSlashCommandService(
-		// Totally unusable client that is enough to fulfill the dependencies for
+		// Totally unusable clients that are enough to fulfill the dependencies for
 		// this test...
+		&sdkTesting.MockProjectsClient{},
 		&sdkTesting.MockEventsClient{
 			LogsClient: &sdkTesting.MockLogsClient{},
 		},
+		&slackTesting.MockAPIClient{},
+		SlashCommandServiceConfig{},
 	)
 	require.NoError(t, err)
 	svc, ok := s.(*slashCommandService)
 	require.True(t, ok)
+	require.NotNil(t, svc.projectsClient)
 	require.NotNil(t, svc.eventsClient)
+	require.NotNil(t, svc.apiClient)
 	require.NotNil(t, svc.ackMsgTemplate)
+	require.NotNil(t, svc.commandFormTemplate)
+	require.NotNil(t, svc.authorizer)
+	require.NotNil(t, svc.identities)
+	require.NotNil(t, svc.enricher)
+	require.NotNil(t, svc.confirmations)
+	require.NotNil(t, svc.builtins)
 }
 
 func TestSlashCommandServiceHandle(t *testing.T) {
@@ -46,6 +61,7 @@ func TestSlashCommandServiceHandle(t *testing.T) {
 		{
 			name: "error creating brigade event",
 			service: &slashCommandService{
+				projectsClient: emptyProjectsClient(),
 				eventsClient: &sdkTesting.MockEventsClient{
 					CreateFn: func(
 						context.Context,
@@ -69,6 +85,7 @@ func TestSlashCommandServiceHandle(t *testing.T) {
 		{
 			name: "success with no subscribers",
 			service: &slashCommandService{
+				projectsClient: emptyProjectsClient(),
 				eventsClient: &sdkTesting.MockEventsClient{
 					CreateFn: func(
 						_ context.Context,
@@ -119,6 +136,7 @@ func TestSlashCommandServiceHandle(t *testing.T) {
 		{
 			name: "success with subscribers",
 			service: &slashCommandService{
+				projectsClient: emptyProjectsClient(),
 				eventsClient: &sdkTesting.MockEventsClient{
 					CreateFn: func(
 						_ context.Context,
@@ -189,9 +207,1683 @@ func TestSlashCommandServiceHandle(t *testing.T) {
 				"template",
 			).Funcs(sprig.TxtFuncMap()).Parse(ackMsgTemplate)
 			require.NoError(t, err)
+			testCase.service.authorizer = newAuthorizer(nil, time.Minute)
+			testCase.service.payloads, err = newPayloadRenderer(nil)
+			require.NoError(t, err)
 			response, err :=
 				testCase.service.Handle(context.Background(), testCommand)
 			testCase.assertions(response, err)
 		})
 	}
 }
+
+func TestSlashCommandServiceHandleWithParameters(t *testing.T) {
+	testCommand := SlashCommand{
+		Command:     "/deploy",
+		APIAppID:    "control-app",
+		TeamID:      "control",
+		ChannelID:   "cone-of-silence",
+		UserID:      "86",
+		TriggerID:   "13345224609.738474920.8088930838d88f008e0",
+		ResponseURL: "https://hooks.slack.com/commands/1234/5678",
+	}
+	testConfig := SlashCommandServiceConfig{
+		SlackApps: slack.NewApps(map[string]slack.App{
+			testCommand.APIAppID: {
+				AppID:    testCommand.APIAppID,
+				APIToken: "foo",
+				Commands: []slack.Command{
+					{
+						Command: testCommand.Command,
+						Parameters: []slack.Parameter{
+							{
+								Name:    "environment",
+								Type:    slack.ParameterTypeSelect,
+								Options: []string{"staging", "prod"},
+								Default: "staging",
+							},
+							{
+								Name:  "version",
+								Label: "Version",
+							},
+							{
+								Name:    "dryRun",
+								Label:   "Dry run",
+								Type:    slack.ParameterTypeCheckbox,
+								Default: "true",
+							},
+						},
+					},
+				},
+			},
+		}),
+	}
+	testCases := []struct {
+		name         string
+		command      func() SlashCommand
+		eventsClient sdk.EventsClient
+		apiClient    slack.APIClient
+		assertions   func([]byte, error)
+	}{
+		{
+			name: "error opening form",
+			command: func() SlashCommand {
+				return testCommand
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					context.Context,
+					string,
+					string,
+					interface{},
+					interface{},
+				) error {
+					return errors.New("something went wrong")
+				},
+			},
+			assertions: func(_ []byte, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error opening form for command")
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name: "success opening form",
+			command: func() SlashCommand {
+				return testCommand
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					token string,
+					method string,
+					args interface{},
+					_ interface{},
+				) error {
+					require.Equal(t, "foo", token)
+					require.Equal(t, "views.open", method)
+					argBytes, err := json.Marshal(args)
+					require.NoError(t, err)
+					view := struct {
+						TriggerID string `json:"trigger_id"`
+						View      struct {
+							CallbackID      string                   `json:"callback_id"`
+							PrivateMetadata string                   `json:"private_metadata"`
+							Blocks          []map[string]interface{} `json:"blocks"`
+						} `json:"view"`
+					}{}
+					err = json.Unmarshal(argBytes, &view)
+					require.NoError(t, err)
+					require.Equal(t, testCommand.TriggerID, view.TriggerID)
+					require.Equal(t, commandFormCallbackID, view.View.CallbackID)
+					command := SlashCommand{}
+					err = json.Unmarshal([]byte(view.View.PrivateMetadata), &command)
+					require.NoError(t, err)
+					require.Equal(t, testCommand, command)
+					require.Len(t, view.View.Blocks, 3)
+					return nil
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response)
+			},
+		},
+		{
+			name: "command with text is emitted right away",
+			command: func() SlashCommand {
+				command := testCommand
+				command.Text = "prod"
+				return command
+			},
+			eventsClient: &sdkTesting.MockEventsClient{
+				CreateFn: func(
+					_ context.Context,
+					event sdk.Event,
+					_ *sdk.EventCreateOptions,
+				) (sdk.EventList, error) {
+					require.Equal(t, "deploy", event.Type)
+					require.Equal(t, "prod", event.Payload)
+					return sdk.EventList{}, nil
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Contains(t, string(response), "No Events Created")
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				testCase.eventsClient,
+				testCase.apiClient,
+				testConfig,
+			)
+			require.NoError(t, err)
+			response, err :=
+				service.Handle(context.Background(), testCase.command())
+			testCase.assertions(response, err)
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleWithRouting(t *testing.T) {
+	testConfig := SlashCommandServiceConfig{
+		SlackApps: slack.NewApps(map[string]slack.App{
+			"control-app": {
+				AppID: "control-app",
+				Commands: []slack.Command{
+					{
+						Command: "/brigade",
+						Aliases: []string{"/bdg"},
+						Subcommands: []slack.Subcommand{
+							{
+								Name:    "deploy",
+								Aliases: []string{"ship"},
+							},
+						},
+					},
+					{
+						Command:   "/ship",
+						Aliases:   []string{"/deploy"},
+						EventType: "brigade.deploy",
+					},
+				},
+			},
+		}),
+	}
+	testCases := []struct {
+		name              string
+		command           string
+		text              string
+		expectedEventType string
+		expectedPayload   string
+	}{
+		{
+			name:              "unconfigured command",
+			command:           "/foo",
+			text:              "deploy prod",
+			expectedEventType: "foo",
+			expectedPayload:   "deploy prod",
+		},
+		{
+			name:              "subcommand",
+			command:           "/brigade",
+			text:              "deploy prod",
+			expectedEventType: "brigade.deploy",
+			expectedPayload:   "prod",
+		},
+		{
+			name:              "command alias and subcommand alias",
+			command:           "/bdg",
+			text:              "ship prod",
+			expectedEventType: "brigade.deploy",
+			expectedPayload:   "prod",
+		},
+		{
+			name:              "unknown subcommand",
+			command:           "/brigade",
+			text:              "rollback prod",
+			expectedEventType: "brigade",
+			expectedPayload:   "rollback prod",
+		},
+		{
+			name:              "command with canonical event type",
+			command:           "/deploy",
+			text:              "prod",
+			expectedEventType: "brigade.deploy",
+			expectedPayload:   "prod",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Equal(t, testCase.expectedEventType, event.Type)
+						require.Equal(t, testCase.expectedPayload, event.Payload)
+						return sdk.EventList{}, nil
+					},
+				},
+				&slackTesting.MockAPIClient{},
+				testConfig,
+			)
+			require.NoError(t, err)
+			_, err = service.Handle(
+				context.Background(),
+				SlashCommand{
+					Command:  testCase.command,
+					APIAppID: "control-app",
+					Text:     testCase.text,
+				},
+			)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleVisibility(t *testing.T) {
+	testConfig := SlashCommandServiceConfig{
+		SlackApps: slack.NewApps(map[string]slack.App{
+			"control-app": {
+				AppID: "control-app",
+				Commands: []slack.Command{
+					{
+						Command: "/rotate-secrets",
+						Visibility: slack.Visibility{
+							Ack:    slack.VisibilityEphemeral,
+							Status: slack.VisibilityDM,
+						},
+					},
+				},
+			},
+		}),
+	}
+	testCases := []struct {
+		name                     string
+		command                  string
+		expectedResponseType     string
+		expectedStatusVisibility string
+	}{
+		{
+			name:                 "default visibility",
+			command:              "/deploy",
+			expectedResponseType: responseTypeInChannel,
+		},
+		{
+			name:                     "private command",
+			command:                  "/rotate-secrets",
+			expectedResponseType:     responseTypeEphemeral,
+			expectedStatusVisibility: slack.VisibilityDM,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Equal(
+							t,
+							testCase.expectedStatusVisibility,
+							event.SourceState.State["statusVisibility"],
+						)
+						return sdk.EventList{}, nil
+					},
+				},
+				&slackTesting.MockAPIClient{},
+				testConfig,
+			)
+			require.NoError(t, err)
+			response, err := service.Handle(
+				context.Background(),
+				SlashCommand{
+					Command:   testCase.command,
+					APIAppID:  "control-app",
+					ChannelID: "cone-of-silence",
+					UserID:    "86",
+				},
+			)
+			require.NoError(t, err)
+			msg := struct {
+				ResponseType string `json:"response_type"`
+			}{}
+			require.NoError(t, json.Unmarshal(response, &msg))
+			require.Equal(t, testCase.expectedResponseType, msg.ResponseType)
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleWithBuiltinSubcommands(t *testing.T) {
+	testConfig := SlashCommandServiceConfig{
+		SlackApps: slack.NewApps(map[string]slack.App{
+			"control-app": {
+				AppID: "control-app",
+				Commands: []slack.Command{
+					{
+						Command:            "/brigade",
+						BuiltinSubcommands: true,
+					},
+				},
+			},
+		}),
+	}
+	testCases := []struct {
+		name          string
+		command       string
+		expectCreated bool
+		expectListed  bool
+	}{
+		{
+			name:         "built-in subcommands enabled",
+			command:      "/brigade",
+			expectListed: true,
+		},
+		{
+			name:          "built-in subcommands not enabled",
+			command:       "/deploy",
+			expectCreated: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			created := false
+			listed := false
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						created = true
+						return sdk.EventList{}, nil
+					},
+					ListFn: func(
+						context.Context,
+						*sdk.EventsSelector,
+						*meta.ListOptions,
+					) (sdk.EventList, error) {
+						listed = true
+						return sdk.EventList{}, nil
+					},
+				},
+				&slackTesting.MockAPIClient{},
+				testConfig,
+			)
+			require.NoError(t, err)
+			_, err = service.Handle(
+				context.Background(),
+				SlashCommand{
+					Command:   testCase.command,
+					APIAppID:  "control-app",
+					ChannelID: "cone-of-silence",
+					Text:      "list",
+				},
+			)
+			require.NoError(t, err)
+			require.Equal(t, testCase.expectCreated, created)
+			require.Equal(t, testCase.expectListed, listed)
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleWithSuggestions(t *testing.T) {
+	testCases := []struct {
+		name           string
+		command        string
+		projectsClient sdk.ProjectsClient
+		assertions     func(response string)
+	}{
+		{
+			name:    "error listing projects",
+			command: "/deplyo",
+			projectsClient: &sdkTesting.MockProjectsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.ProjectsSelector,
+					*meta.ListOptions,
+				) (sdk.ProjectList, error) {
+					return sdk.ProjectList{}, errors.New("something went wrong")
+				},
+			},
+			assertions: func(response string) {
+				require.Contains(t, response, "No projects subscribed to this event.")
+				require.NotContains(t, response, "Did you mean")
+			},
+		},
+		{
+			name:    "similar event type",
+			command: "/deplyo",
+			projectsClient: &sdkTesting.MockProjectsClient{
+				ListFn: func(
+					context.Context,
+					*sdk.ProjectsSelector,
+					*meta.ListOptions,
+				) (sdk.ProjectList, error) {
+					project := testProject("italian", "control-app")
+					project.Spec.EventSubscriptions[0].Types = []string{"deploy"}
+					return sdk.ProjectList{Items: []sdk.Project{project}}, nil
+				},
+			},
+			assertions: func(response string) {
+				require.Contains(t, response, "Did you mean deploy?")
+				require.NotContains(t, response, "Try")
+			},
+		},
+		{
+			name:           "built-in subcommands enabled",
+			command:        "/brigade",
+			projectsClient: emptyProjectsClient(),
+			assertions: func(response string) {
+				require.NotContains(t, response, "Did you mean")
+				require.Contains(t, response, "Try /brigade help")
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			service, err := NewSlashCommandService(
+				testCase.projectsClient,
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						return sdk.EventList{}, nil
+					},
+				},
+				&slackTesting.MockAPIClient{},
+				SlashCommandServiceConfig{
+					SlackApps: slack.NewApps(map[string]slack.App{
+						"control-app": {
+							AppID: "control-app",
+							Commands: []slack.Command{
+								{
+									Command:            "/brigade",
+									BuiltinSubcommands: true,
+								},
+							},
+						},
+					}),
+				},
+			)
+			require.NoError(t, err)
+			response, err := service.Handle(
+				context.Background(),
+				SlashCommand{
+					Command:   testCase.command,
+					APIAppID:  "control-app",
+					ChannelID: "cone-of-silence",
+				},
+			)
+			require.NoError(t, err)
+			require.True(t, json.Valid(response), string(response))
+			testCase.assertions(string(response))
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleWithPayload(t *testing.T) {
+	var createdEvent sdk.Event
+	service, err := NewSlashCommandService(
+		emptyProjectsClient(),
+		&sdkTesting.MockEventsClient{
+			CreateFn: func(
+				_ context.Context,
+				event sdk.Event,
+				_ *sdk.EventCreateOptions,
+			) (sdk.EventList, error) {
+				createdEvent = event
+				return sdk.EventList{}, nil
+			},
+		},
+		&slackTesting.MockAPIClient{},
+		SlashCommandServiceConfig{
+			SlackApps: slack.NewApps(map[string]slack.App{
+				"control-app": {
+					AppID: "control-app",
+					Payload: slack.Payload{
+						Format: slack.PayloadFormatJSON,
+						Labels: map[string]string{
+							"environment": "{{ index .Args 0 }}",
+						},
+					},
+					Commands: []slack.Command{
+						{
+							Command: "/deploy",
+							Subcommands: []slack.Subcommand{
+								{Name: "app"},
+							},
+						},
+					},
+				},
+			}),
+		},
+	)
+	require.NoError(t, err)
+	_, err = service.Handle(
+		context.Background(),
+		SlashCommand{
+			Command:     "/deploy",
+			APIAppID:    "control-app",
+			ChannelID:   "cone-of-silence",
+			ChannelName: "cone-of-silence",
+			Text:        "app prod v1.2.3",
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "prod", createdEvent.Labels["environment"])
+	require.Equal(t, "cone-of-silence", createdEvent.Labels["channelID"])
+	payload := commandPayload{}
+	require.NoError(t, json.Unmarshal([]byte(createdEvent.Payload), &payload))
+	require.Equal(t, payloadVersion, payload.Version)
+	require.Equal(t, "deploy.app", payload.EventType)
+	require.Equal(t, "prod v1.2.3", payload.Text)
+	require.Equal(t, []string{"prod", "v1.2.3"}, payload.Args)
+	require.Equal(t, "cone-of-silence", payload.Command.ChannelName)
+}
+
+func TestSlashCommandServiceHandleWithArguments(t *testing.T) {
+	testApps := map[string]slack.App{
+		"control-app": {
+			AppID: "control-app",
+			Commands: []slack.Command{
+				{
+					Command: "/deploy",
+					Payload: slack.Payload{
+						ShortTitle: "deploy {{ .Values.version }}",
+						LongTitle: "deploy {{ .Values.version }} to " +
+							"{{ .Values.env }}",
+					},
+					Arguments: []slack.Argument{
+						{
+							Name:     "env",
+							Type:     slack.ArgumentTypeEnum,
+							Options:  []string{"dev", "prod"},
+							Required: true,
+							Label:    "environment",
+						},
+						{
+							Name:       "version",
+							Type:       slack.ArgumentTypeSemver,
+							Positional: true,
+							Required:   true,
+						},
+						{
+							Name: "label",
+							Type: slack.ArgumentTypeLabel,
+						},
+						{
+							Name: "notify",
+							Type: slack.ArgumentTypeUser,
+						},
+					},
+				},
+			},
+		},
+	}
+	testCommand := SlashCommand{
+		Command:   "/deploy",
+		APIAppID:  "control-app",
+		ChannelID: "cone-of-silence",
+	}
+	testCases := []struct {
+		name       string
+		text       string
+		assertions func(createdEvent *sdk.Event, response []byte, err error)
+	}{
+		{
+			name: "invalid arguments",
+			text: "1.2.3 --env qa",
+			assertions: func(createdEvent *sdk.Event, response []byte, err error) {
+				require.NoError(t, err)
+				require.Nil(t, createdEvent)
+				msg := struct {
+					ResponseType string `json:"response_type"`
+					Text         string `json:"text"`
+				}{}
+				require.NoError(t, json.Unmarshal(response, &msg))
+				require.Equal(t, responseTypeEphemeral, msg.ResponseType)
+				require.Contains(t, msg.Text, `invalid value "qa" for --env`)
+				require.Contains(
+					t,
+					msg.Text,
+					"Usage: /deploy --env <dev|prod> <version>",
+				)
+			},
+		},
+		{
+			name: "reserved label",
+			text: "v1.2.3 --env prod --label enterpriseID=E123",
+			assertions: func(createdEvent *sdk.Event, response []byte, err error) {
+				require.NoError(t, err)
+				require.Nil(t, createdEvent)
+				require.Contains(t, string(response), responseTypeEphemeral)
+				require.Contains(
+					t,
+					string(response),
+					`label \"enterpriseID\" is reserved`,
+				)
+			},
+		},
+		{
+			name: "valid arguments",
+			text: "v1.2.3 --env prod --label team=ops --label tier=web " +
+				"--notify <@U123|alice>",
+			assertions: func(createdEvent *sdk.Event, _ []byte, err error) {
+				require.NoError(t, err)
+				require.NotNil(t, createdEvent)
+				require.Equal(t, "prod", createdEvent.Labels["environment"])
+				require.Equal(t, "ops", createdEvent.Labels["team"])
+				require.Equal(t, "web", createdEvent.Labels["tier"])
+				require.Equal(
+					t,
+					"cone-of-silence",
+					createdEvent.Labels["channelID"],
+				)
+				require.Equal(t, "deploy v1.2.3", createdEvent.ShortTitle)
+				require.Equal(t, "deploy v1.2.3 to prod", createdEvent.LongTitle)
+				require.JSONEq(
+					t,
+					`{
+						"env": "prod",
+						"version": "v1.2.3",
+						"label": {"team": "ops", "tier": "web"},
+						"notify": "U123"
+					}`,
+					createdEvent.Payload,
+				)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var createdEvent *sdk.Event
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						createdEvent = &event
+						return sdk.EventList{}, nil
+					},
+				},
+				&slackTesting.MockAPIClient{},
+				SlashCommandServiceConfig{
+					SlackApps: slack.NewApps(testApps),
+				},
+			)
+			require.NoError(t, err)
+			command := testCommand
+			command.Text = testCase.text
+			response, err := service.Handle(context.Background(), command)
+			testCase.assertions(createdEvent, response, err)
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleWithEnrichment(t *testing.T) {
+	testCases := []struct {
+		name       string
+		apiClient  slack.APIClient
+		assertions func(createdEvent sdk.Event, err error)
+	}{
+		{
+			name: "enrichment fails",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					context.Context,
+					string,
+					string,
+					interface{},
+					interface{},
+				) error {
+					return errors.New("something went wrong")
+				},
+			},
+			assertions: func(createdEvent sdk.Event, err error) {
+				// The event should have been created anyway
+				require.NoError(t, err)
+				require.Equal(t, "deploy", createdEvent.Type)
+				require.NotContains(t, createdEvent.Labels, "userName")
+				require.NotContains(t, createdEvent.Labels, "channelName")
+			},
+		},
+		{
+			name: "enrichment succeeds",
+			apiClient: &slackTesting.MockAPIClient{
+				CallFn: func(
+					_ context.Context,
+					_ string,
+					method string,
+					_ interface{},
+					result interface{},
+				) error {
+					if method == "users.info" {
+						return json.Unmarshal(
+							[]byte(`{"user":{"name":"max","tz":"America/New_York"}}`),
+							result,
+						)
+					}
+					return json.Unmarshal(
+						[]byte(`{"channel":{"name":"control"}}`),
+						result,
+					)
+				},
+			},
+			assertions: func(createdEvent sdk.Event, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "max", createdEvent.Labels["userName"])
+				require.Equal(t, "control", createdEvent.Labels["channelName"])
+				payload := commandPayload{}
+				require.NoError(
+					t,
+					json.Unmarshal([]byte(createdEvent.Payload), &payload),
+				)
+				require.NotNil(t, payload.User)
+				require.Equal(t, "America/New_York", payload.User.TimeZone)
+				require.NotNil(t, payload.Channel)
+				require.Equal(t, "control", payload.Channel.Name)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var createdEvent sdk.Event
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						createdEvent = event
+						return sdk.EventList{}, nil
+					},
+				},
+				testCase.apiClient,
+				SlashCommandServiceConfig{
+					SlackApps: slack.NewApps(map[string]slack.App{
+						"control-app": {
+							AppID:        "control-app",
+							APIToken:     "foo",
+							EnrichEvents: true,
+							Payload: slack.Payload{
+								Format: slack.PayloadFormatJSON,
+							},
+						},
+					}),
+				},
+			)
+			require.NoError(t, err)
+			_, err = service.Handle(
+				context.Background(),
+				SlashCommand{
+					Command:   "/deploy",
+					APIAppID:  "control-app",
+					ChannelID: "cone-of-silence",
+					UserID:    "86",
+				},
+			)
+			testCase.assertions(createdEvent, err)
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleWithTokenStore(t *testing.T) {
+	testCases := []struct {
+		name                string
+		tokenStore          slack.TokenStore
+		isEnterpriseInstall bool
+		expectedToken       string
+	}{
+		{
+			name: "error getting installation",
+			tokenStore: &slackTesting.MockTokenStore{
+				GetFn: func(
+					context.Context,
+					string,
+					string,
+					string,
+				) (slack.Installation, bool, error) {
+					return slack.Installation{}, false,
+						errors.New("something went wrong")
+				},
+			},
+			// The App's configured token should be used
+			expectedToken: "foo",
+		},
+		{
+			name: "not installed into team",
+			tokenStore: &slackTesting.MockTokenStore{
+				GetFn: func(
+					context.Context,
+					string,
+					string,
+					string,
+				) (slack.Installation, bool, error) {
+					return slack.Installation{}, false, nil
+				},
+			},
+			expectedToken: "foo",
+		},
+		{
+			name: "installed into team",
+			tokenStore: &slackTesting.MockTokenStore{
+				GetFn: func(
+					_ context.Context,
+					appID string,
+					_ string,
+					teamID string,
+				) (slack.Installation, bool, error) {
+					require.Equal(t, "control-app", appID)
+					require.Equal(t, "control", teamID)
+					return slack.Installation{
+						AppID:    appID,
+						TeamID:   teamID,
+						BotToken: "xoxb-control",
+					}, true, nil
+				},
+			},
+			expectedToken: "xoxb-control",
+		},
+		{
+			name: "received via org-wide installation",
+			tokenStore: &slackTesting.MockTokenStore{
+				GetFn: func(
+					_ context.Context,
+					_ string,
+					enterpriseID string,
+					teamID string,
+				) (slack.Installation, bool, error) {
+					// The org-wide installation should be looked up directly
+					require.Equal(t, "net", enterpriseID)
+					require.Empty(t, teamID)
+					return slack.Installation{
+						EnterpriseID: enterpriseID,
+						BotToken:     "xoxb-net",
+					}, true, nil
+				},
+			},
+			isEnterpriseInstall: true,
+			expectedToken:       "xoxb-net",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			tokens := []string{}
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						return sdk.EventList{}, nil
+					},
+				},
+				&slackTesting.MockAPIClient{
+					CallFn: func(
+						_ context.Context,
+						token string,
+						_ string,
+						_ interface{},
+						_ interface{},
+					) error {
+						tokens = append(tokens, token)
+						return nil
+					},
+				},
+				SlashCommandServiceConfig{
+					SlackApps: slack.NewApps(map[string]slack.App{
+						"control-app": {
+							AppID:        "control-app",
+							APIToken:     "foo",
+							EnrichEvents: true,
+						},
+					}),
+					TokenStore: testCase.tokenStore,
+				},
+			)
+			require.NoError(t, err)
+			_, err = service.Handle(
+				context.Background(),
+				SlashCommand{
+					Command:             "/deploy",
+					APIAppID:            "control-app",
+					EnterpriseID:        "net",
+					TeamID:              "control",
+					ChannelID:           "cone-of-silence",
+					UserID:              "86",
+					IsEnterpriseInstall: testCase.isEnterpriseInstall,
+				},
+			)
+			require.NoError(t, err)
+			// Enrichment should have used the expected token for both calls
+			require.Equal(
+				t,
+				[]string{testCase.expectedToken, testCase.expectedToken},
+				tokens,
+			)
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleWithConfirmation(t *testing.T) {
+	testCommand := SlashCommand{
+		Command:   "/deploy",
+		APIAppID:  "control-app",
+		ChannelID: "cone-of-silence",
+		UserID:    "86",
+		Text:      "prod v1.2.3",
+	}
+	testCases := []struct {
+		name         string
+		command      SlashCommand
+		confirmation func(id string) Confirmation
+		assertions   func(created bool, responses []string, err error)
+	}{
+		{
+			name:    "subcommand does not require confirmation",
+			command: SlashCommand{Text: "staging v1.2.3"},
+			assertions: func(created bool, _ []string, err error) {
+				require.NoError(t, err)
+				require.True(t, created)
+			},
+		},
+		{
+			name: "unknown confirmation",
+			confirmation: func(string) Confirmation {
+				return Confirmation{ID: "bogus", UserID: "86"}
+			},
+			assertions: func(created bool, responses []string, err error) {
+				require.NoError(t, err)
+				require.False(t, created)
+				require.Len(t, responses, 1)
+				require.Contains(t, responses[0], "This request has expired")
+			},
+		},
+		{
+			name: "confirmed by another user",
+			confirmation: func(id string) Confirmation {
+				return Confirmation{ID: id, UserID: "99", Confirmed: true}
+			},
+			assertions: func(created bool, responses []string, err error) {
+				require.NoError(t, err)
+				require.False(t, created)
+				require.Len(t, responses, 1)
+				require.Contains(t, responses[0], "only the user who invoked")
+			},
+		},
+		{
+			name: "cancelled",
+			confirmation: func(id string) Confirmation {
+				return Confirmation{ID: id, UserID: "86"}
+			},
+			assertions: func(created bool, responses []string, err error) {
+				require.NoError(t, err)
+				require.False(t, created)
+				require.Len(t, responses, 1)
+				require.Contains(t, responses[0], "Cancelled")
+			},
+		},
+		{
+			name: "confirmed",
+			confirmation: func(id string) Confirmation {
+				return Confirmation{ID: id, UserID: "86", Confirmed: true}
+			},
+			assertions: func(created bool, responses []string, err error) {
+				require.NoError(t, err)
+				require.True(t, created)
+				require.Len(t, responses, 2)
+				require.Contains(t, responses[0], "Confirmed")
+				require.Contains(t, responses[1], "No Events Created")
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			created := false
+			responses := []string{}
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						require.Equal(t, "cone-of-silence", event.Labels["channelID"])
+						created = true
+						return sdk.EventList{}, nil
+					},
+				},
+				&slackTesting.MockAPIClient{
+					RespondFn: func(_ context.Context, _ string, msg []byte) error {
+						responses = append(responses, string(msg))
+						return nil
+					},
+				},
+				SlashCommandServiceConfig{
+					SlackApps: slack.NewApps(map[string]slack.App{
+						"control-app": {
+							AppID: "control-app",
+							Commands: []slack.Command{
+								{
+									Command: "/deploy",
+									Subcommands: []slack.Subcommand{
+										{Name: "staging"},
+										{
+											Name:                "prod",
+											RequireConfirmation: true,
+										},
+									},
+								},
+							},
+						},
+					}),
+				},
+			)
+			require.NoError(t, err)
+			command := testCommand
+			if testCase.command.Text != "" {
+				command.Text = testCase.command.Text
+			}
+			response, err := service.Handle(context.Background(), command)
+			require.NoError(t, err)
+			if testCase.confirmation == nil {
+				testCase.assertions(created, responses, err)
+				return
+			}
+			// Nothing should have been emitted yet
+			require.False(t, created)
+			require.Contains(t, string(response), "Please confirm")
+			pending := service.(*slashCommandService).confirmations.pending.entries
+			require.Len(t, pending, 1)
+			var id string
+			for id = range pending {
+				break
+			}
+			err = service.HandleConfirmation(
+				context.Background(),
+				testCase.confirmation(id),
+			)
+			testCase.assertions(created, responses, err)
+		})
+	}
+}
+
+func TestNewSlashCommandServiceWithInvalidPayloadTemplate(t *testing.T) {
+	_, err := NewSlashCommandService(
+		nil,
+		nil,
+		nil,
+		SlashCommandServiceConfig{
+			SlackApps: slack.NewApps(map[string]slack.App{
+				"control-app": {
+					AppID: "control-app",
+					Payload: slack.Payload{
+						Template: "{{ .Text",
+					},
+				},
+			}),
+		},
+	)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error parsing payload template")
+}
+
+func TestSlashCommandServiceHandleWithPolicy(t *testing.T) {
+	testConfig := SlashCommandServiceConfig{
+		SlackApps: slack.NewApps(map[string]slack.App{
+			"control-app": {
+				AppID: "control-app",
+				Policy: slack.Policy{
+					Allow: &slack.PolicyRule{
+						TeamIDs: []string{"control"},
+					},
+				},
+				Enterprises: []slack.Enterprise{
+					{
+						EnterpriseID: "net",
+						Policy: slack.Policy{
+							Deny: &slack.PolicyRule{
+								ChannelIDs: []string{"lobby"},
+							},
+						},
+					},
+				},
+				Commands: []slack.Command{
+					{
+						Command: "/deploy",
+						Policy: slack.Policy{
+							Allow: &slack.PolicyRule{
+								UserIDs: []string{"86"},
+							},
+						},
+					},
+				},
+			},
+		}),
+	}
+	testCases := []struct {
+		name       string
+		command    SlashCommand
+		assertions func(response []byte, err error, eventsCreated bool)
+	}{
+		{
+			name: "denied by app policy",
+			command: SlashCommand{
+				Command:  "/status",
+				APIAppID: "control-app",
+				TeamID:   "kaos",
+				UserID:   "86",
+			},
+			assertions: func(response []byte, err error, eventsCreated bool) {
+				require.NoError(t, err)
+				require.False(t, eventsCreated)
+				require.Contains(t, string(response), responseTypeEphemeral)
+				require.Contains(t, string(response), "not permitted to use /status")
+			},
+		},
+		{
+			name: "denied by enterprise policy",
+			command: SlashCommand{
+				Command:      "/deploy",
+				APIAppID:     "control-app",
+				EnterpriseID: "net",
+				TeamID:       "control",
+				ChannelID:    "lobby",
+				UserID:       "86",
+			},
+			assertions: func(response []byte, err error, eventsCreated bool) {
+				require.NoError(t, err)
+				require.False(t, eventsCreated)
+				require.Contains(t, string(response), "not permitted to use /deploy")
+			},
+		},
+		{
+			name: "enterprise policy does not apply to other enterprises",
+			command: SlashCommand{
+				Command:      "/deploy",
+				APIAppID:     "control-app",
+				EnterpriseID: "kaos",
+				TeamID:       "control",
+				ChannelID:    "lobby",
+				UserID:       "86",
+			},
+			assertions: func(response []byte, err error, eventsCreated bool) {
+				require.NoError(t, err)
+				require.True(t, eventsCreated)
+			},
+		},
+		{
+			name: "denied by command policy",
+			command: SlashCommand{
+				Command:  "/deploy",
+				APIAppID: "control-app",
+				TeamID:   "control",
+				UserID:   "99",
+			},
+			assertions: func(response []byte, err error, eventsCreated bool) {
+				require.NoError(t, err)
+				require.False(t, eventsCreated)
+				require.Contains(t, string(response), "not permitted to use /deploy")
+			},
+		},
+		{
+			name: "allowed",
+			command: SlashCommand{
+				Command:  "/deploy",
+				APIAppID: "control-app",
+				TeamID:   "control",
+				UserID:   "86",
+			},
+			assertions: func(response []byte, err error, eventsCreated bool) {
+				require.NoError(t, err)
+				require.True(t, eventsCreated)
+				require.Contains(t, string(response), "No Events Created")
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			eventsCreated := false
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						context.Context,
+						sdk.Event,
+						*sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						eventsCreated = true
+						return sdk.EventList{}, nil
+					},
+				},
+				&slackTesting.MockAPIClient{},
+				testConfig,
+			)
+			require.NoError(t, err)
+			ctx, recorder := withOutcomeRecorder(context.Background())
+			response, err := service.Handle(ctx, testCase.command)
+			testCase.assertions(response, err, eventsCreated)
+			// Denials should be recorded as such
+			if !eventsCreated {
+				require.Equal(t, outcomeDenied, recorder.outcome)
+			}
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleWithIdentities(t *testing.T) {
+	testConfig := SlashCommandServiceConfig{
+		SlackApps: slack.NewApps(map[string]slack.App{
+			"control-app": {
+				AppID: "control-app",
+				Identities: &slack.IdentityMapping{
+					Users: []slack.UserMapping{
+						{
+							SlackUserID:   "86",
+							BrigadeUserID: "max",
+						},
+						{
+							SlackUserID:   "99",
+							BrigadeUserID: "agent99",
+						},
+					},
+				},
+			},
+		}),
+	}
+	testProjectsClient := &sdkTesting.MockProjectsClient{
+		ListFn: func(
+			context.Context,
+			*sdk.ProjectsSelector,
+			*meta.ListOptions,
+		) (sdk.ProjectList, error) {
+			return sdk.ProjectList{
+				Items: []sdk.Project{testProject("italian", "control-app")},
+			}, nil
+		},
+		AuthzClient: &sdkTesting.MockProjectAuthzClient{
+			RoleAssignmentsClient: &sdkTesting.MockProjectRoleAssignmentsClient{
+				ListFn: func(
+					_ context.Context,
+					selector *sdk.ProjectRoleAssignmentsSelector,
+					_ *meta.ListOptions,
+				) (sdk.ProjectRoleAssignmentList, error) {
+					list := sdk.ProjectRoleAssignmentList{}
+					if selector.Principal.ID == "max" {
+						list.Items = []sdk.ProjectRoleAssignment{{}}
+					}
+					return list, nil
+				},
+			},
+		},
+	}
+	testCases := []struct {
+		name       string
+		userID     string
+		assertions func(response []byte, err error, event *sdk.Event)
+	}{
+		{
+			name:   "unmapped user",
+			userID: "13",
+			assertions: func(response []byte, err error, event *sdk.Event) {
+				require.NoError(t, err)
+				require.Nil(t, event)
+				require.Contains(t, string(response), responseTypeEphemeral)
+				require.Contains(t, string(response), "not associated")
+			},
+		},
+		{
+			name:   "user lacks required role",
+			userID: "99",
+			assertions: func(response []byte, err error, event *sdk.Event) {
+				require.NoError(t, err)
+				require.Nil(t, event)
+				require.Contains(t, string(response), "agent99 lacks the role")
+				require.Contains(t, string(response), "italian")
+			},
+		},
+		{
+			name:   "user holds required role",
+			userID: "86",
+			assertions: func(response []byte, err error, event *sdk.Event) {
+				require.NoError(t, err)
+				require.NotNil(t, event)
+				require.Equal(t, "max", event.Labels["brigadeUserID"])
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var createdEvent *sdk.Event
+			service, err := NewSlashCommandService(
+				testProjectsClient,
+				&sdkTesting.MockEventsClient{
+					CreateFn: func(
+						_ context.Context,
+						event sdk.Event,
+						_ *sdk.EventCreateOptions,
+					) (sdk.EventList, error) {
+						createdEvent = &event
+						return sdk.EventList{}, nil
+					},
+				},
+				&slackTesting.MockAPIClient{},
+				testConfig,
+			)
+			require.NoError(t, err)
+			response, err := service.Handle(
+				context.Background(),
+				SlashCommand{
+					Command:   "/deploy",
+					APIAppID:  "control-app",
+					ChannelID: "cone-of-silence",
+					UserID:    testCase.userID,
+				},
+			)
+			testCase.assertions(response, err, createdEvent)
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleAsync(t *testing.T) {
+	testCommand := SlashCommand{
+		Command:     "/deploy",
+		APIAppID:    "control-app",
+		ChannelID:   "cone-of-silence",
+		Text:        "prod",
+		ResponseURL: "https://hooks.slack.com/commands/1234/5678",
+	}
+	testCases := []struct {
+		name         string
+		command      func() SlashCommand
+		eventsClient sdk.EventsClient
+		assertions   func(response []byte, err error, responses []string)
+	}{
+		{
+			name: "no response URL",
+			command: func() SlashCommand {
+				command := testCommand
+				command.ResponseURL = ""
+				return command
+			},
+			eventsClient: &sdkTesting.MockEventsClient{
+				CreateFn: func(
+					context.Context,
+					sdk.Event,
+					*sdk.EventCreateOptions,
+				) (sdk.EventList, error) {
+					return sdk.EventList{}, nil
+				},
+			},
+			assertions: func(response []byte, err error, responses []string) {
+				// Should have been handled synchronously
+				require.NoError(t, err)
+				require.Contains(t, string(response), "No Events Created")
+				require.Empty(t, responses)
+			},
+		},
+		{
+			name: "error creating brigade event",
+			command: func() SlashCommand {
+				return testCommand
+			},
+			eventsClient: &sdkTesting.MockEventsClient{
+				CreateFn: func(
+					context.Context,
+					sdk.Event,
+					*sdk.EventCreateOptions,
+				) (sdk.EventList, error) {
+					return sdk.EventList{}, errors.New("something went wrong")
+				},
+			},
+			assertions: func(response []byte, err error, responses []string) {
+				require.NoError(t, err)
+				require.Contains(t, string(response), asyncAckMsg)
+				require.Contains(t, string(response), responseTypeEphemeral)
+				require.Len(t, responses, 1)
+				require.Contains(t, responses[0], asyncErrorMsg)
+				require.NotContains(t, responses[0], "No Events Created")
+			},
+		},
+		{
+			name: "success",
+			command: func() SlashCommand {
+				return testCommand
+			},
+			eventsClient: &sdkTesting.MockEventsClient{
+				CreateFn: func(
+					_ context.Context,
+					event sdk.Event,
+					_ *sdk.EventCreateOptions,
+				) (sdk.EventList, error) {
+					require.Equal(t, "deploy", event.Type)
+					require.Equal(t, "prod", event.Payload)
+					return sdk.EventList{}, nil
+				},
+			},
+			assertions: func(response []byte, err error, responses []string) {
+				require.NoError(t, err)
+				require.Contains(t, string(response), asyncAckMsg)
+				require.Len(t, responses, 1)
+				require.Contains(t, responses[0], "No Events Created")
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			responses := []string{}
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				testCase.eventsClient,
+				&slackTesting.MockAPIClient{
+					RespondFn: func(
+						_ context.Context,
+						responseURL string,
+						message []byte,
+					) error {
+						require.Equal(t, testCommand.ResponseURL, responseURL)
+						responses = append(responses, string(message))
+						return nil
+					},
+				},
+				SlashCommandServiceConfig{
+					AsyncAck: true,
+				},
+			)
+			require.NoError(t, err)
+			// Run "background" work synchronously so it's complete before
+			// assertions are made
+			service.(*slashCommandService).goFn = func(fn func()) {
+				fn()
+			}
+			response, err :=
+				service.Handle(context.Background(), testCase.command())
+			testCase.assertions(response, err, responses)
+		})
+	}
+}
+
+func TestSlashCommandServiceHandleSubmission(t *testing.T) {
+	testCommand := SlashCommand{
+		Command:     "/deploy",
+		APIAppID:    "control-app",
+		ChannelID:   "cone-of-silence",
+		ResponseURL: "https://hooks.slack.com/commands/1234/5678",
+	}
+	testValues := map[string]interface{}{
+		"environment": "prod",
+		"dryRun":      false,
+	}
+	testCases := []struct {
+		name         string
+		config       SlashCommandServiceConfig
+		eventsClient sdk.EventsClient
+		apiClient    slack.APIClient
+		assertions   func(error)
+	}{
+		{
+			name: "error creating brigade event",
+			eventsClient: &sdkTesting.MockEventsClient{
+				CreateFn: func(
+					context.Context,
+					sdk.Event,
+					*sdk.EventCreateOptions,
+				) (sdk.EventList, error) {
+					return sdk.EventList{}, errors.New("something went wrong")
+				},
+			},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error emitting event(s) into Brigade")
+			},
+		},
+		{
+			name: "error responding",
+			eventsClient: &sdkTesting.MockEventsClient{
+				CreateFn: func(
+					context.Context,
+					sdk.Event,
+					*sdk.EventCreateOptions,
+				) (sdk.EventList, error) {
+					return sdk.EventList{}, nil
+				},
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				RespondFn: func(context.Context, string, []byte) error {
+					return errors.New("something went wrong")
+				},
+			},
+			assertions: func(err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name: "success",
+			eventsClient: &sdkTesting.MockEventsClient{
+				CreateFn: func(
+					_ context.Context,
+					event sdk.Event,
+					_ *sdk.EventCreateOptions,
+				) (sdk.EventList, error) {
+					require.Equal(t, "deploy", event.Type)
+					require.JSONEq(
+						t,
+						`{"environment":"prod","dryRun":false}`,
+						event.Payload,
+					)
+					return sdk.EventList{}, nil
+				},
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				RespondFn: func(
+					_ context.Context,
+					responseURL string,
+					message []byte,
+				) error {
+					require.Equal(t, testCommand.ResponseURL, responseURL)
+					require.Contains(t, string(message), "No Events Created")
+					return nil
+				},
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "denied by policy",
+			config: SlashCommandServiceConfig{
+				SlackApps: slack.NewApps(map[string]slack.App{
+					testCommand.APIAppID: {
+						Policy: slack.Policy{
+							Deny: &slack.PolicyRule{
+								ChannelIDs: []string{testCommand.ChannelID},
+							},
+						},
+					},
+				}),
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				RespondFn: func(
+					_ context.Context,
+					responseURL string,
+					message []byte,
+				) error {
+					require.Equal(t, testCommand.ResponseURL, responseURL)
+					require.Contains(t, string(message), "not permitted to use /deploy")
+					return nil
+				},
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "asynchronous error is reported to response URL",
+			config: SlashCommandServiceConfig{
+				AsyncAck: true,
+			},
+			eventsClient: &sdkTesting.MockEventsClient{
+				CreateFn: func(
+					context.Context,
+					sdk.Event,
+					*sdk.EventCreateOptions,
+				) (sdk.EventList, error) {
+					return sdk.EventList{}, errors.New("something went wrong")
+				},
+			},
+			apiClient: &slackTesting.MockAPIClient{
+				RespondFn: func(
+					_ context.Context,
+					responseURL string,
+					message []byte,
+				) error {
+					require.Equal(t, testCommand.ResponseURL, responseURL)
+					require.Contains(t, string(message), asyncErrorMsg)
+					return nil
+				},
+			},
+			assertions: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			service, err := NewSlashCommandService(
+				emptyProjectsClient(),
+				testCase.eventsClient,
+				testCase.apiClient,
+				testCase.config,
+			)
+			require.NoError(t, err)
+			service.(*slashCommandService).goFn = func(fn func()) {
+				fn()
+			}
+			testCase.assertions(
+				service.HandleSubmission(
+					context.Background(),
+					testCommand,
+					testValues,
+				),
+			)
+		})
+	}
+}
+
+// emptyProjectsClient returns a projects client that lists no projects.
+func emptyProjectsClient() sdk.ProjectsClient {
+	return &sdkTesting.MockProjectsClient{
+		ListFn: func(
+			context.Context,
+			*sdk.ProjectsSelector,
+			*meta.ListOptions,
+		) (sdk.ProjectList, error) {
+			return sdk.ProjectList{}, nil
+		},
+	}
+}
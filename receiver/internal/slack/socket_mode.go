@@ -0,0 +1,357 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// socketModeEnvelope encapsulates details of a message received over a Socket
+// Mode connection. Depending on its Type, an envelope either carries a request
+// from Slack, which must be acknowledged, or information about the connection
+// itself.
+//
+// nolint: lll
+type socketModeEnvelope struct {
+	EnvelopeID             string          `json:"envelope_id"`              // e.g. dbdd0ef3-1543-4f94-bfb4-133d0e6c1545
+	Type                   string          `json:"type"`                     // e.g. slash_commands
+	Payload                json.RawMessage `json:"payload"`                  // requests only
+	AcceptsResponsePayload bool            `json:"accepts_response_payload"` // requests only
+	RetryAttempt           int             `json:"retry_attempt"`            // events_api only; e.g. 1
+	RetryReason            string          `json:"retry_reason"`             // events_api only; e.g. timeout
+	Reason                 string          `json:"reason"`                   // disconnect only; e.g. refresh_requested
+}
+
+// socketModeAck is sent over a Socket Mode connection to acknowledge receipt
+// of a request. Where the request permits it, the acknowledgement carries the
+// same response that would otherwise have been returned over HTTP.
+type socketModeAck struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+const (
+	socketModeTypeHello         = "hello"
+	socketModeTypeDisconnect    = "disconnect"
+	socketModeTypeSlashCommands = "slash_commands"
+	socketModeTypeInteractive   = "interactive"
+	socketModeTypeEventsAPI     = "events_api"
+)
+
+// SocketModeClientConfig encapsulates configuration for the Socket Mode
+// client.
+type SocketModeClientConfig struct {
+	// SlackApps is the set of Slack App configurations, which may be reloaded. A
+	// Socket Mode connection is maintained for each App that has an app-level
+	// token.
+	SlackApps *slack.Apps
+	// ReconnectInterval specifies how long to wait before opening a new
+	// connection after a connection could not be opened or was lost
+	// unexpectedly. If not specified, a default of five seconds is used.
+	ReconnectInterval time.Duration
+}
+
+// SocketModeClient is an interface for components that receive requests from
+// Slack over Socket Mode connections, which this gateway opens to Slack,
+// instead of over HTTP. This permits the gateway to run without a publicly
+// accessible endpoint.
+type SocketModeClient interface {
+	// Run maintains a Socket Mode connection for each applicable Slack App and
+	// handles the requests received over them until the provided context is
+	// canceled. Connections are opened and closed as Apps are added, removed, or
+	// reconfigured.
+	Run(context.Context) error
+}
+
+type socketModeClient struct {
+	config              SocketModeClientConfig
+	apiClient           slack.APIClient
+	slashCommandService SlashCommandService
+	interactionService  InteractionService
+	optionsService      InteractionService
+	eventsAPIService    EventsAPIService
+	dialer              *websocket.Dialer
+	// syncInterval is how often the App configurations are checked for changes
+	// that require connections to be opened or closed.
+	syncInterval time.Duration
+}
+
+// socketModeApp tracks the goroutine that maintains the Socket Mode connection
+// for a single App.
+type socketModeApp struct {
+	appToken string
+	cancel   context.CancelFunc
+	doneCh   chan struct{}
+}
+
+// NewSocketModeClient returns an implementation of the SocketModeClient
+// interface. Requests received over Socket Mode connections are delegated to
+// the same transport-agnostic services that handle requests received over
+// HTTP.
+func NewSocketModeClient(
+	apiClient slack.APIClient,
+	slashCommandService SlashCommandService,
+	interactionService InteractionService,
+	optionsService InteractionService,
+	eventsAPIService EventsAPIService,
+	config SocketModeClientConfig,
+) SocketModeClient {
+	if config.ReconnectInterval == 0 {
+		config.ReconnectInterval = 5 * time.Second
+	}
+	return &socketModeClient{
+		config:              config,
+		apiClient:           apiClient,
+		slashCommandService: slashCommandService,
+		interactionService:  interactionService,
+		optionsService:      optionsService,
+		eventsAPIService:    eventsAPIService,
+		dialer:              websocket.DefaultDialer,
+		syncInterval:        10 * time.Second,
+	}
+}
+
+func (s *socketModeClient) Run(ctx context.Context) error {
+	running := map[string]*socketModeApp{}
+	defer func() {
+		for _, app := range running {
+			app.cancel()
+			<-app.doneCh
+		}
+	}()
+	for {
+		s.syncApps(ctx, running)
+		select {
+		case <-time.After(s.syncInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// syncApps starts maintaining a connection for each App that has an app-level
+// token and isn't already connected, and stops maintaining the connection for
+// each App that has been removed or whose app-level token has changed. The
+// provided map of running connections, indexed by App ID, is updated
+// accordingly.
+func (s *socketModeClient) syncApps(
+	ctx context.Context,
+	running map[string]*socketModeApp,
+) {
+	apps := s.config.SlackApps.All()
+	for appID, r := range running {
+		if app, ok := apps[appID]; !ok || app.AppToken != r.appToken {
+			r.cancel()
+			<-r.doneCh
+			delete(running, appID)
+		}
+	}
+	for appID, app := range apps {
+		if _, ok := running[appID]; ok || app.AppToken == "" {
+			continue
+		}
+		appCtx, cancel := context.WithCancel(ctx)
+		r := &socketModeApp{
+			appToken: app.AppToken,
+			cancel:   cancel,
+			doneCh:   make(chan struct{}),
+		}
+		go func(app slack.App) {
+			defer close(r.doneCh)
+			s.runApp(appCtx, app)
+		}(app)
+		running[appID] = r
+	}
+}
+
+// runApp opens a Socket Mode connection for the provided App and handles the
+// requests received over it. Whenever the connection is closed, a new one is
+// opened in its place. This continues until the provided context is canceled.
+func (s *socketModeClient) runApp(ctx context.Context, app slack.App) {
+	for {
+		err := s.connect(ctx, app)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			// Slack asked us to reconnect, so we do so right away.
+			continue
+		}
+		log.Printf(
+			"error in socket mode connection for app %q; reconnecting in %s: %s",
+			app.AppID,
+			s.config.ReconnectInterval,
+			err,
+		)
+		select {
+		case <-time.After(s.config.ReconnectInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// connect opens a Socket Mode connection for the provided App and handles the
+// requests received over it until either Slack asks for the connection to be
+// replaced, in which case nil is returned, or the connection is lost, in which
+// case an error is returned.
+func (s *socketModeClient) connect(ctx context.Context, app slack.App) error {
+	result := struct {
+		URL string `json:"url"`
+	}{}
+	if err := s.apiClient.Call(
+		ctx,
+		app.AppToken,
+		"apps.connections.open",
+		url.Values{},
+		&result,
+	); err != nil {
+		return errors.Wrap(err, "error opening socket mode connection")
+	}
+	conn, _, err := s.dialer.DialContext(ctx, result.URL, nil)
+	if err != nil {
+		return errors.Wrap(err, "error dialing socket mode connection")
+	}
+	defer conn.Close()
+	// Closing the connection is the only way to interrupt a blocked read, so we
+	// do that if the context is canceled.
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-connCtx.Done()
+		conn.Close() // nolint: errcheck
+	}()
+	// Requests are handled concurrently, but only one goroutine at a time may
+	// write to the connection. In-flight requests are given the chance to be
+	// acknowledged before the connection is closed.
+	writeMu := &sync.Mutex{}
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return errors.Wrap(err, "error reading from socket mode connection")
+		}
+		envelope := socketModeEnvelope{}
+		if err = json.Unmarshal(message, &envelope); err != nil {
+			log.Printf(
+				"error unmarshaling socket mode message for app %q: %s",
+				app.AppID,
+				err,
+			)
+			continue
+		}
+		switch envelope.Type {
+		case socketModeTypeHello:
+			log.Printf("opened socket mode connection for app %q", app.AppID)
+		case socketModeTypeDisconnect:
+			log.Printf(
+				"closing socket mode connection for app %q; reason: %q",
+				app.AppID,
+				envelope.Reason,
+			)
+			return nil
+		default:
+			wg.Add(1)
+			go func(envelope socketModeEnvelope) {
+				defer wg.Done()
+				s.handleEnvelope(ctx, conn, writeMu, envelope)
+			}(envelope)
+		}
+	}
+}
+
+// handleEnvelope handles the request carried by the provided envelope and
+// acknowledges it. If the request cannot be handled, it is not acknowledged,
+// just as an internal server error would be returned over HTTP, so that Slack
+// treats it as having failed.
+func (s *socketModeClient) handleEnvelope(
+	ctx context.Context,
+	conn *websocket.Conn,
+	writeMu *sync.Mutex,
+	envelope socketModeEnvelope,
+) {
+	if envelope.RetryAttempt > 0 {
+		log.Printf(
+			"received retry #%d of socket mode %s request; reason: %q",
+			envelope.RetryAttempt,
+			envelope.Type,
+			envelope.RetryReason,
+		)
+	}
+	response, err := s.handle(ctx, envelope)
+	if err != nil {
+		log.Printf(
+			"error handling socket mode %s request %q: %s",
+			envelope.Type,
+			envelope.EnvelopeID,
+			err,
+		)
+		return
+	}
+	ack := socketModeAck{EnvelopeID: envelope.EnvelopeID}
+	if envelope.AcceptsResponsePayload && len(response) > 0 {
+		ack.Payload = response
+	}
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	if err = conn.WriteJSON(ack); err != nil {
+		log.Printf(
+			"error acknowledging socket mode request %q: %s",
+			envelope.EnvelopeID,
+			err,
+		)
+	}
+}
+
+// handle delegates the request carried by the provided envelope to the
+// appropriate service and returns the service's response.
+func (s *socketModeClient) handle(
+	ctx context.Context,
+	envelope socketModeEnvelope,
+) ([]byte, error) {
+	switch envelope.Type {
+	case socketModeTypeSlashCommands:
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(envelope.Payload, &fields); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling slash command")
+		}
+		return s.slashCommandService.Handle(
+			ctx,
+			newSlashCommand(func(name string) string {
+				if value, ok := fields[name]; ok && value != nil {
+					return fmt.Sprint(value)
+				}
+				return ""
+			}),
+		)
+	case socketModeTypeInteractive:
+		interaction := Interaction{}
+		if err := json.Unmarshal(envelope.Payload, &interaction); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling interaction")
+		}
+		// Over HTTP, requests for options are sent to their own endpoint.
+		if interaction.Type == interactionTypeBlockSuggestion {
+			return s.optionsService.Handle(ctx, interaction)
+		}
+		return s.interactionService.Handle(ctx, interaction)
+	case socketModeTypeEventsAPI:
+		eventsAPIEnvelope := EventsAPIEnvelope{}
+		if err :=
+			json.Unmarshal(envelope.Payload, &eventsAPIEnvelope); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling Events API request")
+		}
+		return s.eventsAPIService.Handle(ctx, eventsAPIEnvelope)
+	default:
+		log.Printf("ignoring socket mode request of type %q", envelope.Type)
+		return nil, nil
+	}
+}
@@ -0,0 +1,436 @@
+package slack
+
+// nolint: lll
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade-slack-gateway/internal/slack"
+	slackTesting "github.com/brigadecore/brigade-slack-gateway/internal/slack/testing"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSocketModeClient(t *testing.T) {
+	client, ok := NewSocketModeClient(
+		&slackTesting.MockAPIClient{},
+		&mockSlashCommandService{},
+		&mockInteractionService{},
+		&mockInteractionService{},
+		&mockEventsAPIService{},
+		SocketModeClientConfig{},
+	).(*socketModeClient)
+	require.True(t, ok)
+	require.NotNil(t, client.apiClient)
+	require.NotNil(t, client.slashCommandService)
+	require.NotNil(t, client.interactionService)
+	require.NotNil(t, client.optionsService)
+	require.NotNil(t, client.eventsAPIService)
+	require.NotNil(t, client.dialer)
+	require.Equal(t, 5*time.Second, client.config.ReconnectInterval)
+}
+
+func TestSocketModeClientHandle(t *testing.T) {
+	testCases := []struct {
+		name       string
+		envelope   socketModeEnvelope
+		client     *socketModeClient
+		assertions func([]byte, error)
+	}{
+		{
+			name: "slash command is not valid json",
+			envelope: socketModeEnvelope{
+				Type:    socketModeTypeSlashCommands,
+				Payload: json.RawMessage(`"just some garbage"`),
+			},
+			client: &socketModeClient{},
+			assertions: func(_ []byte, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error unmarshaling slash command")
+			},
+		},
+		{
+			name: "slash command",
+			envelope: socketModeEnvelope{
+				Type: socketModeTypeSlashCommands,
+				Payload: json.RawMessage(`{
+					"api_app_id": "control-app",
+					"team_id": "control",
+					"command": "/brigade",
+					"text": "deploy prod",
+					"is_enterprise_install": true
+				}`),
+			},
+			client: &socketModeClient{
+				slashCommandService: &mockSlashCommandService{
+					HandleFn: func(
+						_ context.Context,
+						command SlashCommand,
+					) ([]byte, error) {
+						require.Equal(
+							t,
+							SlashCommand{
+								APIAppID:            "control-app",
+								TeamID:              "control",
+								Command:             "/brigade",
+								Text:                "deploy prod",
+								IsEnterpriseInstall: true,
+							},
+							command,
+						)
+						return []byte(`{"text":"ok"}`), nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Equal(t, `{"text":"ok"}`, string(response))
+			},
+		},
+		{
+			name: "interaction is not valid json",
+			envelope: socketModeEnvelope{
+				Type:    socketModeTypeInteractive,
+				Payload: json.RawMessage(`"just some garbage"`),
+			},
+			client: &socketModeClient{},
+			assertions: func(_ []byte, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "error unmarshaling interaction")
+			},
+		},
+		{
+			name: "interaction",
+			envelope: socketModeEnvelope{
+				Type:    socketModeTypeInteractive,
+				Payload: json.RawMessage(`{"type":"block_actions"}`),
+			},
+			client: &socketModeClient{
+				interactionService: &mockInteractionService{
+					HandleFn: func(
+						_ context.Context,
+						interaction Interaction,
+					) ([]byte, error) {
+						require.Equal(t, interactionTypeBlockActions, interaction.Type)
+						return nil, nil
+					},
+				},
+			},
+			assertions: func(_ []byte, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "request for options",
+			envelope: socketModeEnvelope{
+				Type:    socketModeTypeInteractive,
+				Payload: json.RawMessage(`{"type":"block_suggestion"}`),
+			},
+			client: &socketModeClient{
+				optionsService: &mockInteractionService{
+					HandleFn: func(context.Context, Interaction) ([]byte, error) {
+						return []byte(`{"options":[]}`), nil
+					},
+				},
+			},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Equal(t, `{"options":[]}`, string(response))
+			},
+		},
+		{
+			name: "Events API request is not valid json",
+			envelope: socketModeEnvelope{
+				Type:    socketModeTypeEventsAPI,
+				Payload: json.RawMessage(`"just some garbage"`),
+			},
+			client: &socketModeClient{},
+			assertions: func(_ []byte, err error) {
+				require.Error(t, err)
+				require.Contains(
+					t,
+					err.Error(),
+					"error unmarshaling Events API request",
+				)
+			},
+		},
+		{
+			name: "Events API request",
+			envelope: socketModeEnvelope{
+				Type: socketModeTypeEventsAPI,
+				Payload: json.RawMessage(
+					`{"type":"event_callback","event":{"type":"app_mention"}}`,
+				),
+			},
+			client: &socketModeClient{
+				eventsAPIService: &mockEventsAPIService{
+					HandleFn: func(
+						_ context.Context,
+						envelope EventsAPIEnvelope,
+					) ([]byte, error) {
+						require.Equal(t, eventsAPITypeEventCallback, envelope.Type)
+						require.Equal(t, "app_mention", envelope.Event.Type)
+						return nil, errors.New("something went wrong")
+					},
+				},
+			},
+			assertions: func(_ []byte, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "something went wrong")
+			},
+		},
+		{
+			name: "unknown request type",
+			envelope: socketModeEnvelope{
+				Type: "nonsense",
+			},
+			client: &socketModeClient{},
+			assertions: func(response []byte, err error) {
+				require.NoError(t, err)
+				require.Nil(t, response)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.assertions(
+				testCase.client.handle(context.Background(), testCase.envelope),
+			)
+		})
+	}
+}
+
+func TestSocketModeClientRun(t *testing.T) {
+	// A fake Socket Mode endpoint that hands each connection to the test
+	upgrader := websocket.Upgrader{}
+	connCh := make(chan *websocket.Conn)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			connCh <- conn
+		}),
+	)
+	defer server.Close()
+
+	var opens int
+	client := NewSocketModeClient(
+		&slackTesting.MockAPIClient{
+			CallFn: func(
+				_ context.Context,
+				token string,
+				method string,
+				_ interface{},
+				result interface{},
+			) error {
+				require.Equal(t, "xapp-control", token)
+				require.Equal(t, "apps.connections.open", method)
+				opens++
+				// The first attempt to open a connection fails
+				if opens == 1 {
+					return &slack.APIError{Method: method, Code: "ratelimited"}
+				}
+				return json.Unmarshal(
+					[]byte(`{"url":"ws`+strings.TrimPrefix(server.URL, "http")+`"}`),
+					result,
+				)
+			},
+		},
+		&mockSlashCommandService{
+			HandleFn: func(context.Context, SlashCommand) ([]byte, error) {
+				return []byte(`{"text":"ok"}`), nil
+			},
+		},
+		&mockInteractionService{},
+		&mockInteractionService{},
+		&mockEventsAPIService{
+			HandleFn: func(
+				_ context.Context,
+				envelope EventsAPIEnvelope,
+			) ([]byte, error) {
+				if envelope.EventID == "bad" {
+					return nil, errors.New("something went wrong")
+				}
+				return nil, nil
+			},
+		},
+		SocketModeClientConfig{
+			SlackApps: slack.NewApps(map[string]slack.App{
+				"control-app": {
+					AppID:    "control-app",
+					AppToken: "xapp-control",
+				},
+				// No connection should be opened for this App
+				"kaos-app": {
+					AppID: "kaos-app",
+				},
+			}),
+			ReconnectInterval: 10 * time.Millisecond,
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error)
+	go func() {
+		errCh <- client.Run(ctx)
+	}()
+
+	conn := <-connCh
+	require.NoError(t, conn.WriteJSON(socketModeEnvelope{Type: "hello"}))
+	// This request can't be handled, so it shouldn't be acknowledged
+	require.NoError(
+		t,
+		conn.WriteJSON(
+			socketModeEnvelope{
+				EnvelopeID: "1",
+				Type:       socketModeTypeEventsAPI,
+				Payload:    json.RawMessage(`{"event_id":"bad"}`),
+			},
+		),
+	)
+	require.NoError(
+		t,
+		conn.WriteJSON(
+			socketModeEnvelope{
+				EnvelopeID: "2",
+				Type:       socketModeTypeEventsAPI,
+				Payload:    json.RawMessage(`{"event_id":"good"}`),
+			},
+		),
+	)
+	require.NoError(
+		t,
+		conn.WriteJSON(
+			socketModeEnvelope{
+				EnvelopeID:             "3",
+				Type:                   socketModeTypeSlashCommands,
+				Payload:                json.RawMessage(`{"command":"/brigade"}`),
+				AcceptsResponsePayload: true,
+			},
+		),
+	)
+	acks := map[string]string{}
+	for i := 0; i < 2; i++ {
+		ack := socketModeAck{}
+		require.NoError(t, conn.ReadJSON(&ack))
+		acks[ack.EnvelopeID] = string(ack.Payload)
+	}
+	require.Equal(t, map[string]string{"2": "", "3": `{"text":"ok"}`}, acks)
+
+	// When asked to, the client should replace the connection
+	require.NoError(
+		t,
+		conn.WriteJSON(
+			socketModeEnvelope{Type: "disconnect", Reason: "refresh_requested"},
+		),
+	)
+	conn.Close()
+	conn = <-connCh
+	defer conn.Close()
+	require.Equal(t, 3, opens)
+
+	// And it should stop when the context is canceled
+	cancel()
+	select {
+	case err := <-errCh:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for client to stop")
+	}
+}
+
+func TestSocketModeClientSyncApps(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	connCh := make(chan *websocket.Conn)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			connCh <- conn
+		}),
+	)
+	defer server.Close()
+
+	tokenCh := make(chan string, 10)
+	apps := slack.NewApps(map[string]slack.App{
+		"control-app": {
+			AppID:    "control-app",
+			AppToken: "xapp-control",
+		},
+	})
+	client := NewSocketModeClient(
+		&slackTesting.MockAPIClient{
+			CallFn: func(
+				_ context.Context,
+				token string,
+				_ string,
+				_ interface{},
+				result interface{},
+			) error {
+				tokenCh <- token
+				return json.Unmarshal(
+					[]byte(`{"url":"ws`+strings.TrimPrefix(server.URL, "http")+`"}`),
+					result,
+				)
+			},
+		},
+		&mockSlashCommandService{},
+		&mockInteractionService{},
+		&mockInteractionService{},
+		&mockEventsAPIService{},
+		SocketModeClientConfig{
+			SlackApps:         apps,
+			ReconnectInterval: time.Hour,
+		},
+	).(*socketModeClient)
+	client.syncInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error)
+	go func() {
+		errCh <- client.Run(ctx)
+	}()
+
+	conn := <-connCh
+	require.Equal(t, "xapp-control", <-tokenCh)
+
+	// When the app-level token changes, the connection should be replaced
+	apps.Replace(map[string]slack.App{
+		"control-app": {
+			AppID:    "control-app",
+			AppToken: "xapp-control-2",
+		},
+	})
+	_, _, err := conn.ReadMessage()
+	require.Error(t, err)
+	conn.Close()
+	conn = <-connCh
+	require.Equal(t, "xapp-control-2", <-tokenCh)
+
+	// When the App is removed, the connection should be closed
+	apps.Replace(nil)
+	_, _, err = conn.ReadMessage()
+	require.Error(t, err)
+	conn.Close()
+
+	cancel()
+	select {
+	case err := <-errCh:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for client to stop")
+	}
+	require.Empty(t, tokenCh)
+}
@@ -0,0 +1,76 @@
+package slack
+
+import "sort"
+
+// maxSuggestions is the maximum number of event types suggested when a slash
+// command results in no events.
+const maxSuggestions = 3
+
+// closestEventTypes returns up to maxSuggestions of the provided candidate
+// event types that are similar, but not identical, to the specified event
+// type, closest first. Candidates are considered similar if they can be
+// transformed into the specified event type with no more than a third as many
+// single-character edits as the event type has characters (and always at least
+// two).
+func closestEventTypes(eventType string, candidates []string) []string {
+	maxDistance := len(eventType) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	type suggestion struct {
+		eventType string
+		distance  int
+	}
+	suggestions := []suggestion{}
+	seen := map[string]struct{}{}
+	for _, candidate := range candidates {
+		if _, ok := seen[candidate]; ok || candidate == eventType ||
+			candidate == "*" {
+			continue
+		}
+		seen[candidate] = struct{}{}
+		if distance := levenshtein(eventType, candidate); distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{candidate, distance})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].eventType < suggestions[j].eventType
+	})
+	eventTypes := []string{}
+	for i := 0; i < len(suggestions) && i < maxSuggestions; i++ {
+		eventTypes = append(eventTypes, suggestions[i].eventType)
+	}
+	return eventTypes
+}
+
+// levenshtein returns the minimum number of single-character insertions,
+// deletions, and substitutions required to transform one string into another.
+func levenshtein(a string, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(br)]
+}
@@ -0,0 +1,66 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClosestEventTypes(t *testing.T) {
+	candidates := []string{
+		"deploy",
+		"deploy.staging",
+		"destroy",
+		"rollback",
+		"deploy",
+		"*",
+	}
+	testCases := []struct {
+		name      string
+		eventType string
+		expected  []string
+	}{
+		{
+			name:      "typo",
+			eventType: "deplyo",
+			expected:  []string{"deploy"},
+		},
+		{
+			name:      "closest first",
+			eventType: "destoy",
+			expected:  []string{"destroy", "deploy"},
+		},
+		{
+			name:      "exact match is not suggested",
+			eventType: "rollback",
+			expected:  []string{},
+		},
+		{
+			name:      "nothing similar",
+			eventType: "status",
+			expected:  []string{},
+		},
+		{
+			name:      "longer event type",
+			eventType: "deploy.stagign",
+			expected:  []string{"deploy.staging"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(
+				t,
+				testCase.expected,
+				closestEventTypes(testCase.eventType, candidates),
+			)
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	require.Equal(t, 0, levenshtein("deploy", "deploy"))
+	require.Equal(t, 6, levenshtein("", "deploy"))
+	require.Equal(t, 1, levenshtein("deploy", "deploys"))
+	require.Equal(t, 2, levenshtein("deploy", "deplyo"))
+	require.Equal(t, 3, levenshtein("kitten", "sitting"))
+}
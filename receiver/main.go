@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 
 	libHTTP "github.com/brigadecore/brigade-foundations/http"
 	"github.com/brigadecore/brigade-foundations/signals"
 	"github.com/brigadecore/brigade-foundations/version"
+	"github.com/brigadecore/brigade-slack-gateway/internal/brigade"
+	"github.com/brigadecore/brigade-slack-gateway/internal/reload"
+	libSlack "github.com/brigadecore/brigade-slack-gateway/internal/slack"
 	"github.com/brigadecore/brigade-slack-gateway/receiver/internal/slack"
-	"github.com/brigadecore/brigade/sdk/v3"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -20,19 +25,150 @@ func main() {
 		version.Commit(),
 	)
 
-	var slashCommandsService slack.SlashCommandService
+	// Both the Brigade API clients and the Slack App configurations can be
+	// replaced while in use, when the files they're loaded from change.
+	var clients *brigade.Clients
 	{
 		address, token, opts, err := apiClientConfig()
 		if err != nil {
 			log.Fatal(err)
 		}
+		clients = brigade.NewClients(address, token, opts)
+	}
+	projectsClient := clients.Projects()
+	// Time spent emitting events into Brigade's event bus is exposed as a metric.
+	eventsClient := slack.NewInstrumentedEventsClient(clients.Events())
+
+	var apps *libSlack.Apps
+	{
+		loadedApps, err := slackApps()
+		if err != nil {
+			log.Fatal(err)
+		}
+		apps = libSlack.NewApps(loadedApps)
+	}
+
+	var watcher *reload.Watcher
+	{
+		var err error
+		if watcher, err = configWatcher(reloadConfig(apps, clients)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	apiClient := libSlack.NewAPIClient()
+
+	// The token store is optional. Without one, Slack Apps can only be used in
+	// the workspaces their configured API tokens belong to.
+	store, err := tokenStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var slashCommandsService slack.SlashCommandService
+	{
+		config, err := slashCommandServiceConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.SlackApps = apps
+		config.TokenStore = store
 		slashCommandsService, err = slack.NewSlashCommandService(
-			sdk.NewEventsClient(address, token, &opts),
+			projectsClient,
+			eventsClient,
+			apiClient,
+			config,
 		)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
+	slashCommandsService = slack.NewRateLimitedSlashCommandService(
+		slashCommandsService,
+		slack.RateLimitedSlashCommandServiceConfig{
+			SlackApps: apps,
+		},
+	)
+
+	var eventsAPIService slack.EventsAPIService
+	{
+		config, err := eventsAPIServiceConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.SlackApps = apps
+		config.TokenStore = store
+		eventsAPIService = slack.NewEventsAPIService(
+			projectsClient,
+			eventsClient,
+			apiClient,
+			config,
+		)
+	}
+
+	// Retries from Slack are answered with the original response before they
+	// can count against rate limits or emit duplicate events.
+	{
+		config, err := deduplicationConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		slashCommandsService = slack.NewDeduplicatingSlashCommandService(
+			slashCommandsService,
+			config,
+		)
+		eventsAPIService = slack.NewDeduplicatingEventsAPIService(
+			eventsAPIService,
+			config,
+		)
+	}
+
+	// Every slash command Slack delivers, including retries, is counted.
+	slashCommandsService =
+		slack.NewInstrumentedSlashCommandService(slashCommandsService)
+
+	var interactionService slack.InteractionService
+	{
+		config, err := interactionServiceConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.SlackApps = apps
+		config.TokenStore = store
+		interactionService = slack.NewInteractionService(
+			projectsClient,
+			eventsClient,
+			apiClient,
+			slashCommandsService,
+			config,
+		)
+	}
+
+	optionsService := slack.NewOptionsService(projectsClient, eventsClient)
+
+	// Slack Apps with an app-level token receive requests over Socket Mode
+	// connections instead of over HTTP.
+	socketModeClient := slack.NewSocketModeClient(
+		apiClient,
+		slashCommandsService,
+		interactionService,
+		optionsService,
+		eventsAPIService,
+		slack.SocketModeClientConfig{
+			SlackApps: apps,
+		},
+	)
+
+	var oauthService slack.OAuthService
+	if store != nil {
+		oauthService = slack.NewOAuthService(
+			apiClient,
+			slack.OAuthServiceConfig{
+				SlackApps:  apps,
+				TokenStore: store,
+			},
+		)
+	}
 
 	var signatureVerificationFilter libHTTP.Filter
 	{
@@ -40,6 +176,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
+		config.SlackApps = apps
 		signatureVerificationFilter = slack.NewSignatureVerificationFilter(config)
 	}
 
@@ -53,7 +190,38 @@ func main() {
 				slack.NewSlashCommandHandler(slashCommandsService).ServeHTTP,
 			),
 		).Methods(http.MethodPost)
+		router.Handle(
+			"/events",
+			signatureVerificationFilter.Decorate(
+				slack.NewEventsAPIHandler(eventsAPIService).ServeHTTP,
+			),
+		).Methods(http.MethodPost)
+		router.Handle(
+			"/interactions",
+			signatureVerificationFilter.Decorate(
+				slack.NewInteractionHandler(interactionService).ServeHTTP,
+			),
+		).Methods(http.MethodPost)
+		router.Handle(
+			"/options",
+			signatureVerificationFilter.Decorate(
+				slack.NewInteractionHandler(optionsService).ServeHTTP,
+			),
+		).Methods(http.MethodPost)
+		// Requests to the OAuth endpoints come from users' browsers rather than
+		// from Slack, so they aren't signed.
+		if oauthService != nil {
+			router.Handle(
+				"/oauth/install",
+				slack.NewOAuthInstallHandler(oauthService),
+			).Methods(http.MethodGet)
+			router.Handle(
+				"/oauth/callback",
+				slack.NewOAuthCallbackHandler(oauthService),
+			).Methods(http.MethodGet)
+		}
 		router.HandleFunc("/healthz", libHTTP.Healthz).Methods(http.MethodGet)
+		router.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
 		serverConfig, err := serverConfig()
 		if err != nil {
 			log.Fatal(err)
@@ -61,7 +229,18 @@ func main() {
 		server = libHTTP.NewServer(router, &serverConfig)
 	}
 
+	ctx := signals.Context()
+
+	go watcher.Run(ctx)
+
+	go func() {
+		if err := socketModeClient.Run(ctx); err != nil &&
+			!errors.Is(err, context.Canceled) {
+			log.Println(err)
+		}
+	}()
+
 	log.Println(
-		server.ListenAndServe(signals.Context()),
+		server.ListenAndServe(ctx),
 	)
 }